@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/jedib0t/go-pretty/v6 v6.6.8
 	github.com/oschwald/geoip2-golang v1.13.0
+	golang.org/x/net v0.35.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
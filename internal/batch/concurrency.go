@@ -0,0 +1,178 @@
+package batch
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// 自适应并发参数
+const (
+	outcomeWindow      = 10  // 计算失败率的最近检测数
+	failureBackoffRate = 0.3 // 失败率超过该值时减半并发
+	failureGrowthRate  = 0.1 // 失败率不超过该值时才增加并发
+	latencyBackoff     = 2.0 // 握手时间超过基线的倍数时减半并发
+	latencySmoothing   = 0.3 // 握手时间指数平均的权重
+	decreaseFactor     = 0.5 // 乘性减少的系数
+)
+
+// concurrencyController 批量检测的自适应并发控制（AIMD）
+// 从 MinConcurrent 开始，检测顺利时每完成一轮（当前并发数个检测）并发加一，直到 MaxConcurrent；
+// 超时、连接失败增多或握手时间明显变长时并发减半，每轮最多减少一次
+type concurrencyController struct {
+	min, max int
+
+	mu       sync.Mutex
+	limit    float64         // 当前并发上限，增加时按 1/limit 累加
+	active   int             // 正在检测的数量
+	waiters  []chan struct{} // 等待名额的检测，先到先得
+	outcomes []bool          // 最近的检测是否失败
+	latency  time.Duration   // 握手时间的指数平均
+	baseline time.Duration   // 握手时间平均值的最低值
+	cooldown int             // 距离下次允许减少还需完成的检测数
+}
+
+// newConcurrencyController 创建自适应并发控制
+func newConcurrencyController(min, max int) *concurrencyController {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &concurrencyController{min: min, max: max, limit: float64(min)}
+}
+
+// Level 当前并发上限
+func (c *concurrencyController) Level() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.level()
+}
+
+// level 当前并发上限，调用方持有锁
+func (c *concurrencyController) level() int {
+	return int(math.Floor(c.limit))
+}
+
+// Acquire 等待检测名额
+func (c *concurrencyController) Acquire(ctx context.Context) error {
+	c.mu.Lock()
+	if c.active < c.level() && len(c.waiters) == 0 {
+		c.active++
+		c.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	c.waiters = append(c.waiters, ready)
+	c.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, waiter := range c.waiters {
+			if waiter == ready {
+				c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// 取消的同时已获得名额，归还给下一个等待者
+		c.active--
+		c.wake()
+		return ctx.Err()
+	}
+}
+
+// Release 归还名额并根据检测结果调整并发，缓存结果不反映网络状况，不参与调整
+func (c *concurrencyController) Release(result *types.DetectionResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.active--
+	if result == nil || !result.Cached {
+		c.adjust(result)
+	}
+	c.wake()
+}
+
+// wake 在名额允许时唤醒等待者，调用方持有锁
+func (c *concurrencyController) wake() {
+	for len(c.waiters) > 0 && c.active < c.level() {
+		close(c.waiters[0])
+		c.waiters = c.waiters[1:]
+		c.active++
+	}
+}
+
+// adjust 按检测结果调整并发上限，调用方持有锁
+func (c *concurrencyController) adjust(result *types.DetectionResult) {
+	c.outcomes = append(c.outcomes, isTransportFailure(result))
+	if len(c.outcomes) > outcomeWindow {
+		c.outcomes = c.outcomes[1:]
+	}
+
+	slow := false
+	if result != nil && result.TLS != nil && result.TLS.HandshakeTime > 0 {
+		sample := result.TLS.HandshakeTime
+		if c.latency == 0 {
+			c.latency = sample
+		} else {
+			c.latency = time.Duration(latencySmoothing*float64(sample) + (1-latencySmoothing)*float64(c.latency))
+		}
+		if c.baseline == 0 || c.latency < c.baseline {
+			c.baseline = c.latency
+		}
+		slow = float64(c.latency) > latencyBackoff*float64(c.baseline)
+	}
+
+	if c.cooldown > 0 {
+		c.cooldown--
+	}
+
+	rate := c.failureRate()
+	switch {
+	case rate > failureBackoffRate || slow:
+		if c.cooldown == 0 && c.limit > float64(c.min) {
+			c.limit = math.Max(float64(c.min), math.Floor(c.limit*decreaseFactor))
+			// 减少后的一轮检测仍可能受之前拥塞的影响，不再重复减少
+			c.cooldown = c.level()
+			// 重新计算基线，避免网络整体变慢后一直判定为拥塞
+			if slow {
+				c.baseline = c.latency
+			}
+		}
+	case rate <= failureGrowthRate:
+		if c.limit < float64(c.max) {
+			c.limit = math.Min(float64(c.max), c.limit+1/c.limit)
+		}
+	}
+}
+
+// failureRate 最近检测的失败率，调用方持有锁
+func (c *concurrencyController) failureRate() float64 {
+	if len(c.outcomes) == 0 {
+		return 0
+	}
+	failures := 0
+	for _, failed := range c.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(c.outcomes))
+}
+
+// isTransportFailure 检测是否因超时或连接失败而未完成
+// 被墙、不支持TLS1.3等正常的不适合结论不计入
+func isTransportFailure(result *types.DetectionResult) bool {
+	if result == nil {
+		return true
+	}
+	return types.IsCheckFailure(result.ReasonCode) || result.ReasonCode == types.ReasonUnreachable
+}
@@ -0,0 +1,201 @@
+package batch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// successResult 顺利完成的检测
+func successResult(handshake time.Duration) *types.DetectionResult {
+	return &types.DetectionResult{Suitable: true, TLS: &types.TLSResult{HandshakeTime: handshake}}
+}
+
+// failureResult 因指定原因未完成或不适合的检测
+func failureResult(code string) *types.DetectionResult {
+	result := &types.DetectionResult{}
+	result.SetReason(code, types.ReasonName(code))
+	return result
+}
+
+// complete 占用并归还一个名额，模拟一次检测
+func complete(t *testing.T, c *concurrencyController, result *types.DetectionResult) {
+	t.Helper()
+	if err := c.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.Release(result)
+}
+
+func TestConcurrencyBounds(t *testing.T) {
+	tests := []struct {
+		min, max         int
+		wantMin, wantMax int
+	}{
+		{2, 10, 2, 10},
+		{0, 5, 1, 5},
+		{-3, 0, 1, 1},
+		{8, 4, 8, 8},
+	}
+
+	for _, tt := range tests {
+		c := newConcurrencyController(tt.min, tt.max)
+		if c.min != tt.wantMin || c.max != tt.wantMax || c.Level() != tt.wantMin {
+			t.Errorf("newConcurrencyController(%d, %d) = %d..%d 从 %d 开始，应为 %d..%d 从 %d 开始",
+				tt.min, tt.max, c.min, c.max, c.Level(), tt.wantMin, tt.wantMax, tt.wantMin)
+		}
+	}
+}
+
+func TestConcurrencyIncreasesOnSuccess(t *testing.T) {
+	c := newConcurrencyController(2, 10)
+
+	// 每完成一轮（当前并发数个检测）并发加一
+	levels := []int{2, 2, 3, 3, 3, 4}
+	for i, want := range levels {
+		complete(t, c, successResult(100*time.Millisecond))
+		if got := c.Level(); got != want {
+			t.Fatalf("第 %d 次成功后并发 = %d，应为 %d", i+1, got, want)
+		}
+	}
+
+	// 不超过上限
+	for i := 0; i < 200; i++ {
+		complete(t, c, successResult(100*time.Millisecond))
+	}
+	if got := c.Level(); got != 10 {
+		t.Errorf("持续成功后并发 = %d，应停在上限 10", got)
+	}
+}
+
+func TestConcurrencyHalvesOnTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *types.DetectionResult
+		halves bool
+	}{
+		{"超时", failureResult(types.ReasonTimeout), true},
+		{"无法连接", failureResult(types.ReasonUnreachable), true},
+		{"没有结果", nil, true},
+		{"被墙不是网络问题", failureResult(types.ReasonBlocked), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConcurrencyController(1, 16)
+			for i := 0; i < 500; i++ {
+				complete(t, c, successResult(100*time.Millisecond))
+			}
+			if c.Level() != 16 {
+				t.Fatalf("应先增加到上限，当前 %d", c.Level())
+			}
+
+			// 最近10次检测中失败超过30%时减半
+			for i := 0; i < 3; i++ {
+				complete(t, c, tt.result)
+			}
+			if c.Level() != 16 {
+				t.Fatalf("失败率未超过阈值时不应减少，当前 %d", c.Level())
+			}
+			complete(t, c, tt.result)
+
+			want := 16
+			if tt.halves {
+				want = 8
+			}
+			if got := c.Level(); got != want {
+				t.Errorf("并发 = %d，应为 %d", got, want)
+			}
+		})
+	}
+}
+
+func TestConcurrencyBackoffCooldownAndMinimum(t *testing.T) {
+	c := newConcurrencyController(2, 16)
+	for i := 0; i < 500; i++ {
+		complete(t, c, successResult(100*time.Millisecond))
+	}
+
+	timeout := failureResult(types.ReasonTimeout)
+	for i := 0; i < 4; i++ {
+		complete(t, c, timeout)
+	}
+	if c.Level() != 8 {
+		t.Fatalf("并发 = %d，应减半为 8", c.Level())
+	}
+
+	// 减半后的一轮（8个检测）内不重复减少
+	for i := 0; i < 7; i++ {
+		complete(t, c, timeout)
+		if c.Level() != 8 {
+			t.Fatalf("冷却期内第 %d 次失败后并发 = %d，应保持 8", i+1, c.Level())
+		}
+	}
+	complete(t, c, timeout)
+	if c.Level() != 4 {
+		t.Fatalf("冷却期结束后并发 = %d，应减半为 4", c.Level())
+	}
+
+	// 持续失败时不低于下限
+	for i := 0; i < 100; i++ {
+		complete(t, c, timeout)
+	}
+	if c.Level() != 2 {
+		t.Errorf("持续失败后并发 = %d，应停在下限 2", c.Level())
+	}
+}
+
+func TestConcurrencyHalvesOnSlowHandshake(t *testing.T) {
+	c := newConcurrencyController(1, 16)
+	for i := 0; i < 500; i++ {
+		complete(t, c, successResult(100*time.Millisecond))
+	}
+
+	// 握手时间的平均值超过基线2倍时减半，之后以变慢后的时间为基线
+	complete(t, c, successResult(time.Second))
+	if got := c.Level(); got != 8 {
+		t.Fatalf("握手明显变慢后并发 = %d，应减半为 8", got)
+	}
+	if c.baseline != c.latency {
+		t.Errorf("基线 = %v，应重置为当前平均 %v", c.baseline, c.latency)
+	}
+}
+
+func TestConcurrencyIgnoresCachedResults(t *testing.T) {
+	c := newConcurrencyController(2, 10)
+	cached := failureResult(types.ReasonTimeout)
+	cached.Cached = true
+
+	for i := 0; i < 20; i++ {
+		complete(t, c, cached)
+	}
+	if c.Level() != 2 || len(c.outcomes) != 0 {
+		t.Errorf("缓存结果不应参与调整，并发 %d，记录 %d 次", c.Level(), len(c.outcomes))
+	}
+}
+
+func TestConcurrencyAcquireWaitsForSlot(t *testing.T) {
+	c := newConcurrencyController(2, 2)
+	for i := 0; i < 2; i++ {
+		if err := c.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 名额已满时等待，取消后不占用名额
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Acquire(ctx); err == nil {
+		t.Fatal("名额已满时取消应返回错误")
+	}
+	if len(c.waiters) != 0 || c.active != 2 {
+		t.Fatalf("取消后等待者 %d 个，占用 %d 个名额", len(c.waiters), c.active)
+	}
+
+	c.Release(successResult(100 * time.Millisecond))
+	if err := c.Acquire(ctx); err != nil {
+		t.Errorf("归还名额后应立即取得名额: %v", err)
+	}
+}
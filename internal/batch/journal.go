@@ -0,0 +1,174 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// journalEntry 进度日志中的一行，对应一个完成检测的目标
+type journalEntry struct {
+	Source string                 `json:"source,omitempty"` // 多源地址对比时的源地址
+	Target types.Target           `json:"target"`
+	Result *types.DetectionResult `json:"result"`
+}
+
+// Journal 批量检测进度日志
+// 每完成一个目标追加一行JSON，中断后可据此跳过已完成的目标继续检测
+type Journal struct {
+	path string
+
+	mu        sync.Mutex
+	file      *os.File
+	completed map[string]*types.DetectionResult
+}
+
+// OpenJournal 打开进度日志
+// resume 为 true 时读取已有记录并继续追加，否则创建新文件
+func OpenJournal(path string, resume bool) (*Journal, error) {
+	journal := &Journal{
+		path:      path,
+		completed: make(map[string]*types.DetectionResult),
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if resume {
+		if err := journal.load(); err != nil {
+			return nil, err
+		}
+	} else {
+		if dir := filepath.Dir(path); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建进度日志目录失败: %v", err)
+			}
+		}
+		flags |= os.O_EXCL
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开进度日志失败: %v", err)
+	}
+	journal.file = file
+
+	// 中断时最后一行可能写到一半，先换行再追加，避免新记录与之连在一起
+	if resume {
+		if err := journal.terminateLastLine(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("打开进度日志失败: %v", err)
+		}
+	}
+	return journal, nil
+}
+
+// NewJournalPath 按开始时间生成新的进度日志路径
+func NewJournalPath(dir string, startTime time.Time) string {
+	return filepath.Join(dir, fmt.Sprintf("batch_%s.jsonl", startTime.Format("20060102_150405")))
+}
+
+// load 读取已有记录，最后一行可能因中断而不完整，无法解析的行忽略
+func (j *Journal) load() error {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("进度日志不存在: %s", j.path)
+	}
+	if err != nil {
+		return fmt.Errorf("读取进度日志失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Result == nil {
+			continue
+		}
+		j.completed[journalKey(entry.Source, entry.Target)] = entry.Result
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取进度日志失败: %v", err)
+	}
+	return nil
+}
+
+// terminateLastLine 文件不以换行结尾时补一个换行
+func (j *Journal) terminateLastLine() error {
+	reader, err := os.Open(j.path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	info, err := reader.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := reader.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = j.file.Write([]byte{'\n'})
+	}
+	return err
+}
+
+// Path 进度日志文件路径
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Completed 目标在之前的运行中已完成的结果
+// 超时、中断或出错的结果不算完成，恢复时重新检测
+func (j *Journal) Completed(source string, target types.Target) (*types.DetectionResult, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	result, exists := j.completed[journalKey(source, target)]
+	if !exists || types.IsCheckFailure(result.ReasonCode) {
+		return nil, false
+	}
+	return result, true
+}
+
+// Record 追加一个完成检测的目标
+func (j *Journal) Record(source string, target types.Target, result *types.DetectionResult) error {
+	data, err := json.Marshal(journalEntry{Source: source, Target: target, Result: result})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.completed[journalKey(source, target)] = result
+	_, err = j.file.Write(data)
+	return err
+}
+
+// Close 关闭进度日志
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// Remove 关闭并删除进度日志，之后的 Close 不再有效果
+func (j *Journal) Remove() error {
+	j.Close()
+	return os.Remove(j.path)
+}
+
+// journalKey 进度日志中目标的键
+func journalKey(source string, target types.Target) string {
+	return source + "|" + strings.ToLower(target.Domain) + "|" + target.IP
+}
@@ -0,0 +1,214 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// newTestJournal 在临时目录中创建进度日志
+func newTestJournal(t *testing.T) *Journal {
+	t.Helper()
+	journal, err := OpenJournal(NewJournalPath(filepath.Join(t.TempDir(), "journal"), time.Now()), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return journal
+}
+
+// resumeJournal 关闭后按 --resume 重新打开
+func resumeJournal(t *testing.T, journal *Journal) *Journal {
+	t.Helper()
+	journal.Close()
+	resumed, err := OpenJournal(journal.Path(), true)
+	if err != nil {
+		t.Fatalf("继续进度日志失败: %v", err)
+	}
+	t.Cleanup(func() { resumed.Close() })
+	return resumed
+}
+
+// newTestManager 输出写到 progress 的批量管理器，不需要引擎即可处理进度日志
+func newTestManager(progress *bytes.Buffer) *Manager {
+	config := &types.Config{}
+	config.Concurrency.MinConcurrent = 1
+	config.Concurrency.MaxConcurrent = 4
+	config.Batch.Timeout = time.Minute
+	return NewManager(config, progress)
+}
+
+func TestJournalCompleted(t *testing.T) {
+	journal := newTestJournal(t)
+
+	suitable := &types.DetectionResult{Domain: "a.example", Suitable: true}
+	blocked := failureResult(types.ReasonBlocked)
+	timeout := failureResult(types.ReasonTimeout)
+
+	records := []struct {
+		source string
+		target types.Target
+		result *types.DetectionResult
+	}{
+		{"", types.Target{Domain: "A.example"}, suitable},
+		{"", types.Target{Domain: "b.example", IP: "192.0.2.1"}, blocked},
+		{"", types.Target{Domain: "c.example"}, timeout},
+		{"198.51.100.1", types.Target{Domain: "d.example"}, suitable},
+	}
+	for _, record := range records {
+		if err := journal.Record(record.source, record.target, record.result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resumed := resumeJournal(t, journal)
+
+	tests := []struct {
+		name   string
+		source string
+		target types.Target
+		want   string // 恢复的结果的原因代码，"-" 表示需要重新检测
+	}{
+		{"域名不区分大小写", "", types.Target{Domain: "a.example"}, ""},
+		{"不适合的结论同样完成", "", types.Target{Domain: "b.example", IP: "192.0.2.1"}, types.ReasonBlocked},
+		{"不同的扫描IP", "", types.Target{Domain: "b.example", IP: "192.0.2.2"}, "-"},
+		{"超时的目标重新检测", "", types.Target{Domain: "c.example"}, "-"},
+		{"按源地址区分", "198.51.100.1", types.Target{Domain: "d.example"}, ""},
+		{"其他源地址", "", types.Target{Domain: "d.example"}, "-"},
+		{"没有记录", "", types.Target{Domain: "e.example"}, "-"},
+	}
+	for _, tt := range tests {
+		result, ok := resumed.Completed(tt.source, tt.target)
+		switch {
+		case tt.want == "-" && ok:
+			t.Errorf("%s: 不应视为已完成", tt.name)
+		case tt.want != "-" && !ok:
+			t.Errorf("%s: 应视为已完成", tt.name)
+		case ok && result.ReasonCode != tt.want:
+			t.Errorf("%s: 原因代码 = %q，应为 %q", tt.name, result.ReasonCode, tt.want)
+		}
+	}
+}
+
+func TestJournalCorruption(t *testing.T) {
+	journal := newTestJournal(t)
+	target := types.Target{Domain: "a.example"}
+	if err := journal.Record("", target, &types.DetectionResult{Domain: "a.example", Suitable: true}); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	// 无法解析的行、没有结果的行，以及中断时写到一半的最后一行
+	file, err := os.OpenFile(journal.Path(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("not json\n")
+	file.WriteString(`{"target":{"domain":"b.example"},"result":null}` + "\n")
+	file.WriteString(`{"target":{"domain":"c.example"},"result":{"dom`)
+	file.Close()
+
+	resumed, err := OpenJournal(journal.Path(), true)
+	if err != nil {
+		t.Fatalf("损坏的行应忽略: %v", err)
+	}
+	if _, ok := resumed.Completed("", target); !ok {
+		t.Error("完整的记录应保留")
+	}
+	for _, domain := range []string{"b.example", "c.example"} {
+		if _, ok := resumed.Completed("", types.Target{Domain: domain}); ok {
+			t.Errorf("%s 的记录不完整，不应视为已完成", domain)
+		}
+	}
+
+	// 继续追加的记录不与写到一半的行连在一起
+	next := types.Target{Domain: "d.example"}
+	if err := resumed.Record("", next, &types.DetectionResult{Domain: "d.example", Suitable: true}); err != nil {
+		t.Fatal(err)
+	}
+	again := resumeJournal(t, resumed)
+	if _, ok := again.Completed("", next); !ok {
+		t.Error("中断后追加的记录应能读取")
+	}
+}
+
+func TestOpenJournalErrors(t *testing.T) {
+	journal := newTestJournal(t)
+	journal.Close()
+
+	if _, err := OpenJournal(journal.Path(), false); err == nil {
+		t.Error("新建进度日志时不应覆盖已有文件")
+	}
+	missing := filepath.Join(t.TempDir(), "missing.jsonl")
+	if _, err := OpenJournal(missing, true); err == nil || !strings.Contains(err.Error(), "不存在") {
+		t.Errorf("继续不存在的进度日志应返回错误，得到 %v", err)
+	}
+}
+
+func TestResumeSkipsCompletedTargets(t *testing.T) {
+	journal := newTestJournal(t)
+	targets := []types.Target{
+		{Domain: "a.example"},
+		{Domain: "b.example", IP: "192.0.2.1"},
+		{Domain: "b.example", IP: "192.0.2.2"},
+	}
+	for _, target := range targets {
+		result := &types.DetectionResult{Domain: target.Domain, TargetIP: target.IP, Suitable: true, Index: 99}
+		if err := journal.Record("", target, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resumed := resumeJournal(t, journal)
+
+	// 所有目标都已完成，不需要引擎
+	var progress bytes.Buffer
+	manager := newTestManager(&progress)
+	results, err := manager.checkWithProgress(context.Background(), nil, targets, resumed, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, result := range results {
+		if result == nil || result.TargetIP != targets[i].IP || result.Index != i {
+			t.Errorf("第 %d 个目标恢复的结果不正确: %+v", i, result)
+		}
+	}
+	if !strings.Contains(progress.String(), "恢复 3 个已完成的目标，剩余 0 个") {
+		t.Errorf("应提示恢复的目标数，输出: %s", progress.String())
+	}
+}
+
+func TestFinishJournal(t *testing.T) {
+	tests := []struct {
+		name     string
+		results  []*types.DetectionResult
+		wantKeep bool
+	}{
+		{"全部完成", []*types.DetectionResult{{Suitable: true}, failureResult(types.ReasonBlocked)}, false},
+		{"有超时的目标", []*types.DetectionResult{{Suitable: true}, failureResult(types.ReasonTimeout)}, true},
+		{"有中断的目标", []*types.DetectionResult{failureResult(types.ReasonInterrupted)}, true},
+		{"有出错的目标", []*types.DetectionResult{{Suitable: true}, nil}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal := newTestJournal(t)
+			var progress bytes.Buffer
+			newTestManager(&progress).finishJournal(journal, tt.results)
+
+			_, err := os.Stat(journal.Path())
+			if kept := err == nil; kept != tt.wantKeep {
+				t.Errorf("保留进度日志 = %v，应为 %v", kept, tt.wantKeep)
+			}
+			if hint := strings.Contains(progress.String(), "--resume "+journal.Path()); hint != tt.wantKeep {
+				t.Errorf("提示 --resume = %v，应为 %v，输出: %s", hint, tt.wantKeep, progress.String())
+			}
+			journal.Close()
+		})
+	}
+}
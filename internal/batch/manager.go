@@ -3,6 +3,8 @@ package batch
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -19,24 +21,27 @@ type Manager struct {
 	formatter      *report.Formatter
 	tableFormatter *report.TableFormatter
 	config         *types.Config
+	progress       io.Writer // 进度、进度日志和继续检测的提示信息的输出位置
 	mu             sync.RWMutex
 	running        bool
 }
 
-// NewManager 创建批量管理器
-func NewManager(config *types.Config) *Manager {
+// NewManager 创建批量管理器，进度和提示信息写到 progress
+func NewManager(config *types.Config, progress io.Writer) *Manager {
 	return &Manager{
 		config:         config,
+		progress:       progress,
 		formatter:      report.NewFormatter(config),
 		tableFormatter: report.NewTableFormatter(config),
 	}
 }
 
-// NewManagerWithEngine 使用现有引擎创建批量管理器
-func NewManagerWithEngine(engine *core.Engine, config *types.Config) *Manager {
+// NewManagerWithEngine 使用现有引擎创建批量管理器，进度和提示信息写到 progress
+func NewManagerWithEngine(engine *core.Engine, config *types.Config, progress io.Writer) *Manager {
 	return &Manager{
 		engine:         engine,
 		config:         config,
+		progress:       progress,
 		formatter:      report.NewFormatter(config),
 		tableFormatter: report.NewTableFormatter(config),
 	}
@@ -87,77 +92,194 @@ func (bm *Manager) Stop() error {
 
 // CheckDomains 批量检测域名
 func (bm *Manager) CheckDomains(ctx context.Context, domains []string) ([]*types.DetectionResult, error) {
+	return bm.CheckTargets(ctx, types.DomainTargets(domains))
+}
+
+// CheckTargets 批量检测目标（域名及可选的扫描IP）
+func (bm *Manager) CheckTargets(ctx context.Context, targets []types.Target) ([]*types.DetectionResult, error) {
 	if !bm.running {
 		return nil, fmt.Errorf("批量管理器未运行")
 	}
 
-	if len(domains) == 0 {
+	if len(targets) == 0 {
 		return []*types.DetectionResult{}, nil
 	}
 
+	// 配置了多个源地址时逐个检测并对比
+	if len(bm.config.Network.SourceAddresses) > 1 {
+		return bm.compareSources(ctx, targets)
+	}
+
 	startTime := time.Now()
 
-	// 使用流式检测显示实时进度
-	results, err := bm.CheckDomainsWithProgress(ctx, domains)
+	// 每个完成的目标写入进度日志，中断后可继续
+	journal, err := bm.openJournal(startTime)
 	if err != nil {
 		return nil, err
 	}
+	if journal != nil {
+		defer journal.Close()
+	}
+
+	// 使用流式检测显示实时进度
+	results, err := bm.checkWithProgress(ctx, bm.engine, targets, journal, "")
+	interrupted := err != nil && ctx.Err() != nil
+	if err != nil && !interrupted {
+		return nil, err
+	}
 
-	// 生成批量报告
+	// 生成批量报告（被中断时只包含已完成的部分）
 	batchReport := bm.generateBatchReport(results, startTime, time.Now())
+	batchReport.Interrupted = interrupted
 
 	// 打印报告
-	fmt.Println(bm.formatBatchReport(batchReport))
+	if report.IsJSONOutput(bm.config) {
+		output, err := bm.formatter.FormatBatchReportJSON(batchReport)
+		if err != nil {
+			return results, err
+		}
+		fmt.Fprint(report.ResultOutput(), output)
+	} else {
+		fmt.Fprintln(report.ResultOutput(), bm.formatBatchReport(batchReport))
+	}
+
+	// 被中断时保存部分报告，避免已完成的检测结果丢失
+	if interrupted {
+		if path, saveErr := bm.savePartialReport(batchReport); saveErr != nil {
+			fmt.Fprintf(bm.progress, "保存部分报告失败: %v\n", saveErr)
+		} else {
+			fmt.Fprintf(bm.progress, "部分报告已保存到: %s\n", path)
+		}
+		return results, err
+	}
 
+	bm.finishJournal(journal, results)
 	return results, nil
 }
 
+// finishJournal 批量检测完成后删除进度日志
+// 有超时或出错的目标时保留，可以用 --resume 只重新检测这些目标
+func (bm *Manager) finishJournal(journal *Journal, results []*types.DetectionResult) {
+	if journal == nil {
+		return
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result == nil || types.IsCheckFailure(result.ReasonCode) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(bm.progress, "%d 个目标检测失败，可使用 --resume %s 重新检测\n", failed, journal.Path())
+		return
+	}
+
+	if err := journal.Remove(); err != nil {
+		fmt.Fprintf(bm.progress, "删除进度日志失败: %v\n", err)
+	}
+}
+
 // CheckDomainsWithProgress 带进度显示的并发批量检测
-func (bm *Manager) CheckDomainsWithProgress(ctx context.Context, domains []string) ([]*types.DetectionResult, error) {
-	results := make([]*types.DetectionResult, len(domains))
-	resultChan := make(chan *ProgressResult, len(domains))
+func (bm *Manager) CheckDomainsWithProgress(ctx context.Context, targets []types.Target) ([]*types.DetectionResult, error) {
+	return bm.checkWithProgress(ctx, bm.engine, targets, nil, "")
+}
+
+// openJournal 打开本次批量检测的进度日志
+// 指定 --resume 时继续写入该日志；新建日志失败时不影响检测，只给出提示
+func (bm *Manager) openJournal(startTime time.Time) (*Journal, error) {
+	if path := bm.config.Batch.Resume; path != "" {
+		journal, err := OpenJournal(path, true)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(bm.progress, "[%s] 从进度日志继续检测: %s\n", time.Now().Format("15:04:05"), path)
+		return journal, nil
+	}
+
+	journal, err := OpenJournal(NewJournalPath(bm.config.Batch.JournalDir, startTime), false)
+	if err != nil {
+		fmt.Fprintf(bm.progress, "创建进度日志失败，本次检测中断后将无法继续: %v\n", err)
+		return nil, nil
+	}
+	fmt.Fprintf(bm.progress, "[%s] 进度日志: %s\n", time.Now().Format("15:04:05"), journal.Path())
+	return journal, nil
+}
+
+// checkWithProgress 使用指定引擎并发检测并显示进度
+// journal 不为 nil 时跳过其中已完成的目标，并记录新完成的目标；source 区分多源地址对比时的各轮检测
+func (bm *Manager) checkWithProgress(ctx context.Context, engine *core.Engine, targets []types.Target, journal *Journal, source string) ([]*types.DetectionResult, error) {
+	results := make([]*types.DetectionResult, len(targets))
+
+	// 从进度日志恢复已完成的目标
+	var pending []int
+	for i, target := range targets {
+		if journal != nil {
+			if result, ok := journal.Completed(source, target); ok {
+				result.Index = i
+				results[i] = result
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+	recovered := len(targets) - len(pending)
+	if recovered > 0 {
+		fmt.Fprintf(bm.progress, "[%s] 从进度日志恢复 %d 个已完成的目标，剩余 %d 个\n", time.Now().Format("15:04:05"), recovered, len(pending))
+	}
+
+	// record 写入进度日志，写入失败后不再记录
+	record := func(progressResult *ProgressResult) {
+		if journal == nil || progressResult.Error != nil || progressResult.Result == nil {
+			return
+		}
+		if err := journal.Record(source, targets[progressResult.Index], progressResult.Result); err != nil {
+			fmt.Fprintf(bm.progress, "写入进度日志失败: %v\n", err)
+			journal = nil
+		}
+	}
+
+	// resumeHint 提示如何继续未完成的检测
+	resumeHint := func() {
+		if journal != nil {
+			fmt.Fprintf(bm.progress, "使用 --resume %s 继续未完成的检测\n", journal.Path())
+		}
+	}
+
+	resultChan := make(chan *ProgressResult, len(pending))
+
+	// 并发数在 MinConcurrent 和 MaxConcurrent 之间自适应调整
+	controller := newConcurrencyController(bm.config.Concurrency.MinConcurrent, bm.config.Concurrency.MaxConcurrent)
 
 	// 启动并发检测
 	go func() {
 		defer close(resultChan)
 
-		// 使用WaitGroup控制并发
+		// 使用WaitGroup等待所有检测完成
 		var wg sync.WaitGroup
 
-		// 使用配置的最大并发数，提高检测效率
-		concurrency := int(bm.config.Concurrency.MinConcurrent) // 使用配置的并发数（Max8个, Min2个）
-		semaphore := make(chan struct{}, concurrency)
-
-		for i, domain := range domains {
+		for _, i := range pending {
 			wg.Add(1)
-			go func(index int, domain string) {
+			go func(index int, target types.Target) {
 				defer wg.Done()
 
-				// 获取信号量
-				select {
-				case semaphore <- struct{}{}:
-					defer func() {
-						<-semaphore
-					}()
-				case <-ctx.Done():
+				// 获取检测名额
+				if controller.Acquire(ctx) != nil {
 					return
 				}
 
 				// 检测域名
-				result, err := bm.engine.CheckDomain(ctx, domain)
+				result, err := engine.CheckTarget(ctx, target)
+				controller.Release(result)
 
-				// 发送结果
-				select {
-				case resultChan <- &ProgressResult{
+				// 发送结果（通道有足够缓冲，不会阻塞）
+				resultChan <- &ProgressResult{
 					Index:  index,
-					Domain: domain,
+					Domain: target.Domain,
 					Result: result,
 					Error:  err,
-				}:
-				case <-ctx.Done():
-					return
 				}
-			}(i, domain)
+			}(i, targets[i])
 		}
 
 		wg.Wait()
@@ -165,47 +287,43 @@ func (bm *Manager) CheckDomainsWithProgress(ctx context.Context, domains []strin
 
 	// 收集结果并显示进度
 	completed := 0
-	timeout := time.NewTimer(1200 * time.Second) // 添加1200秒总超时
+	timeout := time.NewTimer(bm.config.Batch.Timeout) // 批量检测总超时
 	defer timeout.Stop()
 
-	for completed < len(domains) {
+	for completed < len(pending) {
 		select {
 		case progressResult := <-resultChan:
 			results[progressResult.Index] = progressResult.Result
+			record(progressResult)
 			completed++
 
 			// 显示进度
-			fmt.Printf("[%s] 正在检测 [%d/%d]: %s... ", time.Now().Format("15:04:05"), completed, len(domains), progressResult.Domain)
+			fmt.Fprintf(bm.progress, "[%s] 正在检测 [%d/%d] (并发 %d): %s... ", time.Now().Format("15:04:05"), recovered+completed, len(targets), controller.Level(), progressResult.Domain)
 
 			if progressResult.Error != nil {
-				fmt.Printf("失败 - %v\n", progressResult.Error)
+				fmt.Fprintf(bm.progress, "失败 - %v\n", progressResult.Error)
 			} else if progressResult.Result.Suitable {
-				fmt.Printf("适合\n")
+				fmt.Fprintf(bm.progress, "适合\n")
 			} else {
 				// 获取不适合的原因
 				reason := "未知原因"
 				if progressResult.Result.Error != nil {
 					reason = progressResult.Result.Error.Error()
 				}
-				fmt.Printf("不适合 - %s\n", reason)
+				fmt.Fprintf(bm.progress, "不适合 - %s\n", reason)
 			}
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			// 中断处理：保留已完成的结果，未完成的域名标记为中断
+			fmt.Fprintf(bm.progress, "\n[%s] 检测被中断，以下域名未完成检测：\n", time.Now().Format("15:04:05"))
+			bm.collectPendingResults(resultChan, results, record)
+			bm.fillIncompleteResults(targets, results, types.ReasonInterrupted)
+			resumeHint()
+			return results, ctx.Err()
 		case <-timeout.C:
 			// 超时处理：显示未完成的域名
-			fmt.Printf("\n[%s] 检测超时，以下域名未完成检测：\n", time.Now().Format("15:04:05"))
-			for i, domain := range domains {
-				if results[i] == nil {
-					fmt.Printf("  - %s (超时)\n", domain)
-					// 创建超时结果
-					results[i] = &types.DetectionResult{
-						Domain:   domain,
-						Index:    i,
-						Suitable: false,
-						Error:    fmt.Errorf("检测超时"),
-					}
-				}
-			}
+			fmt.Fprintf(bm.progress, "\n[%s] 检测超时，以下域名未完成检测：\n", time.Now().Format("15:04:05"))
+			bm.fillIncompleteResults(targets, results, types.ReasonTimeout)
+			resumeHint()
 			return results, nil
 		}
 	}
@@ -213,6 +331,52 @@ func (bm *Manager) CheckDomainsWithProgress(ctx context.Context, domains []strin
 	return results, nil
 }
 
+// collectPendingResults 收集已经完成但尚未处理的结果，不等待进行中的检测
+func (bm *Manager) collectPendingResults(resultChan <-chan *ProgressResult, results []*types.DetectionResult, record func(*ProgressResult)) {
+	for {
+		select {
+		case progressResult, ok := <-resultChan:
+			if !ok {
+				return
+			}
+			results[progressResult.Index] = progressResult.Result
+			record(progressResult)
+		default:
+			return
+		}
+	}
+}
+
+// fillIncompleteResults 为未完成检测的目标生成占位结果
+func (bm *Manager) fillIncompleteResults(targets []types.Target, results []*types.DetectionResult, code string) {
+	for i, target := range targets {
+		if results[i] == nil {
+			fmt.Fprintf(bm.progress, "  - %s (%s)\n", target.Domain, types.ReasonName(code))
+			results[i] = &types.DetectionResult{
+				Domain:   target.Domain,
+				TargetIP: target.IP,
+				Index:    i,
+				Suitable: false,
+			}
+			results[i].SetReason(code, types.ReasonName(code))
+		}
+	}
+}
+
+// savePartialReport 将部分批量报告保存为JSON文件，返回文件路径
+func (bm *Manager) savePartialReport(batchReport *types.BatchReport) (string, error) {
+	output, err := bm.formatter.FormatBatchReportJSON(batchReport)
+	if err != nil {
+		return "", err
+	}
+
+	path := fmt.Sprintf("partial_report_%s.json", batchReport.EndTime.Format("20060102_150405"))
+	if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
 // ProgressResult 进度结果
 type ProgressResult struct {
 	Index  int
@@ -234,30 +398,31 @@ func (bm *Manager) CheckDomainsStream(ctx context.Context, domains []string) (<-
 func (bm *Manager) generateBatchReport(results []*types.DetectionResult, startTime, endTime time.Time) *types.BatchReport {
 	stats := &types.Statistics{
 		TotalDomains: len(results),
+		ReasonCounts: make(map[string]int),
 	}
 
 	for _, result := range results {
-		// 区分技术错误和正常的检测结果
-		if result.Error == nil {
-			// 没有技术错误，检测成功
-			stats.SuccessfulChecks++
+		// 区分检测失败（超时、中断、出错）和正常的不适合结果（被墙、国内、不支持TLS1.3等）
+		if types.IsCheckFailure(result.ReasonCode) {
+			stats.FailedChecks++
+			stats.ErrorDomains++
 		} else {
-			// 检查是否是正常的检测结果（被墙、国内等）
-			errorMsg := result.Error.Error()
-			if strings.Contains(errorMsg, "域名被墙") || strings.Contains(errorMsg, "国内网站") {
-				// 被墙和国内网站是正常的检测结果，不算失败
-				stats.SuccessfulChecks++
-			} else {
-				// 真正的技术错误
-				stats.FailedChecks++
-			}
+			stats.SuccessfulChecks++
 		}
 
 		if result.Suitable {
 			stats.SuitableDomains++
 		}
 
-		if result.Blocked != nil && result.Blocked.IsBlocked {
+		if result.ReasonCode != "" {
+			stats.ReasonCounts[result.ReasonCode]++
+		}
+
+		if result.Cached {
+			stats.CachedResults++
+		}
+
+		if result.ReasonCode == types.ReasonBlocked {
 			stats.BlockedDomains++
 		}
 	}
@@ -268,6 +433,7 @@ func (bm *Manager) generateBatchReport(results []*types.DetectionResult, startTi
 		TotalDuration: endTime.Sub(startTime),
 		Results:       results,
 		Statistics:    stats,
+		SharedProbes:  sharedProbes(results),
 		Summary: &types.BatchSummary{
 			SuccessRate:     float64(stats.SuccessfulChecks) / float64(stats.TotalDomains),
 			SuitabilityRate: float64(stats.SuitableDomains) / float64(stats.TotalDomains),
@@ -280,6 +446,11 @@ func (bm *Manager) generateBatchReport(results []*types.DetectionResult, startTi
 func (bm *Manager) formatBatchReport(report *types.BatchReport) string {
 	var result strings.Builder
 
+	// 被中断的报告只包含已完成的部分
+	if report.Interrupted {
+		result.WriteString("\n检测被中断，以下为已完成部分的报告\n")
+	}
+
 	// 报告头部
 	result.WriteString(fmt.Sprintf(`
 批量检测报告
@@ -295,6 +466,16 @@ func (bm *Manager) formatBatchReport(report *types.BatchReport) string {
 		report.Summary.SuitabilityRate*100,
 	))
 
+	// 部分结果来自缓存时提示
+	if report.Statistics.CachedResults > 0 {
+		result.WriteString(fmt.Sprintf("其中 %d 个结果来自缓存（使用 --refresh-cache 重新检测）\n\n", report.Statistics.CachedResults))
+	}
+
+	// 经由上游代理检测时显示出口
+	if egress := proxiedEgress(report.Results); len(egress) > 0 {
+		result.WriteString(fmt.Sprintf("检测出口: %s\n\n", strings.Join(egress, ", ")))
+	}
+
 	// 分离适合和不适合的域名
 	var suitableResults []*types.DetectionResult
 	var unsuitableResults []*types.DetectionResult
@@ -305,7 +486,7 @@ func (bm *Manager) formatBatchReport(report *types.BatchReport) string {
 			suitableResults = append(suitableResults, domainResult)
 		} else {
 			// 检查是否因为状态码不自然而被排除
-			if domainResult.StatusCodeCategory == types.StatusCodeCategoryExcluded {
+			if domainResult.ReasonCode == types.ReasonBadStatus {
 				excludedResults = append(excludedResults, domainResult)
 			} else {
 				unsuitableResults = append(unsuitableResults, domainResult)
@@ -315,8 +496,8 @@ func (bm *Manager) formatBatchReport(report *types.BatchReport) string {
 
 	// 显示适合的域名表格
 	if len(suitableResults) > 0 {
-		// 按星级排序：1星在最上面，5星在最下面
-		bm.sortByRecommendationStars(suitableResults)
+		// 按评分排序：分数低的在最上面，最推荐的在最下面
+		bm.sortByScore(suitableResults)
 
 		result.WriteString("适合的域名:\n\n")
 		result.WriteString(bm.tableFormatter.FormatSuitableTable(suitableResults))
@@ -334,6 +515,57 @@ func (bm *Manager) formatBatchReport(report *types.BatchReport) string {
 		result.WriteString(bm.formatExcludedDomains(excludedResults))
 	}
 
+	// 显示跨站重定向的备选目标
+	result.WriteString(bm.formatAlternativeCandidates(report.Results))
+
+	// 显示共用探测的输入
+	result.WriteString(bm.formatSharedProbes(report.SharedProbes))
+
+	return result.String()
+}
+
+// formatAlternativeCandidates 格式化跨站重定向的备选目标
+func (bm *Manager) formatAlternativeCandidates(results []*types.DetectionResult) string {
+	// 已经检测过的域名不再作为备选
+	checked := make(map[string]bool)
+	for _, domainResult := range results {
+		checked[strings.ToLower(domainResult.Domain)] = true
+	}
+
+	var lines []string
+	seen := make(map[string]bool)
+	for _, domainResult := range results {
+		candidate := strings.ToLower(domainResult.AlternativeCandidate)
+		if candidate == "" || checked[candidate] || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		lines = append(lines, fmt.Sprintf("   - %s -> %s\n", domainResult.Domain, candidate))
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("\n跨站重定向的备选目标 (%d个，可单独检测):\n", len(lines)))
+	for _, line := range lines {
+		result.WriteString(line)
+	}
+	return result.String()
+}
+
+// formatSharedProbes 格式化共用同一次探测的输入
+func (bm *Manager) formatSharedProbes(shares []types.ProbeShare) string {
+	if len(shares) == 0 {
+		return ""
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("\n探测同一目标的输入 (%d组，相同的探测只执行一次):\n", len(shares)))
+	for _, share := range shares {
+		result.WriteString(fmt.Sprintf("   - %s <- %s\n", share.ProbedTarget, strings.Join(share.Inputs, ", ")))
+	}
 	return result.String()
 }
 
@@ -369,22 +601,6 @@ func (bm *Manager) formatExcludedDomains(excludedResults []*types.DetectionResul
 	return result.String()
 }
 
-// calculateOptimalConcurrency 计算最优并发数
-func (bm *Manager) calculateOptimalConcurrency(domainCount int) int {
-	// 更激进的并发策略，提高检测效率
-	if domainCount <= 5 {
-		return domainCount // 小批量：每个域名一个并发
-	} else if domainCount <= 20 {
-		return 6 // 中小批量：6个并发
-	} else if domainCount <= 50 {
-		return 8 // 中批量：8个并发
-	} else if domainCount <= 100 {
-		return 10 // 大批量：10个并发
-	} else {
-		return 12 // 超大批量：最多12个并发
-	}
-}
-
 // formatDuration 格式化时间显示
 func formatDuration(d time.Duration) string {
 	if d < time.Millisecond {
@@ -400,44 +616,68 @@ func formatDuration(d time.Duration) string {
 	}
 }
 
-// sortByRecommendationStars 按推荐星级排序，1星在最上面，5星在最下面
-func (bm *Manager) sortByRecommendationStars(results []*types.DetectionResult) {
-	// 使用sort.Slice进行排序
-	sort.Slice(results, func(i, j int) bool {
-		starsI := bm.calculateStars(results[i])
-		starsJ := bm.calculateStars(results[j])
-		return starsI < starsJ // 升序排列：1星在前，5星在后
+// sortByScore 按评分排序，分数低的在最上面，分数最高的在最下面
+func (bm *Manager) sortByScore(results []*types.DetectionResult) {
+	// 分数相同时保持检测顺序
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score < results[j].Score // 升序排列：最推荐的在最后，靠近命令行
 	})
 }
 
-// calculateStars 计算域名的推荐星级数量
-func (bm *Manager) calculateStars(result *types.DetectionResult) int {
-	stars := 0
-
-	// 1. TLS硬性条件检查 (TLS1.3 + X25519 + H2 + SNI匹配)
-	if result.TLS != nil && result.TLS.SupportsTLS13 &&
-		result.TLS.SupportsX25519 && result.TLS.SupportsHTTP2 &&
-		result.SNI != nil && result.SNI.SNIMatch {
-		stars++
+// proxiedEgress 结果中经由代理的出口，按出现顺序去重
+func proxiedEgress(results []*types.DetectionResult) []string {
+	var egress []string
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if result == nil || result.Egress == "" || result.Egress == types.EgressDirect || seen[result.Egress] {
+			continue
+		}
+		seen[result.Egress] = true
+		egress = append(egress, result.Egress)
 	}
+	return egress
+}
 
-	// 2. 握手时间延迟小 (<= 10ms)
-	if result.TLS != nil && result.TLS.HandshakeTime > 0 {
-		handshakeMs := int(result.TLS.HandshakeTime.Milliseconds())
-		if handshakeMs <= 10 {
-			stars++
+// sharedProbes 按探测的最终域名归组，列出对应多个输入的分组，按首次出现的顺序
+// 同一输入出现多次时标注次数
+func sharedProbes(results []*types.DetectionResult) []types.ProbeShare {
+	var order []string
+	groups := make(map[string][]string)
+	counts := make(map[string]map[string]int)
+	for _, result := range results {
+		if result == nil || result.ProbedTarget == "" {
+			continue
+		}
+		input := result.Domain
+		if result.TargetIP != "" {
+			input += " (" + result.TargetIP + ")"
 		}
-	}
 
-	// 3. 没有CDN (不使用CDN更安全)
-	if result.CDN == nil || !result.CDN.IsCDN {
-		stars++
+		target := result.ProbedTarget
+		if _, exists := counts[target]; !exists {
+			order = append(order, target)
+			counts[target] = make(map[string]int)
+		}
+		if counts[target][input] == 0 {
+			groups[target] = append(groups[target], input)
+		}
+		counts[target][input]++
 	}
 
-	// 4. TLD加分 (.com 和 .net) - 新增逻辑
-	if strings.HasSuffix(result.Domain, ".com") || strings.HasSuffix(result.Domain, ".net") {
-		stars++
+	var shares []types.ProbeShare
+	for _, target := range order {
+		inputs := groups[target]
+		total := 0
+		for i, input := range inputs {
+			count := counts[target][input]
+			total += count
+			if count > 1 {
+				inputs[i] = fmt.Sprintf("%s ×%d", input, count)
+			}
+		}
+		if total > 1 {
+			shares = append(shares, types.ProbeShare{ProbedTarget: target, Inputs: inputs})
+		}
 	}
-
-	return stars
+	return shares
 }
@@ -0,0 +1,124 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"RealityChecker/internal/core"
+	"RealityChecker/internal/report"
+	"RealityChecker/internal/types"
+)
+
+// compareSources 依次从每个源地址检测同一批目标，输出对比报告
+// 每个源地址使用独立的引擎，连接和DNS查询都从该地址发出
+func (bm *Manager) compareSources(ctx context.Context, targets []types.Target) ([]*types.DetectionResult, error) {
+	comparison := &types.SourceComparison{Sources: bm.config.Network.SourceAddresses}
+	var allResults []*types.DetectionResult
+	var sourceResults [][]*types.DetectionResult
+
+	// 各源地址的结果写入同一个进度日志，按源地址区分
+	journal, err := bm.openJournal(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if journal != nil {
+		defer journal.Close()
+	}
+
+	for _, source := range comparison.Sources {
+		fmt.Fprintf(bm.progress, "[%s] 从源地址 %s 检测 %d 个目标...\n", time.Now().Format("15:04:05"), source, len(targets))
+
+		engine, err := bm.sourceEngine(source)
+		if err != nil {
+			return allResults, fmt.Errorf("源地址 %s: %v", source, err)
+		}
+
+		startTime := time.Now()
+		results, err := bm.checkWithProgress(ctx, engine, targets, journal, source)
+		engine.Stop()
+
+		interrupted := err != nil && ctx.Err() != nil
+		if err != nil && !interrupted {
+			return allResults, err
+		}
+
+		batchReport := bm.generateBatchReport(results, startTime, time.Now())
+		batchReport.Interrupted = interrupted
+		comparison.Reports = append(comparison.Reports, batchReport)
+		sourceResults = append(sourceResults, results)
+		allResults = append(allResults, results...)
+
+		if interrupted {
+			comparison.Interrupted = true
+			break
+		}
+	}
+
+	comparison.Differences = sourceDifferences(targets, sourceResults)
+
+	if report.IsJSONOutput(bm.config) {
+		output, err := bm.formatter.FormatSourceComparisonJSON(comparison)
+		if err != nil {
+			return allResults, err
+		}
+		fmt.Fprint(report.ResultOutput(), output)
+	} else {
+		fmt.Fprintln(report.ResultOutput(), bm.tableFormatter.FormatSourceComparison(comparison))
+	}
+
+	if comparison.Interrupted {
+		return allResults, ctx.Err()
+	}
+	bm.finishJournal(journal, allResults)
+	return allResults, nil
+}
+
+// sourceEngine 创建绑定指定源地址的引擎
+// 与主引擎共用结果缓存，各源地址的结果按源地址区分
+func (bm *Manager) sourceEngine(source string) (*core.Engine, error) {
+	config := *bm.config
+	config.Network.SourceAddress = source
+	config.Network.Interface = ""
+	config.Network.SourceAddresses = nil
+
+	engine := core.NewEngineWithResults(&config, bm.engine.Results())
+	if err := engine.Start(); err != nil {
+		return nil, fmt.Errorf("启动引擎失败: %v", err)
+	}
+	return engine, nil
+}
+
+// sourceDifferences 各源地址结论不一致的目标
+// 适合与否、原因代码、星级或评分不同即视为不一致，未完成所有源地址检测的目标不参与比较
+func sourceDifferences(targets []types.Target, sourceResults [][]*types.DetectionResult) []types.Target {
+	if len(sourceResults) < 2 {
+		return nil
+	}
+
+	var differences []types.Target
+	for i, target := range targets {
+		first := sourceResults[0][i]
+		for _, results := range sourceResults[1:] {
+			other := results[i]
+			if first == nil || other == nil {
+				continue
+			}
+			if verdictDiffers(first, other) {
+				differences = append(differences, target)
+				break
+			}
+		}
+	}
+	return differences
+}
+
+// verdictDiffers 两个检测结果的结论是否不同
+// 评分按报告中显示的整数比较，握手时间的细微差异不视为不一致
+func verdictDiffers(a, b *types.DetectionResult) bool {
+	return a.Suitable != b.Suitable ||
+		a.ReasonCode != b.ReasonCode ||
+		a.Stars != b.Stars ||
+		math.Round(a.Score) != math.Round(b.Score)
+}
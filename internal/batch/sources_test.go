@@ -0,0 +1,44 @@
+package batch
+
+import (
+	"testing"
+
+	"RealityChecker/internal/types"
+)
+
+// scoredResult 带星级和评分的适合结果
+func scoredResult(stars int, score float64) *types.DetectionResult {
+	return &types.DetectionResult{Suitable: true, Stars: stars, Score: score}
+}
+
+func TestSourceDifferences(t *testing.T) {
+	targets := []types.Target{{Domain: "a.example"}}
+
+	tests := []struct {
+		name   string
+		first  *types.DetectionResult
+		other  *types.DetectionResult
+		differ bool
+	}{
+		{"结论相同", scoredResult(3, 75), scoredResult(3, 75), false},
+		{"评分的细微差异", scoredResult(3, 75.2), scoredResult(3, 74.9), false},
+		{"适合与否不同", scoredResult(3, 75), failureResult(types.ReasonBlocked), true},
+		{"不适合的原因不同", failureResult(types.ReasonBlocked), failureResult(types.ReasonNoH2), true},
+		{"星级不同", scoredResult(3, 75), scoredResult(2, 75), true},
+		{"评分不同", scoredResult(3, 75), scoredResult(3, 70), true},
+		{"有源地址未完成", scoredResult(3, 75), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			differences := sourceDifferences(targets, [][]*types.DetectionResult{{tt.first}, {tt.other}})
+			if differ := len(differences) == 1; differ != tt.differ {
+				t.Errorf("不一致 = %v，应为 %v", differ, tt.differ)
+			}
+		})
+	}
+
+	if differences := sourceDifferences(targets, [][]*types.DetectionResult{{scoredResult(3, 75)}}); differences != nil {
+		t.Errorf("只有一个源地址时不比较，得到 %v", differences)
+	}
+}
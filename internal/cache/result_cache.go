@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"RealityChecker/internal/dataset"
+	"RealityChecker/internal/types"
+	"RealityChecker/internal/version"
+)
+
+// resultCacheVersion 缓存文件格式版本
+const resultCacheVersion = 1
+
+// ResultCache 检测结果磁盘缓存
+// 缓存键由检测目标、配置指纹和数据集快照的指纹组成
+// 配置指纹覆盖影响检测结果的配置、规则和程序版本；SIGHUP重新加载更新后的数据文件后，旧的结果不再命中
+type ResultCache struct {
+	path        string
+	ttl         time.Duration
+	maxSize     int
+	fingerprint string
+
+	*resultStore
+}
+
+// resultStore 缓存条目和命中统计，由 ForConfig 派生的缓存共用
+type resultStore struct {
+	mu      sync.Mutex
+	entries map[string]*types.CachedResult
+	dirty   bool
+	hits    int64
+	misses  int64
+}
+
+// resultCacheFile 缓存文件内容
+type resultCacheFile struct {
+	Version int                            `json:"version"`
+	Entries map[string]*types.CachedResult `json:"entries"`
+}
+
+// NewResultCache 创建检测结果缓存
+func NewResultCache(config *types.Config) *ResultCache {
+	return &ResultCache{
+		path:        config.Cache.ResultPath,
+		ttl:         config.Cache.TTL,
+		maxSize:     config.Cache.MaxSize,
+		fingerprint: Fingerprint(config),
+		resultStore: &resultStore{entries: make(map[string]*types.CachedResult)},
+	}
+}
+
+// ForConfig 派生使用另一份配置的缓存，共用条目、文件和命中统计
+// 例如按源地址检测时，各源地址的结果以各自的配置指纹区分，由原缓存统一加载和保存
+func (c *ResultCache) ForConfig(config *types.Config) *ResultCache {
+	return &ResultCache{
+		path:        c.path,
+		ttl:         c.ttl,
+		maxSize:     c.maxSize,
+		fingerprint: Fingerprint(config),
+		resultStore: c.resultStore,
+	}
+}
+
+// Load 从磁盘加载缓存，文件不存在时为空缓存
+func (c *ResultCache) Load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取结果缓存失败: %v", err)
+	}
+
+	var file resultCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("结果缓存文件已损坏: %v", err)
+	}
+	if file.Version != resultCacheVersion {
+		// 格式不兼容时丢弃旧缓存
+		c.dirty = true
+		return nil
+	}
+
+	for key, entry := range file.Entries {
+		if entry != nil && entry.Result != nil && !c.expired(entry) {
+			c.entries[key] = entry
+		}
+	}
+	c.dirty = len(c.entries) != len(file.Entries)
+	return nil
+}
+
+// Save 将缓存写回磁盘，先写临时文件再替换，避免中断时损坏缓存
+func (c *ResultCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(resultCacheFile{Version: resultCacheVersion, Entries: c.entries})
+	if err != nil {
+		return fmt.Errorf("序列化结果缓存失败: %v", err)
+	}
+
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建缓存目录失败: %v", err)
+		}
+	}
+
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入结果缓存失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入结果缓存失败: %v", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// Get 获取目标使用指定数据集快照时的缓存结果，返回副本
+func (c *ResultCache) Get(target types.Target, datasets *dataset.Snapshot) (*types.DetectionResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.key(target, datasets)
+	entry, exists := c.entries[key]
+	if !exists {
+		c.misses++
+		return nil, false
+	}
+	if c.expired(entry) {
+		delete(c.entries, key)
+		c.dirty = true
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	result := *entry.Result
+	result.Cached = true
+	return &result, true
+}
+
+// Put 缓存检测结果，超时、中断或出错的结果不缓存
+// datasets 为检测时使用的数据集快照
+func (c *ResultCache) Put(target types.Target, datasets *dataset.Snapshot, result *types.DetectionResult) {
+	if result == nil || types.IsCheckFailure(result.ReasonCode) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := *result
+	stored.Cached = false
+	stored.SharedProbe = false
+	c.entries[c.key(target, datasets)] = &types.CachedResult{Result: &stored, Timestamp: time.Now()}
+	c.dirty = true
+
+	c.evict()
+}
+
+// Stats 缓存条目数和命中统计
+func (c *ResultCache) Stats() (size int, hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries), c.hits, c.misses
+}
+
+// key 缓存键
+func (c *ResultCache) key(target types.Target, datasets *dataset.Snapshot) string {
+	return c.fingerprint + "|" + datasets.Fingerprint + "|" + strings.ToLower(target.Domain) + "|" + target.IP
+}
+
+// expired 缓存条目是否过期
+func (c *ResultCache) expired(entry *types.CachedResult) bool {
+	return c.ttl > 0 && time.Since(entry.Timestamp) > c.ttl
+}
+
+// evict 淘汰过期条目，超出容量时淘汰最早写入的条目
+func (c *ResultCache) evict() {
+	if c.maxSize <= 0 || len(c.entries) <= c.maxSize {
+		return
+	}
+
+	for key, entry := range c.entries {
+		if c.expired(entry) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) <= c.maxSize {
+		return
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].Timestamp.Before(c.entries[keys[j]].Timestamp)
+	})
+	for _, key := range keys[:len(keys)-c.maxSize] {
+		delete(c.entries, key)
+	}
+}
+
+// Fingerprint 计算影响检测结果的配置的指纹
+// 配置、规则、插件或程序版本变化后，旧的缓存结果不再命中；数据文件的变化由数据集快照的指纹反映
+func Fingerprint(config *types.Config) string {
+	hash := sha256.New()
+
+	fmt.Fprintf(hash, "version=%s/%s\n", version.GetVersion(), version.GetCommit())
+
+	// 影响检测结果的配置
+	settings, _ := yaml.Marshal(struct {
+		Network      types.NetworkConfig   `yaml:"network"`
+		TLS          types.TLSConfig       `yaml:"tls"`
+		CheckTimeout time.Duration         `yaml:"check_timeout"`
+		Detection    types.DetectionConfig `yaml:"detection"`
+		Plugins      []types.PluginConfig  `yaml:"plugins"`
+		Rules        []types.RuleConfig    `yaml:"rules"`
+	}{
+		Network:      config.Network,
+		TLS:          config.TLS,
+		CheckTimeout: config.Concurrency.CheckTimeout,
+		Detection:    config.Detection,
+		Plugins:      config.Plugins,
+		Rules:        config.Rules,
+	})
+	hash.Write(settings)
+
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
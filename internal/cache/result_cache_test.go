@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"RealityChecker/internal/dataset"
+	"RealityChecker/internal/types"
+)
+
+// newTestConfig 缓存文件位于临时目录的配置
+func newTestConfig(t *testing.T, ttl time.Duration, maxSize int) *types.Config {
+	t.Helper()
+	return &types.Config{
+		Cache: types.CacheConfig{
+			ResultEnabled: true,
+			TTL:           ttl,
+			MaxSize:       maxSize,
+			ResultPath:    filepath.Join(t.TempDir(), "results.json"),
+		},
+	}
+}
+
+// newTestResult 检测结果
+func newTestResult(domain string) *types.DetectionResult {
+	return &types.DetectionResult{Domain: domain, Suitable: true}
+}
+
+func TestKeyComposition(t *testing.T) {
+	datasets := &dataset.Snapshot{Fingerprint: "data-a"}
+	target := types.Target{Domain: "Example.com", IP: "192.0.2.1"}
+
+	tests := []struct {
+		name     string
+		config   func(*types.Config) // 修改读取时使用的配置
+		datasets *dataset.Snapshot
+		target   types.Target
+		wantHit  bool
+	}{
+		{"相同的配置和数据集", nil, datasets, target, true},
+		{"域名不区分大小写", nil, datasets, types.Target{Domain: "example.COM", IP: "192.0.2.1"}, true},
+		{"重新加载后数据未变化", nil, &dataset.Snapshot{Fingerprint: "data-a"}, target, true},
+		{"不检测的配置不影响", func(c *types.Config) { c.Output.Format = "json" }, datasets, target, true},
+		{"不同的扫描IP", nil, datasets, types.Target{Domain: "example.com", IP: "192.0.2.2"}, false},
+		{"没有扫描IP", nil, datasets, types.Target{Domain: "example.com"}, false},
+		{"数据集指纹变化", nil, &dataset.Snapshot{Fingerprint: "data-b"}, target, false},
+		{"检测配置变化", func(c *types.Config) { c.Detection.FullDiagnosis = true }, datasets, target, false},
+		{"规则变化", func(c *types.Config) { c.Rules = []types.RuleConfig{{Name: "custom"}} }, datasets, target, false},
+		{"源地址变化", func(c *types.Config) { c.Network.SourceAddress = "198.51.100.1" }, datasets, target, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig(t, time.Hour, 0)
+			cache := NewResultCache(config)
+			cache.Put(target, datasets, newTestResult("example.com"))
+
+			reader := cache
+			if tt.config != nil {
+				changed := *config
+				tt.config(&changed)
+				reader = cache.ForConfig(&changed)
+			}
+
+			result, hit := reader.Get(tt.target, tt.datasets)
+			if hit != tt.wantHit {
+				t.Fatalf("命中 = %v，应为 %v", hit, tt.wantHit)
+			}
+			if hit && (!result.Cached || result.Domain != "example.com") {
+				t.Errorf("缓存结果 = %+v，应为标记为缓存的 example.com", result)
+			}
+		})
+	}
+}
+
+func TestForConfigSharesStore(t *testing.T) {
+	config := newTestConfig(t, time.Hour, 0)
+	cache := NewResultCache(config)
+	datasets := &dataset.Snapshot{Fingerprint: "data-a"}
+	target := types.Target{Domain: "example.com"}
+
+	source := *config
+	source.Network.SourceAddress = "198.51.100.1"
+	derived := cache.ForConfig(&source)
+	derived.Put(target, datasets, newTestResult("example.com"))
+
+	if _, hit := derived.Get(target, datasets); !hit {
+		t.Error("派生的缓存应命中自己写入的结果")
+	}
+	if _, hit := cache.Get(target, datasets); hit {
+		t.Error("原缓存不应命中其他源地址的结果")
+	}
+	if size, hits, misses := cache.Stats(); size != 1 || hits != 1 || misses != 1 {
+		t.Errorf("共用的统计 = %d/%d/%d，应为 1/1/1", size, hits, misses)
+	}
+}
+
+func TestPutSkipsCheckFailures(t *testing.T) {
+	cache := NewResultCache(newTestConfig(t, time.Hour, 0))
+	datasets := &dataset.Snapshot{Fingerprint: "data-a"}
+	target := types.Target{Domain: "example.com"}
+
+	result := newTestResult("example.com")
+	result.SetReason(types.ReasonTimeout, types.ReasonName(types.ReasonTimeout))
+	cache.Put(target, datasets, result)
+	cache.Put(target, datasets, nil)
+
+	if size, _, _ := cache.Stats(); size != 0 {
+		t.Errorf("超时和空结果不应缓存，缓存了 %d 条", size)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	datasets := &dataset.Snapshot{Fingerprint: "data-a"}
+	fresh := types.Target{Domain: "fresh.example"}
+	stale := types.Target{Domain: "stale.example"}
+
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		age       time.Duration // stale 条目的写入时间距今
+		wantStale bool          // stale 条目是否仍然有效
+	}{
+		{"未过期", time.Hour, 30 * time.Minute, true},
+		{"已过期", time.Hour, 2 * time.Hour, false},
+		{"TTL为0时不过期", 0, 24 * time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/Get", func(t *testing.T) {
+			cache := NewResultCache(newTestConfig(t, tt.ttl, 0))
+			cache.Put(fresh, datasets, newTestResult(fresh.Domain))
+			cache.Put(stale, datasets, newTestResult(stale.Domain))
+			cache.entries[cache.key(stale, datasets)].Timestamp = time.Now().Add(-tt.age)
+
+			if _, hit := cache.Get(stale, datasets); hit != tt.wantStale {
+				t.Errorf("命中 = %v，应为 %v", hit, tt.wantStale)
+			}
+			if _, hit := cache.Get(fresh, datasets); !hit {
+				t.Error("未过期的条目应命中")
+			}
+			wantSize := 1
+			if tt.wantStale {
+				wantSize = 2
+			}
+			if size, _, _ := cache.Stats(); size != wantSize {
+				t.Errorf("条目数 = %d，过期的条目应在读取时删除", size)
+			}
+		})
+
+		t.Run(tt.name+"/Load", func(t *testing.T) {
+			config := newTestConfig(t, tt.ttl, 0)
+			writer := NewResultCache(config)
+			writer.Put(fresh, datasets, newTestResult(fresh.Domain))
+			writer.Put(stale, datasets, newTestResult(stale.Domain))
+			writer.entries[writer.key(stale, datasets)].Timestamp = time.Now().Add(-tt.age)
+			if err := writer.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			cache := NewResultCache(config)
+			if err := cache.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if _, hit := cache.Get(stale, datasets); hit != tt.wantStale {
+				t.Errorf("加载后命中 = %v，应为 %v", hit, tt.wantStale)
+			}
+			if _, hit := cache.Get(fresh, datasets); !hit {
+				t.Error("加载后未过期的条目应命中")
+			}
+			// 加载时丢弃了过期条目，需要写回
+			if cache.dirty != !tt.wantStale {
+				t.Errorf("dirty = %v，应为 %v", cache.dirty, !tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestEvictionOrder(t *testing.T) {
+	datasets := &dataset.Snapshot{Fingerprint: "data-a"}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		ages    map[string]time.Duration // 已有条目的写入时间距今
+		maxSize int
+		want    []string // 写入 new.example 后保留的条目
+	}{
+		{
+			name:    "未超出容量时不淘汰",
+			ttl:     time.Hour,
+			ages:    map[string]time.Duration{"a.example": 3 * time.Minute, "b.example": 2 * time.Minute},
+			maxSize: 3,
+			want:    []string{"a.example", "b.example", "new.example"},
+		},
+		{
+			name:    "超出容量时淘汰最早写入的条目",
+			ttl:     time.Hour,
+			ages:    map[string]time.Duration{"a.example": 2 * time.Minute, "b.example": 3 * time.Minute, "c.example": time.Minute},
+			maxSize: 2,
+			want:    []string{"c.example", "new.example"},
+		},
+		{
+			name:    "先淘汰过期条目",
+			ttl:     time.Hour,
+			ages:    map[string]time.Duration{"a.example": 3 * time.Minute, "b.example": 2 * time.Hour, "c.example": time.Minute},
+			maxSize: 3,
+			want:    []string{"a.example", "c.example", "new.example"},
+		},
+		{
+			name:    "容量为0时不限制",
+			ttl:     time.Hour,
+			ages:    map[string]time.Duration{"a.example": 3 * time.Minute, "b.example": 2 * time.Minute},
+			maxSize: 0,
+			want:    []string{"a.example", "b.example", "new.example"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewResultCache(newTestConfig(t, tt.ttl, tt.maxSize))
+			for domain, age := range tt.ages {
+				target := types.Target{Domain: domain}
+				cache.entries[cache.key(target, datasets)] = &types.CachedResult{Result: newTestResult(domain), Timestamp: now.Add(-age)}
+			}
+
+			cache.Put(types.Target{Domain: "new.example"}, datasets, newTestResult("new.example"))
+
+			if len(cache.entries) != len(tt.want) {
+				t.Errorf("保留 %d 条，应为 %d 条", len(cache.entries), len(tt.want))
+			}
+			for _, domain := range tt.want {
+				if _, exists := cache.entries[cache.key(types.Target{Domain: domain}, datasets)]; !exists {
+					t.Errorf("%s 不应被淘汰", domain)
+				}
+			}
+		})
+	}
+}
+
+func TestSaveAtomic(t *testing.T) {
+	datasets := &dataset.Snapshot{Fingerprint: "data-a"}
+	target := types.Target{Domain: "example.com"}
+	config := newTestConfig(t, time.Hour, 0)
+	config.Cache.ResultPath = filepath.Join(t.TempDir(), "nested", "results.json")
+
+	cache := NewResultCache(config)
+	cache.Put(target, datasets, newTestResult("example.com"))
+	if err := cache.Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if _, err := os.Stat(config.Cache.ResultPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("保存后不应留下临时文件: %v", err)
+	}
+	saved, err := os.ReadFile(config.Cache.ResultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file resultCacheFile
+	if err := json.Unmarshal(saved, &file); err != nil || file.Version != resultCacheVersion || len(file.Entries) != 1 {
+		t.Fatalf("缓存文件内容不正确: %v %s", err, saved)
+	}
+
+	// 写入临时文件失败时保留原文件
+	cache.Put(types.Target{Domain: "other.example"}, datasets, newTestResult("other.example"))
+	if err := os.Mkdir(config.Cache.ResultPath+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err == nil {
+		t.Fatal("临时文件无法写入时应返回错误")
+	}
+	if current, err := os.ReadFile(config.Cache.ResultPath); err != nil || string(current) != string(saved) {
+		t.Errorf("保存失败时原文件应保持不变: %v", err)
+	}
+	if !cache.dirty {
+		t.Error("保存失败后仍需写回")
+	}
+
+	// 损坏的缓存文件返回错误，不影响之后的检测
+	if err := os.WriteFile(config.Cache.ResultPath, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewResultCache(config).Load(); err == nil {
+		t.Error("损坏的缓存文件应返回错误")
+	}
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"RealityChecker/internal/ui"
@@ -22,7 +25,7 @@ func (r *RootCmd) executeBatch(domainsStr string) {
 
 	// 显示重复域名警告
 	if len(duplicateDomains) > 0 {
-		ui.PrintTimestampedMessage("警告：发现 %d 个重复域名，已去重：", len(duplicateDomains))
+		ui.FprintTimestampedMessage(r.progress, "警告：发现 %d 个重复域名，已去重：", len(duplicateDomains))
 
 		// 只显示前5个重复域名，避免显示过多
 		displayCount := 5
@@ -31,20 +34,20 @@ func (r *RootCmd) executeBatch(domainsStr string) {
 		}
 
 		for i := 0; i < displayCount; i++ {
-			fmt.Printf("   - %s\n", duplicateDomains[i])
+			fmt.Fprintf(r.progress, "   - %s\n", duplicateDomains[i])
 		}
 
 		// 如果还有更多重复域名，显示省略提示
 		if len(duplicateDomains) > displayCount {
-			fmt.Printf("   ... 还有 %d 个重复域名\n", len(duplicateDomains)-displayCount)
+			fmt.Fprintf(r.progress, "   ... 还有 %d 个重复域名\n", len(duplicateDomains)-displayCount)
 		}
 
-		fmt.Println()
+		fmt.Fprintln(r.progress)
 	}
 
 	// 显示无效域名警告
 	if len(invalidDomains) > 0 {
-		ui.PrintTimestampedMessage("警告：发现 %d 个无效域名，已跳过：", len(invalidDomains))
+		ui.FprintTimestampedMessage(r.progress, "警告：发现 %d 个无效域名，已跳过：", len(invalidDomains))
 
 		// 只显示前5个无效域名，避免显示过多
 		displayCount := 5
@@ -53,22 +56,26 @@ func (r *RootCmd) executeBatch(domainsStr string) {
 		}
 
 		for i := 0; i < displayCount; i++ {
-			fmt.Printf("   - %s\n", invalidDomains[i])
+			fmt.Fprintf(r.progress, "   - %s\n", invalidDomains[i])
 		}
 
 		// 如果还有更多无效域名，显示省略提示
 		if len(invalidDomains) > displayCount {
-			fmt.Printf("   ... 还有 %d 个无效域名\n", len(invalidDomains)-displayCount)
+			fmt.Fprintf(r.progress, "   ... 还有 %d 个无效域名\n", len(invalidDomains)-displayCount)
 		}
 
-		fmt.Println()
+		fmt.Fprintln(r.progress)
 	}
 
-	ui.PrintTimestampedMessage("开始批量检测 %d 个域名...", len(domains))
+	ui.FprintTimestampedMessage(r.progress, "开始批量检测 %d 个域名...", len(domains))
 
 	_, err := r.batchManager.CheckDomains(r.ctx, domains)
 	if err != nil {
-		fmt.Printf("批量检测失败: %v\n", err)
+		if errors.Is(err, context.Canceled) {
+			// 部分报告已由批量管理器输出
+			return
+		}
+		fmt.Fprintf(os.Stderr, "批量检测失败: %v\n", err)
 		return
 	}
 
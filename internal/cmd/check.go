@@ -2,11 +2,10 @@ package cmd
 
 import (
 	"fmt"
-	"net"
+	"os"
 	"regexp"
 	"strings"
 
-	"RealityChecker/internal/config"
 	"RealityChecker/internal/report"
 	"RealityChecker/internal/ui"
 )
@@ -28,17 +27,25 @@ func (r *RootCmd) executeCheck(domain string) {
 		return
 	}
 
-	ui.PrintTimestampedMessage("开始检测域名: %s", domain)
+	ui.FprintTimestampedMessage(r.progress, "开始检测域名: %s", domain)
 
 	result, err := r.engine.CheckDomain(r.ctx, domain)
 	if err != nil {
-		fmt.Printf("检测失败: %v\n", err)
+		fmt.Fprintf(os.Stderr, "检测失败: %v\n", err)
 		return
 	}
 
 	// 使用格式化器输出结果
-	cfg, _ := config.LoadConfig("")
-	formatter := report.NewFormatter(cfg)
+	formatter := report.NewFormatter(r.config)
+	if report.IsJSONOutput(r.config) {
+		output, err := formatter.FormatSingleResultJSON(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "输出结果失败: %v\n", err)
+			return
+		}
+		fmt.Fprint(report.ResultOutput(), output)
+		return
+	}
 	fmt.Printf("\n%s", formatter.FormatSingleResult(result))
 
 	// 显示广告
@@ -73,13 +80,6 @@ func isValidDomain(domain string) bool {
 		return false
 	}
 
-	// 尝试解析域名（不进行实际DNS查询）
-	_, err := net.LookupHost(domain)
-	if err != nil {
-		// 即使DNS解析失败，只要格式正确就认为是有效的
-		// 因为可能是网络问题或域名确实不存在
-		return true
-	}
-
+	// 不在此处解析域名，DNS查询统一由检测阶段经共享缓存完成
 	return true
 }
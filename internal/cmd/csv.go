@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
-	"time"
 
+	"RealityChecker/internal/types"
 	"RealityChecker/internal/ui"
 )
 
@@ -57,9 +59,9 @@ func (r *RootCmd) executeCSV(csvFile string) {
 		return
 	}
 
-	// 提取域名（从CERT_DOMAIN列）
-	domains := extractDomainsFromCSV(records)
-	if len(domains) == 0 {
+	// 提取域名（从CERT_DOMAIN列）及扫描IP（从IP列）
+	targets := extractTargetsFromCSV(records)
+	if len(targets) == 0 {
 		ui.PrintErrorWithDetails(
 			"错误：未找到有效的域名",
 			"请使用 RealiTLScanner 工具扫描，得到 CSV 文件",
@@ -70,12 +72,16 @@ func (r *RootCmd) executeCSV(csvFile string) {
 		return
 	}
 
-	fmt.Printf("[%s] 从CSV文件提取到 %d 个域名\n", time.Now().Format("15:04:05"), len(domains))
-	ui.PrintTimestampedMessage("开始批量检测...")
+	ui.FprintTimestampedMessage(r.progress, "从CSV文件提取到 %d 个域名", len(targets))
+	ui.FprintTimestampedMessage(r.progress, "开始批量检测...")
 
-	_, err = r.batchManager.CheckDomains(r.ctx, domains)
+	_, err = r.batchManager.CheckTargets(r.ctx, targets)
 	if err != nil {
-		fmt.Printf("批量检测失败: %v\n", err)
+		if errors.Is(err, context.Canceled) {
+			// 部分报告已由批量管理器输出
+			return
+		}
+		fmt.Fprintf(os.Stderr, "批量检测失败: %v\n", err)
 		return
 	}
 
@@ -83,9 +89,9 @@ func (r *RootCmd) executeCSV(csvFile string) {
 	ui.PrintAdvertisement()
 }
 
-// extractDomainsFromCSV 从CSV记录中提取域名
-func extractDomainsFromCSV(records [][]string) []string {
-	var domains []string
+// extractTargetsFromCSV 从CSV记录中提取检测目标（域名及扫描IP）
+func extractTargetsFromCSV(records [][]string) []types.Target {
+	var targets []types.Target
 	domainSet := make(map[string]bool) // 用于去重
 
 	// 跳过标题行，从第二行开始处理
@@ -107,14 +113,17 @@ func extractDomainsFromCSV(records [][]string) []string {
 			continue
 		}
 
-		// 去重
+		// 去重（同一域名保留第一次出现的扫描IP）
 		if !domainSet[certDomain] {
-			domains = append(domains, certDomain)
+			targets = append(targets, types.Target{
+				Domain: certDomain,
+				IP:     strings.TrimSpace(records[i][0]), // IP列
+			})
 			domainSet[certDomain] = true
 		}
 	}
 
-	return domains
+	return targets
 }
 
 // shouldExcludeDomain 判断是否应该排除某个域名
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strings"
@@ -11,6 +12,9 @@ import (
 	"RealityChecker/internal/batch"
 	"RealityChecker/internal/config"
 	"RealityChecker/internal/core"
+	"RealityChecker/internal/dataset"
+	"RealityChecker/internal/report"
+	"RealityChecker/internal/types"
 	"RealityChecker/internal/ui"
 	"RealityChecker/internal/version"
 )
@@ -19,18 +23,125 @@ import (
 type RootCmd struct {
 	engine       *core.Engine
 	batchManager *batch.Manager
+	config       *types.Config
+	args         []string  // 去除选项后的命令参数
+	progress     io.Writer // 进度和提示信息的输出位置
 	ctx          context.Context
 	cancel       context.CancelFunc
 }
 
-// NewRootCmd 创建根命令
-func NewRootCmd() (*RootCmd, error) {
+// cliOptions 命令行选项
+type cliOptions struct {
+	json         bool     // --json: 以JSON格式输出结果
+	full         bool     // --full: 完整诊断，不早期退出
+	noCache      bool     // --no-cache: 不读取也不写入结果缓存
+	refreshCache bool     // --refresh-cache: 忽略已缓存的结果，重新检测并更新缓存
+	sources      []string // --source=<ip>[,<ip>...]: 绑定源地址，多个时批量检测逐个对比
+	resume       string   // --resume <journal>: 从进度日志继续被中断的批量检测
+}
+
+// parseOptions 从参数中提取选项，返回其余参数
+func parseOptions(args []string) ([]string, cliOptions) {
+	var options cliOptions
+	var remaining []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if value, ok := strings.CutPrefix(arg, "--source="); ok {
+			for _, source := range strings.Split(value, ",") {
+				if source = strings.TrimSpace(source); source != "" {
+					options.sources = append(options.sources, source)
+				}
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(arg, "--resume="); ok {
+			options.resume = value
+			continue
+		}
+
+		switch arg {
+		case "--json":
+			options.json = true
+		case "--full":
+			options.full = true
+		case "--no-cache":
+			options.noCache = true
+		case "--refresh-cache":
+			options.refreshCache = true
+		case "--resume":
+			if i+1 < len(args) {
+				i++
+				options.resume = args[i]
+			}
+		default:
+			remaining = append(remaining, arg)
+		}
+	}
+	return remaining, options
+}
+
+// apply 将命令行选项应用到配置
+func (o cliOptions) apply(cfg *types.Config) {
+	if o.json {
+		cfg.Output.Format = report.FormatJSON
+	}
+	if o.full {
+		cfg.Detection.FullDiagnosis = true
+	}
+	if o.noCache {
+		cfg.Cache.ResultEnabled = false
+	}
+	if o.refreshCache {
+		cfg.Cache.Refresh = true
+	}
+	if o.resume != "" {
+		cfg.Batch.Resume = o.resume
+	}
+	switch len(o.sources) {
+	case 0:
+	case 1:
+		cfg.Network.SourceAddress = o.sources[0]
+		cfg.Network.SourceAddresses = nil
+	default:
+		cfg.Network.SourceAddresses = o.sources
+	}
+}
+
+// ProgressOutput 横幅、进度和提示信息的输出位置
+// 命令行或配置文件要求JSON输出时为标准错误，标准输出只保留检测结果
+func ProgressOutput(cfg *types.Config) io.Writer {
+	_, options := parseOptions(os.Args[1:])
+	return report.ProgressOutput(options.json || report.IsJSONOutput(cfg))
+}
+
+// printDatasets 打印数据集的条目数和加载错误
+// 写到标准错误，不与检测结果（例如JSON输出）混在一起；SIGHUP可能在输出结果的过程中到达
+func printDatasets(title string, snapshot *dataset.Snapshot) {
+	ui.FprintTimestampedMessage(os.Stderr, "%s: %s", title, snapshot.Summary())
+	for _, status := range snapshot.Errors() {
+		if status.Stale {
+			ui.FprintTimestampedMessage(os.Stderr, "重新加载 %s 失败，继续使用之前的数据: %s", status.Path, status.Error)
+		} else {
+			ui.FprintTimestampedMessage(os.Stderr, "加载 %s 失败: %s", status.Path, status.Error)
+		}
+	}
+}
+
+// NewRootCmd 创建根命令，进度和提示信息写到 progress
+func NewRootCmd(progress io.Writer) (*RootCmd, error) {
 	// 加载配置
 	cfg, err := config.LoadConfig("")
 	if err != nil {
 		return nil, fmt.Errorf("加载配置失败: %v", err)
 	}
 
+	// 命令行选项优先于配置文件
+	args, options := parseOptions(os.Args[1:])
+	options.apply(cfg)
+
+	// 加载数据集，所有检测共用
+	printDatasets("数据集", dataset.Default().Snapshot())
+
 	// 创建引擎
 	engine := core.NewEngine(cfg)
 	if err := engine.Start(); err != nil {
@@ -38,7 +149,7 @@ func NewRootCmd() (*RootCmd, error) {
 	}
 
 	// 创建批量管理器（共享引擎）
-	batchManager := batch.NewManagerWithEngine(engine, cfg)
+	batchManager := batch.NewManagerWithEngine(engine, cfg, progress)
 	if err := batchManager.Start(); err != nil {
 		engine.Stop()
 		return nil, fmt.Errorf("启动批量管理器失败: %v", err)
@@ -53,9 +164,21 @@ func NewRootCmd() (*RootCmd, error) {
 		cancel()
 	}()
 
+	// 收到SIGHUP时重新加载数据集，进行中的检测不受影响
+	go func() {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		for range hupChan {
+			printDatasets("已重新加载数据集", dataset.Default().Reload())
+		}
+	}()
+
 	return &RootCmd{
 		engine:       engine,
 		batchManager: batchManager,
+		config:       cfg,
+		args:         args,
+		progress:     progress,
 		ctx:          ctx,
 		cancel:       cancel,
 	}, nil
@@ -65,14 +188,14 @@ func NewRootCmd() (*RootCmd, error) {
 func (r *RootCmd) Execute() {
 	defer r.cleanup()
 
-	if len(os.Args) < 2 {
+	if len(r.args) < 1 {
 		ui.PrintUsage()
 		os.Exit(1)
 	}
 
-	switch os.Args[1] {
+	switch r.args[0] {
 	case "check":
-		if len(os.Args) < 3 {
+		if len(r.args) < 2 {
 			ui.PrintErrorWithDetails(
 				"错误：缺少域名参数",
 				"用法: reality-checker check <domain>",
@@ -80,9 +203,9 @@ func (r *RootCmd) Execute() {
 			)
 			os.Exit(1)
 		}
-		r.executeCheck(os.Args[2])
+		r.executeCheck(r.args[1])
 	case "batch":
-		if len(os.Args) < 3 {
+		if len(r.args) < 2 {
 			ui.PrintErrorWithDetails(
 				"错误：缺少域名参数",
 				"用法: reality-checker batch <domain1> <domain2> <domain3> ...",
@@ -91,10 +214,10 @@ func (r *RootCmd) Execute() {
 			os.Exit(1)
 		}
 		// 将所有参数（除了命令名）合并为空格分隔的字符串
-		domainsStr := strings.Join(os.Args[2:], " ")
+		domainsStr := strings.Join(r.args[1:], " ")
 		r.executeBatch(domainsStr)
 	case "csv":
-		if len(os.Args) < 3 {
+		if len(r.args) < 2 {
 			ui.PrintErrorWithDetails(
 				"错误：缺少CSV文件参数",
 				"用法: reality-checker csv <csv_file>",
@@ -102,12 +225,12 @@ func (r *RootCmd) Execute() {
 			)
 			os.Exit(1)
 		}
-		r.executeCSV(os.Args[2])
+		r.executeCSV(r.args[1])
 	case "version", "-v", "--version":
 		r.showVersion()
 	default:
 		ui.PrintErrorWithDetails(
-			fmt.Sprintf("错误：未知命令 '%s'", os.Args[1]),
+			fmt.Sprintf("错误：未知命令 '%s'", r.args[0]),
 			"可用命令: check, batch, csv, version",
 		)
 		os.Exit(1)
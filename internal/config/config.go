@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"RealityChecker/internal/types"
@@ -77,9 +78,28 @@ func mergeConfig(defaultConfig *types.Config, fileConfig *types.Config) {
 	if fileConfig.Network.Retries >= 0 {
 		defaultConfig.Network.Retries = fileConfig.Network.Retries
 	}
+	if fileConfig.Network.RetryBackoff > 0 {
+		defaultConfig.Network.RetryBackoff = fileConfig.Network.RetryBackoff
+	}
 	if len(fileConfig.Network.DNSServers) > 0 {
 		defaultConfig.Network.DNSServers = fileConfig.Network.DNSServers
 	}
+	if fileConfig.Network.UseDNSServers {
+		defaultConfig.Network.UseDNSServers = true
+	}
+	if fileConfig.Network.Proxy.Enabled() {
+		defaultConfig.Network.Proxy = fileConfig.Network.Proxy
+	}
+	if fileConfig.Network.SourceAddress != "" {
+		defaultConfig.Network.SourceAddress = fileConfig.Network.SourceAddress
+	}
+	if fileConfig.Network.Interface != "" {
+		defaultConfig.Network.Interface = fileConfig.Network.Interface
+	}
+	if len(fileConfig.Network.SourceAddresses) > 0 {
+		defaultConfig.Network.SourceAddresses = fileConfig.Network.SourceAddresses
+	}
+	mergeRateLimit(&defaultConfig.Network.RateLimit, &fileConfig.Network.RateLimit)
 
 	// TLS配置
 	if fileConfig.TLS.MinVersion > 0 {
@@ -119,6 +139,9 @@ func mergeConfig(defaultConfig *types.Config, fileConfig *types.Config) {
 	if fileConfig.Cache.MaxSize > 0 {
 		defaultConfig.Cache.MaxSize = fileConfig.Cache.MaxSize
 	}
+	if fileConfig.Cache.ResultPath != "" {
+		defaultConfig.Cache.ResultPath = fileConfig.Cache.ResultPath
+	}
 
 	// 批量配置
 	defaultConfig.Batch.StreamOutput = fileConfig.Batch.StreamOutput
@@ -129,15 +152,73 @@ func mergeConfig(defaultConfig *types.Config, fileConfig *types.Config) {
 	if fileConfig.Batch.Timeout > 0 {
 		defaultConfig.Batch.Timeout = fileConfig.Batch.Timeout
 	}
+	if fileConfig.Batch.JournalDir != "" {
+		defaultConfig.Batch.JournalDir = fileConfig.Batch.JournalDir
+	}
+
+	// 检测配置
+	if fileConfig.Detection.MaxRedirects > 0 {
+		defaultConfig.Detection.MaxRedirects = fileConfig.Detection.MaxRedirects
+	}
+	defaultConfig.Detection.DisqualifyCrossSiteRedirect = fileConfig.Detection.DisqualifyCrossSiteRedirect
+	defaultConfig.Detection.AllowProxyFront = fileConfig.Detection.AllowProxyFront
+	defaultConfig.Detection.FullDiagnosis = fileConfig.Detection.FullDiagnosis
+	if fileConfig.Detection.CertHandshakes > 0 {
+		defaultConfig.Detection.CertHandshakes = fileConfig.Detection.CertHandshakes
+	}
+	if len(fileConfig.Detection.StageTimeouts) > 0 {
+		defaultConfig.Detection.StageTimeouts = fileConfig.Detection.StageTimeouts
+	}
+
+	// 插件配置
+	if len(fileConfig.Plugins) > 0 {
+		defaultConfig.Plugins = fileConfig.Plugins
+	}
+
+	// 适合性规则（配置后整体替换内置规则）
+	if len(fileConfig.Rules) > 0 {
+		defaultConfig.Rules = fileConfig.Rules
+	}
+}
+
+// mergeRateLimit 合并连接限制配置，未设置的项保留默认值
+func mergeRateLimit(defaultLimit, fileLimit *types.RateLimitConfig) {
+	if fileLimit.PerIPConcurrent != 0 {
+		defaultLimit.PerIPConcurrent = fileLimit.PerIPConcurrent
+	}
+	if fileLimit.PerIPRate != 0 {
+		defaultLimit.PerIPRate = fileLimit.PerIPRate
+	}
+	if fileLimit.PrefixLength > 0 {
+		defaultLimit.PrefixLength = fileLimit.PrefixLength
+	}
+	if fileLimit.PrefixLengthV6 > 0 {
+		defaultLimit.PrefixLengthV6 = fileLimit.PrefixLengthV6
+	}
+	if fileLimit.PerPrefixConcurrent != 0 {
+		defaultLimit.PerPrefixConcurrent = fileLimit.PerPrefixConcurrent
+	}
+	if fileLimit.PerPrefixRate != 0 {
+		defaultLimit.PerPrefixRate = fileLimit.PerPrefixRate
+	}
 }
 
 // getDefaultConfig 获取默认配置
 func getDefaultConfig() *types.Config {
 	return &types.Config{
 		Network: types.NetworkConfig{
-			Timeout:    3 * time.Second, // 减少到3秒
-			Retries:    1,
-			DNSServers: []string{"8.8.8.8", "1.1.1.1"},
+			Timeout:      3 * time.Second, // 减少到3秒
+			Retries:      1,
+			RetryBackoff: 300 * time.Millisecond,
+			DNSServers:   []string{"8.8.8.8", "1.1.1.1"},
+			RateLimit: types.RateLimitConfig{
+				PerIPConcurrent:     4, // 约为单个域名检测同时使用的连接数
+				PerIPRate:           5,
+				PrefixLength:        24,
+				PrefixLengthV6:      64,
+				PerPrefixConcurrent: 16,
+				PerPrefixRate:       20,
+			},
 		},
 		TLS: types.TLSConfig{
 			MinVersion: 771, // TLS 1.2
@@ -146,7 +227,7 @@ func getDefaultConfig() *types.Config {
 		Concurrency: types.ConcurrencyConfig{
 			MaxConcurrent: 8,
 			MinConcurrent: 1,
-			CheckTimeout:  3 * time.Second, // 减少到3秒
+			CheckTimeout:  30 * time.Second, // 单个域名所有检测阶段的总超时
 			CacheTTL:      5 * time.Minute,
 		},
 		Output: types.OutputConfig{
@@ -157,14 +238,23 @@ func getDefaultConfig() *types.Config {
 		Cache: types.CacheConfig{
 			DNSEnabled:    true,
 			ResultEnabled: true,
-			TTL:           5 * time.Minute,
-			MaxSize:       1000,
+			TTL:           time.Hour, // 检测结果的有效期
+			MaxSize:       10000,     // 足够容纳一次大型CSV扫描
+			ResultPath:    "cache/results.json",
 		},
 		Batch: types.BatchConfig{
 			StreamOutput: false,
 			ProgressBar:  true,
 			ReportFormat: "text",
-			Timeout:      30 * time.Second,
+			Timeout:      20 * time.Minute, // 批量检测总超时
+			JournalDir:   "journal",
+		},
+		Detection: types.DetectionConfig{
+			MaxRedirects:                5,
+			DisqualifyCrossSiteRedirect: false,
+			AllowProxyFront:             false,
+			FullDiagnosis:               false,
+			CertHandshakes:              1,
 		},
 	}
 }
@@ -178,9 +268,24 @@ func validateAndSetDefaults(config *types.Config) {
 	if config.Network.Retries < 0 {
 		config.Network.Retries = 3
 	}
+	if config.Network.RetryBackoff <= 0 {
+		config.Network.RetryBackoff = 300 * time.Millisecond
+	}
 	if len(config.Network.DNSServers) == 0 {
 		config.Network.DNSServers = []string{"8.8.8.8", "1.1.1.1"}
 	}
+	config.Network.Proxy.Type = strings.ToLower(config.Network.Proxy.Type)
+	if config.Network.RateLimit.PrefixLength <= 0 || config.Network.RateLimit.PrefixLength > 32 {
+		config.Network.RateLimit.PrefixLength = 24
+	}
+	if config.Network.RateLimit.PrefixLengthV6 <= 0 || config.Network.RateLimit.PrefixLengthV6 > 128 {
+		config.Network.RateLimit.PrefixLengthV6 = 64
+	}
+	if len(config.Network.SourceAddresses) == 1 {
+		// 只有一个源地址时等同于绑定该地址
+		config.Network.SourceAddress = config.Network.SourceAddresses[0]
+		config.Network.SourceAddresses = nil
+	}
 
 	// TLS配置验证
 	if config.TLS.MinVersion == 0 {
@@ -194,6 +299,12 @@ func validateAndSetDefaults(config *types.Config) {
 	if config.Concurrency.MaxConcurrent <= 0 {
 		config.Concurrency.MaxConcurrent = 8
 	}
+	if config.Concurrency.MinConcurrent <= 0 {
+		config.Concurrency.MinConcurrent = 1
+	}
+	if config.Concurrency.MinConcurrent > config.Concurrency.MaxConcurrent {
+		config.Concurrency.MinConcurrent = config.Concurrency.MaxConcurrent
+	}
 	if config.Concurrency.CheckTimeout <= 0 {
 		config.Concurrency.CheckTimeout = 30 * time.Second
 	}
@@ -208,10 +319,13 @@ func validateAndSetDefaults(config *types.Config) {
 
 	// 缓存配置验证
 	if config.Cache.TTL <= 0 {
-		config.Cache.TTL = 5 * time.Minute
+		config.Cache.TTL = time.Hour
 	}
 	if config.Cache.MaxSize <= 0 {
-		config.Cache.MaxSize = 1000
+		config.Cache.MaxSize = 10000
+	}
+	if config.Cache.ResultPath == "" {
+		config.Cache.ResultPath = "cache/results.json"
 	}
 
 	// 批量配置验证
@@ -219,6 +333,27 @@ func validateAndSetDefaults(config *types.Config) {
 		config.Batch.ReportFormat = "text"
 	}
 	if config.Batch.Timeout <= 0 {
-		config.Batch.Timeout = 60 * time.Second
+		config.Batch.Timeout = 20 * time.Minute
+	}
+	if config.Batch.JournalDir == "" {
+		config.Batch.JournalDir = "journal"
+	}
+
+	// 检测配置验证
+	if config.Detection.MaxRedirects <= 0 {
+		config.Detection.MaxRedirects = 5
+	}
+	if config.Detection.CertHandshakes <= 0 {
+		config.Detection.CertHandshakes = 1
+	}
+
+	// 插件配置验证
+	for i := range config.Plugins {
+		if config.Plugins[i].Timeout <= 0 {
+			config.Plugins[i].Timeout = 10 * time.Second
+		}
+		if len(config.Plugins[i].Requires) == 0 {
+			config.Plugins[i].Requires = []string{types.FieldNetwork}
+		}
 	}
 }
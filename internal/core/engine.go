@@ -3,8 +3,12 @@ package core
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 
+	"RealityChecker/internal/cache"
+	"RealityChecker/internal/dataset"
 	"RealityChecker/internal/network"
 	"RealityChecker/internal/types"
 )
@@ -14,6 +18,9 @@ type Engine struct {
 	config      *types.Config
 	pipeline    *Pipeline
 	connections *network.ConnectionManager
+	results     *cache.ResultCache // 检测结果缓存，未启用时为nil
+	ownsResults bool               // 由本引擎加载和保存结果缓存
+	probes      *probeGroup        // 合并同时进行的相同目标和相同最终域名的探测
 	mu          sync.RWMutex
 	running     bool
 }
@@ -26,11 +33,34 @@ func NewEngine(config *types.Config) *Engine {
 
 	// 初始化组件
 	engine.connections = network.NewConnectionManager(config)
+	engine.probes = newProbeGroup()
 	engine.pipeline = NewPipeline(engine.connections, config)
+	engine.pipeline.probes = engine.probes
+	if config.Cache.ResultEnabled {
+		engine.results = cache.NewResultCache(config)
+		engine.ownsResults = true
+	}
+
+	return engine
+}
 
+// NewEngineWithResults 创建与其他引擎共用结果缓存的检测引擎
+// 缓存由原引擎加载和保存，本引擎的结果按自己的配置区分
+func NewEngineWithResults(config *types.Config, results *cache.ResultCache) *Engine {
+	engine := NewEngine(config)
+	engine.results = nil
+	engine.ownsResults = false
+	if results != nil && config.Cache.ResultEnabled {
+		engine.results = results.ForConfig(config)
+	}
 	return engine
 }
 
+// Results 检测结果缓存，未启用时为nil
+func (e *Engine) Results() *cache.ResultCache {
+	return e.results
+}
+
 // Start 启动引擎（简化版本）
 func (e *Engine) Start() error {
 	e.mu.Lock()
@@ -40,12 +70,22 @@ func (e *Engine) Start() error {
 		return fmt.Errorf("引擎已在运行")
 	}
 
+	// 校验检测阶段依赖
+	if err := e.pipeline.Validate(); err != nil {
+		return err
+	}
+
 	// 启动连接管理器
 	if err := e.connections.Start(); err != nil {
 		return fmt.Errorf("启动连接管理器失败: %v", err)
 	}
 
-	// 缓存管理器已移除
+	// 加载检测结果缓存，缓存损坏时从空缓存开始
+	if e.results != nil && e.ownsResults {
+		if err := e.results.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v，将重新检测\n", err)
+		}
+	}
 
 	e.running = true
 	return nil
@@ -60,11 +100,16 @@ func (e *Engine) Stop() error {
 		return nil
 	}
 
-	// 缓存管理器已移除
+	// 保存检测结果缓存
+	if e.results != nil && e.ownsResults {
+		if err := e.results.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "保存结果缓存失败: %v\n", err)
+		}
+	}
 
 	// 停止连接管理器
 	if err := e.connections.Stop(); err != nil {
-		fmt.Printf("停止连接管理器失败: %v\n", err)
+		fmt.Fprintf(os.Stderr, "停止连接管理器失败: %v\n", err)
 	}
 
 	e.running = false
@@ -73,11 +118,49 @@ func (e *Engine) Stop() error {
 
 // CheckDomain 检测单个域名（直接使用pipeline，简化架构）
 func (e *Engine) CheckDomain(ctx context.Context, domain string) (*types.DetectionResult, error) {
+	return e.CheckTarget(ctx, types.Target{Domain: domain})
+}
+
+// CheckTarget 检测单个目标（域名及可选的扫描IP）
+func (e *Engine) CheckTarget(ctx context.Context, target types.Target) (*types.DetectionResult, error) {
 	if !e.running {
 		return nil, fmt.Errorf("引擎未运行")
 	}
 
-	return e.pipeline.Execute(ctx, domain)
+	// 命中缓存时直接返回，--refresh-cache 时跳过读取
+	// 缓存按数据集快照区分，重新加载数据集后不再返回使用旧数据的结果
+	datasets := e.pipeline.datasets.Snapshot()
+	if e.results != nil && !e.config.Cache.Refresh {
+		if result, ok := e.results.Get(target, datasets); ok {
+			return result, nil
+		}
+	}
+
+	// 相同的目标（同一IP和SNI）同时检测时只检测一次，先后出现时由结果缓存复用
+	var err error
+	value, shared := e.probes.Do(ctx, targetProbeKey(target), func() (interface{}, bool) {
+		var result *types.DetectionResult
+		result, err = e.pipeline.ExecuteTarget(ctx, target)
+		return result, err == nil && result != nil && !types.IsCheckFailure(result.ReasonCode)
+	})
+	result, _ := value.(*types.DetectionResult)
+	if shared {
+		// 复制一份，避免调用方修改其他目标的结果
+		copied := *result
+		copied.SharedProbe = true
+		return &copied, nil
+	}
+
+	// 检测期间重新加载了数据集时，无法确定结果使用的数据，不缓存
+	if err == nil && e.results != nil && e.pipeline.datasets.Snapshot() == datasets {
+		e.results.Put(target, datasets, result)
+	}
+	return result, err
+}
+
+// targetProbeKey 目标的探测键，扫描IP和作为SNI的域名都相同时为同一探测
+func targetProbeKey(target types.Target) string {
+	return "target|" + target.IP + "|" + strings.ToLower(target.Domain)
 }
 
 // CheckDomains 批量检测域名（移除并发控制，由调用方管理）
@@ -95,11 +178,12 @@ func (e *Engine) CheckDomains(ctx context.Context, domains []string) ([]*types.D
 
 	for i, domain := range domains {
 		// 直接执行检测，不进行并发控制
-		result, err := e.pipeline.Execute(ctx, domain)
+		result, err := e.CheckDomain(ctx, domain)
 		if err != nil {
 			result = &types.DetectionResult{
-				Domain: domain,
-				Error:  err,
+				Domain:     domain,
+				Error:      err,
+				ReasonCode: types.ReasonError,
 			}
 		}
 		results[i] = result
@@ -130,11 +214,12 @@ func (e *Engine) CheckDomainsStream(ctx context.Context, domains []string) (<-ch
 				semaphore <- struct{}{}
 				defer func() { <-semaphore }()
 
-				result, err := e.pipeline.Execute(ctx, domain)
+				result, err := e.CheckDomain(ctx, domain)
 				if err != nil {
 					result = &types.DetectionResult{
-						Domain: domain,
-						Error:  err,
+						Domain:     domain,
+						Error:      err,
+						ReasonCode: types.ReasonError,
 					}
 				}
 
@@ -157,11 +242,26 @@ func (e *Engine) GetStats() *EngineStats {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	return &EngineStats{
-		Running:     e.running,
-		Connections: e.connections.GetStats(),
-		Cache:       nil, // 缓存管理器已移除
+	stats := &EngineStats{
+		Running:      e.running,
+		Connections:  e.connections.GetStats(),
+		SharedProbes: e.probes.Shared(),
+		Datasets:     e.pipeline.datasets.Snapshot().Statuses,
 	}
+
+	if e.results != nil {
+		size, hits, misses := e.results.Stats()
+		stats.Cache = &types.CacheStats{
+			ResultCacheSize: size,
+			ResultHits:      hits,
+			ResultMisses:    misses,
+		}
+		if hits+misses > 0 {
+			stats.Cache.HitRate = float64(hits) / float64(hits+misses)
+		}
+	}
+
+	return stats
 }
 
 // EngineStats 引擎统计信息（简化版本）
@@ -169,6 +269,9 @@ type EngineStats struct {
 	Running     bool                   `json:"running"`
 	Connections *types.ConnectionStats `json:"connections"`
 	Cache       *types.CacheStats      `json:"cache"`
+
+	SharedProbes int64            `json:"shared_probes"` // 复用其他目标探测结果的次数
+	Datasets     []dataset.Status `json:"datasets"`      // 数据集的加载结果
 }
 
 // CoordinatorStats 协调器统计
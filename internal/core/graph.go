@@ -0,0 +1,123 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"RealityChecker/internal/types"
+)
+
+// stageGraph 检测阶段依赖图
+type stageGraph struct {
+	stages     []types.DetectionStage
+	deps       [][]int // deps[i]: 阶段i依赖的阶段
+	dependents [][]int // dependents[i]: 依赖阶段i的阶段
+	order      []int   // 拓扑顺序
+}
+
+// buildStageGraph 根据 Requires/Produces 声明构建依赖图
+// 字段没有产出者、同一字段有多个产出者或存在循环依赖时返回错误
+func buildStageGraph(stages []types.DetectionStage) (*stageGraph, error) {
+	graph := &stageGraph{
+		stages:     stages,
+		deps:       make([][]int, len(stages)),
+		dependents: make([][]int, len(stages)),
+	}
+
+	// 建立字段到产出阶段的映射
+	producers := make(map[string]int)
+	names := make(map[string]bool)
+	for i, stage := range stages {
+		if names[stage.Name()] {
+			return nil, fmt.Errorf("检测阶段名称重复: %s", stage.Name())
+		}
+		names[stage.Name()] = true
+
+		for _, field := range stage.Produces() {
+			if other, exists := producers[field]; exists {
+				return nil, fmt.Errorf("字段 %s 同时由检测阶段 %s 和 %s 产出", field, stages[other].Name(), stage.Name())
+			}
+			producers[field] = i
+		}
+	}
+
+	// 根据依赖字段建立边
+	for i, stage := range stages {
+		seen := make(map[int]bool)
+		for _, field := range stage.Requires() {
+			producer, exists := producers[field]
+			if !exists {
+				return nil, fmt.Errorf("检测阶段 %s 依赖的字段 %s 没有产出者", stage.Name(), field)
+			}
+			if producer == i {
+				return nil, fmt.Errorf("检测阶段 %s 依赖自身产出的字段 %s", stage.Name(), field)
+			}
+			if seen[producer] {
+				continue
+			}
+			seen[producer] = true
+			graph.deps[i] = append(graph.deps[i], producer)
+			graph.dependents[producer] = append(graph.dependents[producer], i)
+		}
+	}
+
+	// 拓扑排序检测循环依赖，同时就绪的阶段按优先级排序
+	indegree := make([]int, len(stages))
+	for i := range stages {
+		indegree[i] = len(graph.deps[i])
+	}
+
+	var ready []int
+	for i := range stages {
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	for len(ready) > 0 {
+		graph.sortByPriority(ready)
+		current := ready[0]
+		ready = ready[1:]
+		graph.order = append(graph.order, current)
+
+		for _, dependent := range graph.dependents[current] {
+			indegree[dependent]--
+			if indegree[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(graph.order) != len(stages) {
+		var cycle []string
+		for i, stage := range stages {
+			if indegree[i] > 0 {
+				cycle = append(cycle, stage.Name())
+			}
+		}
+		return nil, fmt.Errorf("检测阶段存在循环依赖: %s", strings.Join(cycle, ", "))
+	}
+
+	return graph, nil
+}
+
+// sortByPriority 按优先级排序阶段下标，优先级相同时按名称排序保证确定性
+func (g *stageGraph) sortByPriority(indexes []int) {
+	sort.SliceStable(indexes, func(a, b int) bool {
+		stageA, stageB := g.stages[indexes[a]], g.stages[indexes[b]]
+		if stageA.Priority() != stageB.Priority() {
+			return stageA.Priority() < stageB.Priority()
+		}
+		return stageA.Name() < stageB.Name()
+	})
+}
+
+// orderedStages 按拓扑顺序返回检测阶段
+func (g *stageGraph) orderedStages() []types.DetectionStage {
+	ordered := make([]types.DetectionStage, len(g.order))
+	for i, index := range g.order {
+		ordered[i] = g.stages[index]
+	}
+	return ordered
+}
@@ -2,35 +2,46 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sort"
-	"sync"
 	"time"
 
+	"RealityChecker/internal/dataset"
 	"RealityChecker/internal/detectors"
 	"RealityChecker/internal/network"
+	"RealityChecker/internal/rules"
 	"RealityChecker/internal/types"
 )
 
 // Pipeline 检测流水线
 type Pipeline struct {
 	stages      []types.DetectionStage
+	graph       *stageGraph
+	graphErr    error
 	config      *types.Config
 	earlyExit   bool
 	connections *network.ConnectionManager
+	rules       *rules.Engine
+	rulesErr    error
+	probes      *probeGroup // 由引擎设置，为nil时不合并探测
+	datasets    *dataset.Registry
 }
 
 // NewPipeline 创建新的检测流水线
 func NewPipeline(connections *network.ConnectionManager, config *types.Config) *Pipeline {
 	pipeline := &Pipeline{
 		config:      config,
-		earlyExit:   true,
+		earlyExit:   !config.Detection.FullDiagnosis, // 完整诊断时执行所有检测阶段
 		connections: connections,
+		datasets:    dataset.Default(),
 	}
 
 	// 初始化检测阶段
 	pipeline.initializeStages()
 
+	// 编译适合性与评分规则
+	pipeline.rules, pipeline.rulesErr = rules.New(config.Rules)
+
 	return pipeline
 }
 
@@ -45,206 +56,412 @@ func (p *Pipeline) initializeStages() {
 		detectors.NewLocationCheckStage(),    // 6. 地理位置检查
 		detectors.NewComprehensiveTLSStage(), // 7. 综合TLS检测 (TLS1.3、X25519、H2、SNI、证书、CDN)
 		detectors.NewHotWebsiteStage(),       // 8. 热门网站检测
+		detectors.NewPageContentStage(),      // 9. 页面内容分类 (WAF挑战页、停放域名、默认页面)
+		detectors.NewProxyFrontStage(),       // 10. 代理前置检测 (扫描IP是否为他人的Reality/代理服务器)
+	}
+
+	// 配置的外部插件作为普通检测阶段运行
+	for _, plugin := range p.config.Plugins {
+		p.stages = append(p.stages, detectors.NewPluginStage(plugin))
 	}
 
-	// 按优先级排序
-	sort.Slice(p.stages, func(i, j int) bool {
-		return p.stages[i].Priority() < p.stages[j].Priority()
-	})
+	// 根据依赖声明构建调度图
+	p.rebuildGraph()
+}
+
+// rebuildGraph 重新构建依赖图，构建失败时记录错误
+func (p *Pipeline) rebuildGraph() error {
+	p.graph, p.graphErr = buildStageGraph(p.stages)
+	return p.graphErr
+}
+
+// Validate 校验检测阶段的依赖声明
+func (p *Pipeline) Validate() error {
+	if p.graphErr != nil {
+		return fmt.Errorf("检测流水线配置无效: %v", p.graphErr)
+	}
+	if p.rulesErr != nil {
+		return fmt.Errorf("适合性规则配置无效: %v", p.rulesErr)
+	}
+
+	// 需要额外校验的阶段（例如外部插件）
+	for _, stage := range p.stages {
+		if validator, ok := stage.(interface{ Validate() error }); ok {
+			if err := validator.Validate(); err != nil {
+				return fmt.Errorf("检测流水线配置无效: %v", err)
+			}
+		}
+	}
+	return nil
 }
 
 // Execute 执行检测流水线
 func (p *Pipeline) Execute(ctx context.Context, domain string) (*types.DetectionResult, error) {
+	return p.ExecuteTarget(ctx, types.Target{Domain: domain})
+}
+
+// ExecuteTarget 对检测目标执行检测流水线
+func (p *Pipeline) ExecuteTarget(ctx context.Context, target types.Target) (*types.DetectionResult, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
 	startTime := time.Now()
+	domain := target.Domain
+
+	// 单个域名所有检测阶段的总超时
+	if p.config.Concurrency.CheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.Concurrency.CheckTimeout)
+		defer cancel()
+	}
 
 	// 创建流水线上下文
 	pipelineCtx := &types.PipelineContext{
 		Domain:      domain,
 		StartTime:   startTime,
-		Result:      &types.DetectionResult{Domain: domain, StartTime: startTime},
+		TargetIP:    target.IP,
+		Result:      &types.DetectionResult{Domain: domain, TargetIP: target.IP, Egress: p.connections.Egress(), SourceAddress: p.connections.Source(), StartTime: startTime},
 		Connections: p.connections, // 传递连接管理器给检测器
 		Cache:       nil,           // 缓存管理器已移除
 		Config:      p.config,
 		Context:     ctx, // 传递原始context
 		EarlyExit:   false,
+		Datasets:    p.datasets.Snapshot(), // 同一目标的各阶段使用同一份数据
+	}
+	if p.probes != nil {
+		pipelineCtx.Probes = p.probes
 	}
 
-	// 并发执行检测阶段，提高检测效率
-	p.executeStagesConcurrently(ctx, pipelineCtx)
+	// 按依赖图并发执行检测阶段
+	p.executeStages(ctx, pipelineCtx)
 
 	// 计算总耗时
 	pipelineCtx.Result.Duration = time.Since(startTime)
 
+	// 超时或被中断时检测结果不完整，不能评估适合性
+	if err := ctx.Err(); err != nil && !pipelineCtx.EarlyExit && !stagesPassed(pipelineCtx.Result.Stages) {
+		pipelineCtx.Result.Suitable = false
+		if err == context.DeadlineExceeded {
+			pipelineCtx.Result.SetReason(types.ReasonTimeout, "检测超时")
+		} else {
+			pipelineCtx.Result.SetReason(types.ReasonInterrupted, "检测已中断")
+		}
+		return pipelineCtx.Result, nil
+	}
+
 	// 评估适合性
 	p.evaluateSuitability(pipelineCtx.Result)
 
 	return pipelineCtx.Result, nil
 }
 
-// executeStagesConcurrently 并发执行检测阶段
-func (p *Pipeline) executeStagesConcurrently(ctx context.Context, pipelineCtx *types.PipelineContext) {
-	// 将检测阶段分为两组：阻塞检测和网络检测
-	var blockingStages []types.DetectionStage
-	var networkStages []types.DetectionStage
-
-	for _, stage := range p.stages {
-		if stage.CanEarlyExit() {
-			blockingStages = append(blockingStages, stage)
-		} else {
-			networkStages = append(networkStages, stage)
+// stagesPassed 是否所有检测阶段都执行成功
+func stagesPassed(stages []types.StageReport) bool {
+	for _, stage := range stages {
+		if stage.Outcome != types.StageOutcomePassed {
+			return false
 		}
 	}
+	return true
+}
+
+// stageConcurrency 同时执行的检测阶段数上限
+const stageConcurrency = 4
 
-	// 先执行阻塞检测（被墙检测、地理位置检测等）
-	for _, stage := range blockingStages {
-		select {
-		case <-ctx.Done():
-			return
-		default:
+// stageCompletion 检测阶段执行完成通知
+type stageCompletion struct {
+	index   int
+	partial *types.PartialResult
+	report  types.StageReport
+	err     error
+}
+
+// executeStages 按依赖图调度检测阶段
+// 依赖全部完成的阶段进入就绪队列，就绪阶段按优先级并发执行
+// 每个阶段读取依赖结果的快照并返回部分结果，所有部分结果最后按拓扑顺序合并
+func (p *Pipeline) executeStages(ctx context.Context, pipelineCtx *types.PipelineContext) {
+	graph := p.graph
+
+	partials := make([]*types.PartialResult, len(graph.stages))
+	reports := make([]*types.StageReport, len(graph.stages))
+	errs := make([]error, len(graph.stages))
+
+	pending := make([]int, len(graph.stages))
+	var ready []int
+	for i := range graph.stages {
+		pending[i] = len(graph.deps[i])
+		if pending[i] == 0 {
+			ready = append(ready, i)
 		}
+	}
 
-		if err := stage.Execute(pipelineCtx); err != nil {
-			pipelineCtx.Result.Error = err
-			if stage.CanEarlyExit() {
-				pipelineCtx.EarlyExit = true
-				return
+	done := make(chan stageCompletion)
+	running := 0
+	stopped := false
+
+	for {
+		// 上下文取消后不再启动新的阶段
+		if !stopped {
+			select {
+			case <-ctx.Done():
+				stopped = true
+			default:
 			}
 		}
 
-		// 检查是否需要早期退出
-		if p.earlyExit && stage.CanEarlyExit() && pipelineCtx.EarlyExit {
-			pipelineCtx.Result.EarlyExit = true
-			return
+		graph.sortByPriority(ready)
+		for !stopped && len(ready) > 0 && running < stageConcurrency {
+			index := ready[0]
+			ready = ready[1:]
+			running++
+
+			// 快照在调度协程中生成，阶段之间不共享可写状态
+			stageCtx := p.stageContext(pipelineCtx, partials)
+			go func(index int, stageCtx *types.PipelineContext) {
+				partial, report, err := p.runStage(graph.stages[index], stageCtx)
+				done <- stageCompletion{index: index, partial: partial, report: report, err: err}
+			}(index, stageCtx)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		completion := <-done
+		running--
+		partials[completion.index] = completion.partial
+		reports[completion.index] = &completion.report
+		errs[completion.index] = completion.err
+
+		// 可早期退出的阶段失败时停止调度
+		stage := graph.stages[completion.index]
+		if completion.err != nil && p.earlyExit && stage.CanEarlyExit() {
+			pipelineCtx.EarlyExit = true
+			stopped = true
+		}
+
+		for _, dependent := range graph.dependents[completion.index] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
 		}
 	}
 
-	// 如果被阻塞，直接返回
-	if pipelineCtx.EarlyExit {
-		return
+	pipelineCtx.Result = p.assembleResult(pipelineCtx.Result, partials)
+	pipelineCtx.Result.EarlyExit = pipelineCtx.EarlyExit
+
+	// 按拓扑顺序记录执行情况，未执行的阶段记为跳过或超时
+	for _, index := range graph.order {
+		if reports[index] != nil {
+			pipelineCtx.Result.Stages = append(pipelineCtx.Result.Stages, *reports[index])
+			continue
+		}
+		report := types.StageReport{
+			Name:    graph.stages[index].Name(),
+			Outcome: types.StageOutcomeSkipped,
+		}
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			report.Outcome = types.StageOutcomeTimedOut
+			report.Error = "检测超时，未执行"
+		case ctx.Err() != nil:
+			report.Error = "检测已取消，未执行"
+		case pipelineCtx.EarlyExit:
+			report.Error = "早期退出，未执行"
+		}
+		pipelineCtx.Result.Stages = append(pipelineCtx.Result.Stages, report)
 	}
 
-	// 并发执行网络检测阶段
-	if len(networkStages) > 0 {
-		p.executeNetworkStagesConcurrently(ctx, pipelineCtx, networkStages)
+	// 按拓扑顺序取第一个错误，保证结果确定
+	for _, index := range graph.order {
+		if errs[index] != nil {
+			pipelineCtx.Result.Error = errs[index]
+			break
+		}
 	}
 }
 
-// executeNetworkStagesConcurrently 并发执行网络检测阶段
-func (p *Pipeline) executeNetworkStagesConcurrently(ctx context.Context, pipelineCtx *types.PipelineContext, stages []types.DetectionStage) {
-	// 使用信号量控制网络检测的并发数
-	networkConcurrency := 4 // 网络检测使用4个并发
-	semaphore := make(chan struct{}, networkConcurrency)
-
-	var wg sync.WaitGroup
-	for i, stage := range stages {
-		wg.Add(1)
-		go func(index int, s types.DetectionStage) {
-			defer wg.Done()
+// runStage 执行单个检测阶段，捕获panic并生成执行记录
+func (p *Pipeline) runStage(stage types.DetectionStage, stageCtx *types.PipelineContext) (partial *types.PartialResult, report types.StageReport, err error) {
+	report = types.StageReport{
+		Name:    stage.Name(),
+		Start:   time.Now(),
+		Outcome: types.StageOutcomePassed,
+	}
 
-			// 获取信号量
-			select {
-			case semaphore <- struct{}{}:
-				defer func() {
-					<-semaphore
-				}()
-			case <-ctx.Done():
-				return
+	// 阶段单独配置了超时时，在单域名超时的基础上进一步限制
+	if timeout := p.config.Detection.StageTimeouts[stage.Name()]; timeout > 0 && stageCtx.Context != nil {
+		var cancel context.CancelFunc
+		stageCtx.Context, cancel = context.WithTimeout(stageCtx.Context, timeout)
+		defer cancel()
+	}
+	stageCtx.Trace = &types.StageTrace{}
+
+	defer func() {
+		if r := recover(); r != nil {
+			partial = nil
+			err = fmt.Errorf("检测阶段 %s panic: %v", stage.Name(), r)
+			report.Outcome = types.StageOutcomePanicked
+		} else if err != nil {
+			report.Outcome = types.StageOutcomeFailed
+			if errors.Is(err, context.DeadlineExceeded) ||
+				(stageCtx.Context != nil && stageCtx.Context.Err() == context.DeadlineExceeded) {
+				report.Outcome = types.StageOutcomeTimedOut
 			}
+		}
 
-			// 执行检测阶段
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						pipelineCtx.Result.Error = fmt.Errorf("检测阶段 %s panic: %v", s.Name(), r)
-					}
-				}()
+		report.Duration = time.Since(report.Start)
+		report.Retries = stageCtx.Trace.Retries()
+		if err != nil {
+			report.Error = err.Error()
+		}
+		if partial != nil {
+			report.Evidence = partial.Evidence
+		}
+	}()
+
+	partial, err = stage.Execute(stageCtx)
+	return partial, report, err
+}
+
+// stageContext 为检测阶段创建独立的上下文，结果为已完成阶段的合并快照
+func (p *Pipeline) stageContext(pipelineCtx *types.PipelineContext, partials []*types.PartialResult) *types.PipelineContext {
+	stageCtx := *pipelineCtx
+	stageCtx.Result = p.assembleResult(pipelineCtx.Result, partials)
 
-				if err := s.Execute(pipelineCtx); err != nil {
-					pipelineCtx.Result.Error = err
-				}
-			}()
-		}(i, stage)
+	// 发生重定向时，依赖网络结果的阶段检测最终域名
+	if network := stageCtx.Result.Network; network != nil && network.IsRedirected && network.FinalDomain != "" {
+		stageCtx.Domain = network.FinalDomain
 	}
 
-	wg.Wait()
+	return &stageCtx
 }
 
-// evaluateSuitability 评估适合性
-func (p *Pipeline) evaluateSuitability(result *types.DetectionResult) {
-	// 检查硬性条件
-	if result.Blocked != nil && result.Blocked.IsBlocked {
-		result.Suitable = false
-		result.Error = fmt.Errorf("域名被墙")
-		return
+// assembleResult 以base为基础按拓扑顺序合并部分结果，返回新的检测结果
+func (p *Pipeline) assembleResult(base *types.DetectionResult, partials []*types.PartialResult) *types.DetectionResult {
+	result := *base
+	if base.Summary != nil {
+		summary := *base.Summary
+		summary.Warnings = append([]string(nil), base.Summary.Warnings...)
+		result.Summary = &summary
 	}
 
-	if result.Location != nil && result.Location.IsDomestic {
-		result.Suitable = false
-		result.Error = fmt.Errorf("国内网站")
-		return
+	for _, index := range p.graph.order {
+		if partials[index] != nil {
+			mergePartialResult(&result, partials[index])
+		}
 	}
 
-	if result.Network != nil && !result.Network.Accessible {
-		result.Suitable = false
-		result.Error = fmt.Errorf("网络不可达")
-		result.StatusCodeCategory = types.StatusCodeCategoryNetwork
-		return
-	}
+	return &result
+}
 
-	// 检查状态码是否安全
-	if result.Network != nil && result.Network.Accessible {
-		statusCodeCategory := types.ClassifyStatusCode(result.Network.StatusCode, true)
-		result.StatusCodeCategory = statusCodeCategory
+// mergePartialResult 合并部分结果
+// 部分结果可能被多个快照引用，合并时只替换指针或复制后修改，不修改原对象
+func mergePartialResult(result *types.DetectionResult, partial *types.PartialResult) {
+	if partial.Blocked != nil {
+		result.Blocked = partial.Blocked
+	}
+	if partial.Network != nil {
+		result.Network = partial.Network
+	}
+	if partial.StatusCodeCategory != "" {
+		result.StatusCodeCategory = partial.StatusCodeCategory
+	}
+	if partial.Naturalness != nil && result.Network != nil {
+		network := *result.Network
+		network.Naturalness = partial.Naturalness
+		result.Network = &network
+	}
 
-		// 如果状态码不安全，标记为不适合
-		if statusCodeCategory == types.StatusCodeCategoryExcluded {
-			result.Suitable = false
-			result.Error = fmt.Errorf("状态码不自然: %d", result.Network.StatusCode)
-			return
+	// IP解析结果与地理位置结果合并到同一个Location中
+	if partial.IPAddress != "" {
+		location := types.LocationResult{}
+		if result.Location != nil {
+			location = *result.Location
 		}
+		location.IPAddress = partial.IPAddress
+		result.Location = &location
+	}
+	if partial.Location != nil {
+		result.Location = partial.Location
 	}
 
-	if result.TLS != nil {
-		if !result.TLS.SupportsTLS13 {
-			result.Suitable = false
-			result.Error = fmt.Errorf("不支持TLS 1.3")
-			return
-		}
-		if !result.TLS.SupportsX25519 {
-			result.Suitable = false
-			result.Error = fmt.Errorf("不支持X25519密钥交换")
-			return
+	if partial.TLS != nil {
+		result.TLS = partial.TLS
+	}
+	if partial.SNI != nil {
+		result.SNI = partial.SNI
+	}
+	if partial.Certificate != nil {
+		result.Certificate = partial.Certificate
+	}
+
+	// CDN结果先产出者优先，热门网站标记单独合并
+	if partial.CDN != nil && (result.CDN == nil || !result.CDN.IsCDN) {
+		cdn := *partial.CDN
+		if result.CDN != nil {
+			cdn.IsHotWebsite = result.CDN.IsHotWebsite
 		}
-		if !result.TLS.SupportsHTTP2 {
-			result.Suitable = false
-			result.Error = fmt.Errorf("不支持HTTP/2")
-			return
+		result.CDN = &cdn
+	}
+	if partial.HotWebsite != nil {
+		cdn := types.CDNResult{}
+		if result.CDN != nil {
+			cdn = *result.CDN
 		}
+		cdn.IsHotWebsite = *partial.HotWebsite
+		result.CDN = &cdn
 	}
 
-	if result.Certificate != nil {
-		if !result.Certificate.Valid {
-			result.Suitable = false
-			result.Error = fmt.Errorf("证书无效")
-			return
-		}
-		// 只有真正过期的证书才标记为不适合（天数小于等于0）
-		if result.Certificate.DaysUntilExpiry <= 0 {
-			result.Suitable = false
-			result.Error = fmt.Errorf("证书已过期（%d天）", result.Certificate.DaysUntilExpiry)
-			return
+	if partial.PageStatus != nil {
+		result.PageStatus = partial.PageStatus
+	}
+	if partial.ProxyFront != nil {
+		result.ProxyFront = partial.ProxyFront
+	}
+	if partial.Plugin != nil {
+		plugins := make(map[string]*types.PluginResult, len(result.Plugins)+1)
+		for name, plugin := range result.Plugins {
+			plugins[name] = plugin
 		}
+		plugins[partial.Plugin.Name] = partial.Plugin
+		result.Plugins = plugins
+	}
+	if partial.AlternativeCandidate != "" {
+		result.AlternativeCandidate = partial.AlternativeCandidate
+	}
+	if partial.ProbedTarget != "" {
+		result.ProbedTarget = partial.ProbedTarget
+		result.SharedProbe = result.SharedProbe || partial.SharedProbe
+	}
+	for _, warning := range partial.Warnings {
+		result.AddWarning(warning)
 	}
+}
 
-	if result.SNI != nil && (!result.SNI.SupportsSNI || !result.SNI.SNIMatch) {
-		result.Suitable = false
-		result.Error = fmt.Errorf("SNI不匹配")
-		return
+// evaluateSuitability 评估适合性
+// 硬性条件、评分和推荐星级都由规则引擎根据配置的规则计算
+func (p *Pipeline) evaluateSuitability(result *types.DetectionResult) {
+	// 按最终状态码分类，不可达的目标归为网络错误
+	if result.Network != nil {
+		result.StatusCodeCategory = types.ClassifyStatusCode(result.Network.StatusCode, result.Network.Accessible)
 	}
 
-	// 所有硬性条件都符合
-	result.Suitable = true
-	result.HardRequirementsMet = true
+	evaluation := p.rules.Evaluate(result, p.config)
+	result.Score = evaluation.Score
+	result.ScoreBreakdown = evaluation.Breakdown
+	result.Stars = evaluation.Stars
+	result.Suitable = evaluation.Suitable
+	result.HardRequirementsMet = evaluation.Suitable
+	result.FailedRequirements = evaluation.Failed
+	if !evaluation.Suitable {
+		result.SetReason(evaluation.Reason.Code, evaluation.Reason.Message)
+	} else if result.Error != nil {
+		// 硬性条件都通过但有检测阶段出错，结果不完整
+		result.ReasonCode = types.ReasonError
+	}
 }
 
 // SetEarlyExit 设置是否早期退出
@@ -252,21 +469,22 @@ func (p *Pipeline) SetEarlyExit(earlyExit bool) {
 	p.earlyExit = earlyExit
 }
 
-// GetStages 获取检测阶段
+// GetStages 按拓扑顺序获取检测阶段
 func (p *Pipeline) GetStages() []types.DetectionStage {
-	return p.stages
+	if p.graph == nil {
+		return p.stages
+	}
+	return p.graph.orderedStages()
 }
 
-// AddStage 添加检测阶段
-func (p *Pipeline) AddStage(stage types.DetectionStage) {
+// AddStage 添加检测阶段，依赖声明无效时返回错误
+func (p *Pipeline) AddStage(stage types.DetectionStage) error {
 	p.stages = append(p.stages, stage)
-	sort.Slice(p.stages, func(i, j int) bool {
-		return p.stages[i].Priority() < p.stages[j].Priority()
-	})
+	return p.rebuildGraph()
 }
 
-// RemoveStage 移除检测阶段
-func (p *Pipeline) RemoveStage(name string) {
+// RemoveStage 移除检测阶段，移除后依赖无法满足时返回错误
+func (p *Pipeline) RemoveStage(name string) error {
 	var newStages []types.DetectionStage
 	for _, stage := range p.stages {
 		if stage.Name() != name {
@@ -274,4 +492,5 @@ func (p *Pipeline) RemoveStage(name string) {
 		}
 	}
 	p.stages = newStages
+	return p.rebuildGraph()
 }
@@ -0,0 +1,401 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"RealityChecker/internal/config"
+	"RealityChecker/internal/network"
+	"RealityChecker/internal/types"
+)
+
+// 测试证书的签发者
+const (
+	localIssuer      = "Local Test CA"
+	cloudflareIssuer = "Cloudflare Test CA"
+)
+
+// testCertificates 各签发者为模拟网站签发的证书，覆盖 127.0.0.0/24 的所有地址
+var testCertificates = map[string]tls.Certificate{}
+
+// TestMain 生成测试用的CA和网站证书，并通过 SSL_CERT_FILE 让TLS检测信任这些CA
+// 系统根证书在首次校验时加载一次，必须在任何测试开始前设置
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "pipeline-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var roots bytes.Buffer
+	for _, issuer := range []string{localIssuer, cloudflareIssuer} {
+		caPEM, certificate, err := newTestCertificate(issuer)
+		if err != nil {
+			log.Fatal(err)
+		}
+		roots.Write(caPEM)
+		testCertificates[issuer] = certificate
+	}
+
+	rootsPath := filepath.Join(dir, "roots.pem")
+	if err := os.WriteFile(rootsPath, roots.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	os.Setenv("SSL_CERT_FILE", rootsPath)
+	os.Setenv("SSL_CERT_DIR", dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestCertificate 生成CA及其签发的网站证书，返回CA证书（PEM）和网站证书
+func newTestCertificate(issuer string) ([]byte, tls.Certificate, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{issuer}, CommonName: issuer},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for i := 0; i < 256; i++ {
+		leaf.IPAddresses = append(leaf.IPAddresses, net.IPv4(127, 0, 0, byte(i)))
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	return caPEM, tls.Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: leafKey}, nil
+}
+
+// fakeSites 本地模拟的目标网站
+// 所有目标都是 127.0.0.x，经由本地CONNECT代理把443端口转发到TLS服务器、80端口转发到HTTP服务器
+type fakeSites struct {
+	tlsServer  *httptest.Server
+	httpServer *httptest.Server
+	proxy      net.Listener
+	wg         sync.WaitGroup
+}
+
+// newFakeSites 启动模拟网站和代理，网站证书由 issuer 签发
+// 末位为奇数的地址跳转到下一个地址，末位为偶数的地址首页返回200、其他路径返回404
+func newFakeSites(t *testing.T, issuer string) *fakeSites {
+	t.Helper()
+
+	sites := &fakeSites{}
+	sites.tlsServer = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := hostOnly(r.Host)
+		ip := net.ParseIP(host).To4()
+		if ip != nil && ip[3]%2 == 1 {
+			next := net.IPv4(ip[0], ip[1], ip[2], ip[3]+1)
+			http.Redirect(w, r, "https://"+next.String()+"/", http.StatusMovedPermanently)
+			return
+		}
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body>%s</body></html>", host, strings.Repeat("content ", 64))
+	}))
+	// 连通性测试等提前关闭的连接会在服务端产生握手错误日志
+	sites.tlsServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	sites.tlsServer.TLS = &tls.Config{Certificates: []tls.Certificate{testCertificates[issuer]}}
+	sites.tlsServer.EnableHTTP2 = true
+	sites.tlsServer.StartTLS()
+
+	sites.httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://"+hostOnly(r.Host)+r.URL.Path, http.StatusMovedPermanently)
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动代理失败: %v", err)
+	}
+	sites.proxy = listener
+	sites.wg.Add(1)
+	go sites.serveProxy()
+
+	t.Cleanup(sites.Close)
+	return sites
+}
+
+// serveProxy 处理CONNECT请求
+func (s *fakeSites) serveProxy() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.proxy.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.tunnel(conn)
+		}()
+	}
+}
+
+// tunnel 按目标端口转发到对应的本地服务器
+func (s *fakeSites) tunnel(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil || req.Method != http.MethodConnect {
+		return
+	}
+
+	var backend string
+	switch _, port, _ := net.SplitHostPort(req.Host); port {
+	case "443":
+		backend = s.tlsServer.Listener.Addr().String()
+	case "80":
+		backend = s.httpServer.Listener.Addr().String()
+	default:
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+
+	upstream, err := net.Dial("tcp", backend)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer upstream.Close()
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, reader)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Close 关闭代理和服务器
+func (s *fakeSites) Close() {
+	s.proxy.Close()
+	s.tlsServer.CloseClientConnections()
+	s.httpServer.CloseClientConnections()
+	s.tlsServer.Close()
+	s.httpServer.Close()
+	s.wg.Wait()
+}
+
+// hostOnly 去掉Host头中的端口
+func hostOnly(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// newTestPipeline 创建经由模拟网站代理探测的流水线
+func newTestPipeline(t *testing.T, sites *fakeSites) *Pipeline {
+	t.Helper()
+
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		t.Fatalf("加载默认配置失败: %v", err)
+	}
+	cfg.Network.Proxy = types.ProxyConfig{Type: types.ProxyTypeHTTP, Address: sites.proxy.Addr().String()}
+	cfg.Network.RateLimit = types.RateLimitConfig{PerIPConcurrent: -1, PerIPRate: -1, PerPrefixConcurrent: -1, PerPrefixRate: -1}
+	cfg.Network.Retries = 0
+
+	connections := network.NewConnectionManager(cfg)
+	if err := connections.Start(); err != nil {
+		t.Fatalf("启动连接管理器失败: %v", err)
+	}
+	t.Cleanup(func() { connections.Stop() })
+
+	pipeline := NewPipeline(connections, cfg)
+	if err := pipeline.Validate(); err != nil {
+		t.Fatalf("流水线无效: %v", err)
+	}
+	return pipeline
+}
+
+// TestPipelineConcurrentTargets 并发检测多个目标，各目标的结果互不影响
+// 配合 go test -race 检查检测阶段之间及目标之间没有共享的可写状态
+func TestPipelineConcurrentTargets(t *testing.T) {
+	sites := newFakeSites(t, localIssuer)
+	pipeline := newTestPipeline(t, sites)
+
+	const targetCount = 32
+	targets := make([]types.Target, targetCount)
+	for i := range targets {
+		targets[i] = types.Target{Domain: fmt.Sprintf("127.0.0.%d", 10+i)}
+		if i%4 == 0 {
+			// 带扫描IP的目标会执行代理前置检测
+			targets[i].IP = "127.0.0.200"
+		}
+	}
+
+	results := make([]*types.DetectionResult, targetCount)
+	errs := make([]error, targetCount)
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = pipeline.ExecuteTarget(context.Background(), targets[i])
+		}(i)
+	}
+	wg.Wait()
+
+	stageCount := len(pipeline.stages)
+	for i, result := range results {
+		target := targets[i]
+		if errs[i] != nil {
+			t.Fatalf("%s: 执行失败: %v", target.Domain, errs[i])
+		}
+		if result.Domain != target.Domain || result.TargetIP != target.IP {
+			t.Errorf("%s: 结果属于 %s/%s", target.Domain, result.Domain, result.TargetIP)
+		}
+		if len(result.Stages) != stageCount {
+			t.Errorf("%s: 记录了 %d 个检测阶段，应为 %d", target.Domain, len(result.Stages), stageCount)
+		}
+		for _, stage := range result.Stages {
+			if stage.Outcome == types.StageOutcomePanicked {
+				t.Errorf("%s: 检测阶段 %s panic: %s", target.Domain, stage.Name, stage.Error)
+			}
+		}
+
+		if result.Network == nil || !result.Network.Accessible {
+			t.Errorf("%s: 网站不可访问: %+v", target.Domain, result.Network)
+			continue
+		}
+
+		// 奇数地址跳转到下一个地址，最终域名必须来自本目标自己的重定向
+		wantFinal := target.Domain
+		if ip := net.ParseIP(target.Domain).To4(); ip[3]%2 == 1 {
+			wantFinal = net.IPv4(ip[0], ip[1], ip[2], ip[3]+1).String()
+		}
+		if result.Network.FinalDomain != wantFinal {
+			t.Errorf("%s: 最终域名 %s，应为 %s", target.Domain, result.Network.FinalDomain, wantFinal)
+		}
+		if result.Network.StatusCode != http.StatusOK {
+			t.Errorf("%s: 状态码 %d", target.Domain, result.Network.StatusCode)
+		}
+		if !strings.Contains(string(result.Network.BodySnippet), "<title>"+wantFinal+"</title>") {
+			t.Errorf("%s: 页面内容不属于 %s", target.Domain, wantFinal)
+		}
+
+		naturalness := result.Network.Naturalness
+		if naturalness == nil {
+			t.Errorf("%s: 没有自然度检测结果", target.Domain)
+		} else if naturalness.RandomPathStatus != http.StatusNotFound || !naturalness.HTTPRedirectsToHTTPS {
+			t.Errorf("%s: 自然度检测结果 %+v", target.Domain, naturalness)
+		}
+
+		if result.TLS == nil || !result.TLS.SupportsTLS13 || !result.TLS.SupportsHTTP2 {
+			t.Errorf("%s: TLS检测结果 %+v", target.Domain, result.TLS)
+		}
+		if result.Certificate == nil || !result.Certificate.Valid {
+			t.Errorf("%s: 证书检测结果 %+v", target.Domain, result.Certificate)
+		}
+		if result.CDN != nil && result.CDN.IsCDN {
+			t.Errorf("%s: 误判为CDN: %+v", target.Domain, result.CDN)
+		}
+		if target.IP != "" && result.ProxyFront == nil {
+			t.Errorf("%s: 没有代理前置检测结果", target.Domain)
+		}
+	}
+}
+
+// TestPipelineCertificateCDN 证书签发者为CDN时，由TLS阶段握手得到的证书识别CDN
+func TestPipelineCertificateCDN(t *testing.T) {
+	sites := newFakeSites(t, cloudflareIssuer)
+	pipeline := newTestPipeline(t, sites)
+
+	result, err := pipeline.ExecuteTarget(context.Background(), types.Target{Domain: "127.0.0.20"})
+	if err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if result.Certificate == nil || !strings.Contains(result.Certificate.Issuer, cloudflareIssuer) {
+		t.Fatalf("证书检测结果 %+v", result.Certificate)
+	}
+	if result.CDN == nil || !result.CDN.IsCDN {
+		t.Fatalf("没有根据证书签发者识别CDN: %+v", result.CDN)
+	}
+	if !strings.Contains(result.CDN.Evidence, cloudflareIssuer) {
+		t.Errorf("CDN依据 %q 没有包含证书签发者", result.CDN.Evidence)
+	}
+}
+
+// TestPipelineConcurrentCancel 并发检测中途取消，所有目标都能返回结果
+func TestPipelineConcurrentCancel(t *testing.T) {
+	sites := newFakeSites(t, localIssuer)
+	pipeline := newTestPipeline(t, sites)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := pipeline.ExecuteTarget(ctx, types.Target{Domain: fmt.Sprintf("127.0.0.%d", 100+i)})
+			if err != nil {
+				t.Errorf("执行失败: %v", err)
+				return
+			}
+			if result == nil {
+				t.Errorf("取消后没有返回结果")
+			}
+		}(i)
+		if i == 8 {
+			cancel()
+		}
+	}
+	wg.Wait()
+	cancel()
+}
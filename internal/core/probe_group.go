@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// probeGroup 合并多个目标之间同时进行的相同探测
+// 进行中的探测只执行一次，其他目标等待并共用结果；探测完成后即移除，不保留结果，
+// 先后出现的相同探测由结果缓存处理，--refresh-cache 和重新加载数据集对其同样有效
+type probeGroup struct {
+	mu     sync.Mutex
+	calls  map[string]*probeCall
+	shared int64 // 复用其他目标探测结果的次数
+}
+
+// probeCall 一次探测
+type probeCall struct {
+	done  chan struct{}
+	value interface{}
+	ok    bool // 结果是否可以共用，超时或中断的结果不共用
+}
+
+// newProbeGroup 创建探测合并组
+func newProbeGroup() *probeGroup {
+	return &probeGroup{
+		calls: make(map[string]*probeCall),
+	}
+}
+
+// Do 执行或等待键相同的探测，返回探测结果和是否复用了其他目标的结果
+// probe 返回的结果不可共用时，等待者各自重新探测
+func (g *probeGroup) Do(ctx context.Context, key string, probe func() (interface{}, bool)) (interface{}, bool) {
+	for {
+		g.mu.Lock()
+		call, exists := g.calls[key]
+		if !exists {
+			call = &probeCall{done: make(chan struct{})}
+			g.calls[key] = call
+			g.mu.Unlock()
+			return g.run(key, call, probe), false
+		}
+		g.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			// 本目标已超时或被中断，不再等待，由探测自身报告取消
+			value, _ := probe()
+			return value, false
+		}
+		if call.ok {
+			g.mu.Lock()
+			g.shared++
+			g.mu.Unlock()
+			return call.value, true
+		}
+		// 首个探测的结果不可共用（例如该目标超时被取消），重新探测
+	}
+}
+
+// run 执行探测，完成后移除探测，探测 panic 时同样唤醒等待者
+// 等待者持有探测本身，移除后仍能读取结果
+func (g *probeGroup) run(key string, call *probeCall, probe func() (interface{}, bool)) interface{} {
+	defer func() {
+		g.mu.Lock()
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.ok = probe()
+	return call.value
+}
+
+// Shared 复用其他目标探测结果的次数
+func (g *probeGroup) Shared() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.shared
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProbeGroupDoesNotKeepCompletedProbes(t *testing.T) {
+	group := newProbeGroup()
+	runs := 0
+	probe := func() (interface{}, bool) {
+		runs++
+		return runs, true
+	}
+
+	// 完成的探测立即移除，先后出现的相同探测各自执行
+	for i := 1; i <= 3; i++ {
+		value, shared := group.Do(context.Background(), "key", probe)
+		if shared || value != i {
+			t.Errorf("第 %d 次探测得到 %v（复用 %v），完成的探测不应被复用", i, value, shared)
+		}
+	}
+	if len(group.calls) != 0 {
+		t.Errorf("完成的探测应被移除，剩余 %d 个", len(group.calls))
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"RealityChecker/internal/network"
+	"RealityChecker/internal/types"
 )
 
 // DataFile 数据文件配置
@@ -17,30 +20,37 @@ type DataFile struct {
 
 // Downloader 数据文件下载器
 type Downloader struct {
+	dialer  *network.Dialer // 配置上游代理时经由代理下载
 	timeout time.Duration
 	retries int
 	retryDelay time.Duration
+	progress io.Writer // 下载进度和提示信息的输出位置
 }
 
-// NewDownloader 创建下载器
-func NewDownloader() *Downloader {
+// NewDownloader 创建下载器，进度和提示信息写到 progress
+func NewDownloader(config *types.NetworkConfig, progress io.Writer) *Downloader {
 	return &Downloader{
+		dialer:     network.NewDialer(config),
 		timeout:    30 * time.Second,
 		retries:    3,
 		retryDelay: 2 * time.Second,
+		progress:   progress,
 	}
 }
 
 // printTimestampedMessage 打印带时间戳的消息
-func printTimestampedMessage(format string, args ...interface{}) {
+func (d *Downloader) printTimestampedMessage(format string, args ...interface{}) {
 	timestamp := time.Now().Format("15:04:05")
 	message := fmt.Sprintf(format, args...)
-	fmt.Printf("[%s] %s\n", timestamp, message)
+	fmt.Fprintf(d.progress, "[%s] %s\n", timestamp, message)
 }
 
 // EnsureDataFiles 确保所有数据文件存在且最新
 func (d *Downloader) EnsureDataFiles() error {
-	printTimestampedMessage("检查数据文件...")
+	d.printTimestampedMessage("检查数据文件...")
+	if d.dialer.Proxied() {
+		d.printTimestampedMessage("经由代理 %s 下载数据文件", d.dialer.Egress())
+	}
 	
 	// 定义需要下载的文件
 	files := []DataFile{
@@ -54,6 +64,11 @@ func (d *Downloader) EnsureDataFiles() error {
 			URL:       "https://raw.githubusercontent.com/V2RaySSR/RealityChecker/main/data/hot_websites.txt",
 			LocalPath: "data/hot_websites.txt",
 		},
+		{
+			Name:      "page_signatures.txt",
+			URL:       "https://raw.githubusercontent.com/V2RaySSR/RealityChecker/main/data/page_signatures.txt",
+			LocalPath: "data/page_signatures.txt",
+		},
 		{
 			Name:      "gfwlist.conf",
 			URL:       "https://raw.githubusercontent.com/Loyalsoldier/clash-rules/release/gfw.txt",
@@ -78,7 +93,7 @@ func (d *Downloader) EnsureDataFiles() error {
 		}
 	}
 
-	printTimestampedMessage("数据文件检查完成。")
+	d.printTimestampedMessage("数据文件检查完成。")
 	return nil
 }
 
@@ -92,7 +107,7 @@ func (d *Downloader) ensureFile(file DataFile) error {
 
 	// 如果文件不存在，直接下载
 	if !exists {
-		printTimestampedMessage("下载 %s...", file.Name)
+		d.printTimestampedMessage("下载 %s...", file.Name)
 		return d.downloadWithRetry(file)
 	}
 
@@ -104,7 +119,7 @@ func (d *Downloader) ensureFile(file DataFile) error {
 
 	// 如果需要更新，下载新文件
 	if needsUpdate {
-		printTimestampedMessage("更新 %s...", file.Name)
+		d.printTimestampedMessage("更新 %s...", file.Name)
 		return d.downloadWithRetry(file)
 	}
 
@@ -139,7 +154,7 @@ func (d *Downloader) needsUpdate(path string) (bool, error) {
 func (d *Downloader) downloadWithRetry(file DataFile) error {
 	for i := 0; i < d.retries; i++ {
 		if i > 0 {
-			fmt.Printf("重试中... (%d/%d)\n", i, d.retries)
+			fmt.Fprintf(d.progress, "重试中... (%d/%d)\n", i, d.retries)
 			time.Sleep(d.retryDelay)
 		}
 
@@ -148,7 +163,7 @@ func (d *Downloader) downloadWithRetry(file DataFile) error {
 			return nil // 成功
 		}
 
-		fmt.Printf("错误：下载 %s 失败 - %s %v\n", file.Name, file.URL, err)
+		fmt.Fprintf(d.progress, "错误：下载 %s 失败 - %s %v\n", file.Name, file.URL, err)
 	}
 
 	// 所有重试都失败了，显示手动下载说明
@@ -160,7 +175,8 @@ func (d *Downloader) downloadWithRetry(file DataFile) error {
 func (d *Downloader) downloadFile(file DataFile) error {
 	// 创建HTTP客户端
 	client := &http.Client{
-		Timeout: d.timeout,
+		Transport: d.dialer.HTTPTransport(),
+		Timeout:   d.timeout,
 	}
 
 	// 发送请求
@@ -204,13 +220,14 @@ func (d *Downloader) downloadFile(file DataFile) error {
 
 // showManualDownloadInstructions 显示手动下载说明
 func (d *Downloader) showManualDownloadInstructions() {
-	fmt.Println("程序终止：缺少必要的数据文件")
-	fmt.Println()
-	fmt.Println("请手动下载以下文件到 data/ 目录：")
-	fmt.Println("1. cdn_keywords.txt: https://raw.githubusercontent.com/V2RaySSR/RealityChecker/main/data/cdn_keywords.txt")
-	fmt.Println("2. hot_websites.txt: https://raw.githubusercontent.com/V2RaySSR/RealityChecker/main/data/hot_websites.txt")
-	fmt.Println("3. gfwlist.conf: https://raw.githubusercontent.com/Loyalsoldier/clash-rules/release/gfw.txt")
-	fmt.Println("4. Country.mmdb: https://github.com/Loyalsoldier/geoip/releases/latest/download/Country.mmdb")
-	fmt.Println()
-	fmt.Println("下载完成后重新运行程序即可。")
+	fmt.Fprintln(d.progress, "程序终止：缺少必要的数据文件")
+	fmt.Fprintln(d.progress)
+	fmt.Fprintln(d.progress, "请手动下载以下文件到 data/ 目录：")
+	fmt.Fprintln(d.progress, "1. cdn_keywords.txt: https://raw.githubusercontent.com/V2RaySSR/RealityChecker/main/data/cdn_keywords.txt")
+	fmt.Fprintln(d.progress, "2. hot_websites.txt: https://raw.githubusercontent.com/V2RaySSR/RealityChecker/main/data/hot_websites.txt")
+	fmt.Fprintln(d.progress, "3. page_signatures.txt: https://raw.githubusercontent.com/V2RaySSR/RealityChecker/main/data/page_signatures.txt")
+	fmt.Fprintln(d.progress, "4. gfwlist.conf: https://raw.githubusercontent.com/Loyalsoldier/clash-rules/release/gfw.txt")
+	fmt.Fprintln(d.progress, "5. Country.mmdb: https://github.com/Loyalsoldier/geoip/releases/latest/download/Country.mmdb")
+	fmt.Fprintln(d.progress)
+	fmt.Fprintln(d.progress, "下载完成后重新运行程序即可。")
 }
@@ -0,0 +1,215 @@
+package dataset
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+
+	"RealityChecker/internal/domainmatch"
+)
+
+// loadGFWList 加载clash规则格式的GFW域名列表
+// '+.example.com' 表示域名本身及其所有子域名
+func loadGFWList(path string, snapshot *Snapshot, status *Status) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gfwlist := domainmatch.New()
+	name := filepath.Base(path)
+	scanner := bufio.NewScanner(file)
+	inPayload := false
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "payload:" {
+			inPayload = true
+			continue
+		}
+
+		if !inPayload {
+			continue
+		}
+
+		if strings.HasPrefix(line, "- '") && strings.HasSuffix(line, "'") {
+			domain := strings.TrimPrefix(line, "- '")
+			domain = strings.TrimSuffix(domain, "'")
+			if domain == "" {
+				continue
+			}
+
+			ruleType, pattern := domainmatch.ParseRule(domain)
+			if err := gfwlist.Add(ruleType, pattern, name, lineNumber); err != nil {
+				status.Invalid++
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if gfwlist.Len() == 0 {
+		return fmt.Errorf("没有找到域名规则")
+	}
+
+	snapshot.GFWList = gfwlist
+	status.Entries = gfwlist.Len()
+	return nil
+}
+
+// loadHotWebsites 加载热门网站列表，每行一条规则，# 开头为注释
+func loadHotWebsites(path string, snapshot *Snapshot, status *Status) error {
+	hotWebsites := domainmatch.New()
+	err := scanRules(path, func(line string, lineNumber int) {
+		ruleType, pattern := domainmatch.ParseRule(line)
+		if err := hotWebsites.Add(ruleType, pattern, filepath.Base(path), lineNumber); err != nil {
+			status.Invalid++
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot.HotWebsites = hotWebsites
+	status.Entries = hotWebsites.Len()
+	return nil
+}
+
+// loadCDNKeywords 加载CDN特征关键字，按节标题分类
+// CNAME和NS后缀建立域名匹配器，其余特征按原文保存
+func loadCDNKeywords(path string, snapshot *Snapshot, status *Status) error {
+	keywords := newSnapshot().CDN
+	sections := map[string]map[string]bool{
+		"http_strong_header:":       keywords.HTTPStrongHeader,
+		"http_medium_header:":       keywords.HTTPMediumHeader,
+		"http_value_cdn_domains:":   keywords.HTTPValueCDNDomains,
+		"asn_strong_exact:":         keywords.ASNStrongExact,
+		"cert_issuer_hint:":         keywords.CertIssuerHint,
+		"exclude_server_tokens:":    keywords.ExcludeServerTokens,
+		"exclude_keywords_generic:": keywords.ExcludeKeywordsGeneric,
+	}
+	matchers := map[string]*domainmatch.Matcher{
+		"cname_strong_suffix:": keywords.CNAMEStrongSuffix,
+		"ns_hint_suffix:":      keywords.NSHintSuffix,
+	}
+
+	name := filepath.Base(path)
+	currentSection := ""
+	loadedCount := 0
+
+	err := scanRules(path, func(line string, lineNumber int) {
+		// 检查是否是节标题
+		if strings.HasSuffix(line, ":") {
+			currentSection = line
+			return
+		}
+
+		// 后缀规则去掉行尾注释，按后缀匹配
+		if matcher, exists := matchers[currentSection]; exists {
+			suffix := strings.TrimSpace(strings.Split(line, "#")[0])
+			if err := matcher.Add(domainmatch.Suffix, suffix, name, lineNumber); err != nil {
+				status.Invalid++
+				return
+			}
+			loadedCount++
+			return
+		}
+
+		// 排除规则不计入特征数量
+		if section, exists := sections[currentSection]; exists {
+			section[line] = true
+			if !strings.HasPrefix(currentSection, "exclude_") {
+				loadedCount++
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot.CDN = keywords
+	status.Entries = loadedCount
+	return nil
+}
+
+// loadPageSignatures 加载页面内容特征，按节标题分类
+// 节标题必须是已知的节名，响应头特征同样以冒号结尾，不能只根据冒号判断
+func loadPageSignatures(path string, snapshot *Snapshot, status *Status) error {
+	pages := &PageSignatures{}
+	sections := map[string]*[]string{
+		"waf_challenge:":        &pages.WAFChallenge,
+		"waf_challenge_header:": &pages.WAFChallengeHeader,
+		"parked_domain:":        &pages.ParkedDomain,
+		"default_page:":         &pages.DefaultPage,
+	}
+
+	var current *[]string
+	loadedCount := 0
+
+	err := scanRules(path, func(line string, lineNumber int) {
+		if section, exists := sections[line]; exists {
+			current = section
+			return
+		}
+
+		// 移除行尾注释，统一转为小写
+		line = strings.ToLower(strings.TrimSpace(strings.Split(line, "#")[0]))
+		if line == "" || current == nil {
+			return
+		}
+		*current = append(*current, line)
+		loadedCount++
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot.Pages = pages
+	status.Entries = loadedCount
+	return nil
+}
+
+// scanRules 逐行读取规则文件，跳过空行和 # 开头的注释，行号从1开始
+func scanRules(path string, handle func(line string, lineNumber int)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		handle(line, lineNumber)
+	}
+	return scanner.Err()
+}
+
+// loadGeoIP 加载GeoIP数据库，构建日期记录为版本
+// 读入内存而不是映射文件，替换快照后旧数据库由垃圾回收释放，不影响仍在使用它的检测
+func loadGeoIP(path string, status *Status) (*geoip2.Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := geoip2.FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	status.Version = time.Unix(int64(db.Metadata().BuildEpoch), 0).Format("2006-01-02")
+	return db, nil
+}
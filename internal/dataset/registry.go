@@ -0,0 +1,292 @@
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+
+	"RealityChecker/internal/domainmatch"
+)
+
+// DefaultDir 数据文件所在目录
+const DefaultDir = "data"
+
+// Snapshot 一次加载的全部数据集
+// 加载完成后不再修改，检测阶段可以并发读取；重新加载时整体替换为新的快照
+type Snapshot struct {
+	GFWList     *domainmatch.Matcher // GFW域名列表
+	HotWebsites *domainmatch.Matcher // 热门网站
+	CDN         *CDNKeywords         // CDN特征关键字
+	Pages       *PageSignatures      // 页面内容特征
+	Country     *geoip2.Reader       // GeoIP国家数据库，未加载时为nil
+	ASN         *geoip2.Reader       // GeoIP ASN数据库（可选），未加载时为nil
+
+	LoadedAt    time.Time
+	Statuses    []Status // 各数据集的加载结果，按加载顺序
+	Fingerprint string   // 快照所用数据文件的指纹，数据文件更新并重新加载后随之变化
+}
+
+// CDNKeywords CDN特征关键字，按 cdn_keywords.txt 的节分类
+type CDNKeywords struct {
+	CNAMEStrongSuffix      *domainmatch.Matcher // CNAME记录的CDN专属后缀
+	HTTPStrongHeader       map[string]bool
+	HTTPMediumHeader       map[string]bool
+	HTTPValueCDNDomains    map[string]bool
+	ASNStrongExact         map[string]bool
+	NSHintSuffix           *domainmatch.Matcher // NS记录的CDN后缀
+	CertIssuerHint         map[string]bool
+	ExcludeServerTokens    map[string]bool
+	ExcludeKeywordsGeneric map[string]bool
+}
+
+// PageSignatures 页面内容特征，按 page_signatures.txt 的节分类，均已转为小写
+type PageSignatures struct {
+	WAFChallenge       []string // 挑战页的响应体特征
+	WAFChallengeHeader []string // 挑战页特有的响应头（头名: 值）
+	ParkedDomain       []string
+	DefaultPage        []string
+}
+
+// Status 数据集的加载结果
+type Status struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Entries  int    `json:"entries"`           // 条目数，GeoIP数据库为0
+	Version  string `json:"version,omitempty"` // GeoIP数据库的构建日期
+	Invalid  int    `json:"invalid,omitempty"` // 无法解析而忽略的规则数
+	Optional bool   `json:"optional,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Stale    bool   `json:"stale,omitempty"` // 重新加载失败，沿用之前加载的数据
+	missing  bool   // 文件不存在
+	file     string // 加载的数据文件的大小和修改时间
+}
+
+// source 一个数据集的文件和加载方式
+type source struct {
+	name     string
+	optional bool
+	load     func(path string, snapshot *Snapshot, status *Status) error // 加载到快照，并记录条目数等信息
+	keep     func(snapshot, previous *Snapshot)                          // 加载失败时沿用之前的数据
+}
+
+// sources 所有数据集
+var sources = []source{
+	{
+		name: "gfwlist.conf",
+		load: loadGFWList,
+		keep: func(s, p *Snapshot) { s.GFWList = p.GFWList },
+	},
+	{
+		name: "cdn_keywords.txt",
+		load: loadCDNKeywords,
+		keep: func(s, p *Snapshot) { s.CDN = p.CDN },
+	},
+	{
+		name: "hot_websites.txt",
+		load: loadHotWebsites,
+		keep: func(s, p *Snapshot) { s.HotWebsites = p.HotWebsites },
+	},
+	{
+		name: "page_signatures.txt",
+		load: loadPageSignatures,
+		keep: func(s, p *Snapshot) { s.Pages = p.Pages },
+	},
+	{
+		name: "Country.mmdb",
+		load: func(path string, s *Snapshot, status *Status) (err error) {
+			s.Country, err = loadGeoIP(path, status)
+			return err
+		},
+		keep: func(s, p *Snapshot) { s.Country = p.Country },
+	},
+	{
+		name:     "GeoLite2-ASN.mmdb",
+		optional: true,
+		load: func(path string, s *Snapshot, status *Status) (err error) {
+			s.ASN, err = loadGeoIP(path, status)
+			return err
+		},
+		keep: func(s, p *Snapshot) { s.ASN = p.ASN },
+	},
+}
+
+// Registry 数据集注册表
+// 数据只在首次使用和重新加载时读取，检测阶段通过快照共享
+type Registry struct {
+	dir string
+
+	mu      sync.Mutex // 串行化加载
+	current atomic.Pointer[Snapshot]
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// Default 默认数据目录的注册表，进程内共享
+func Default() *Registry {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry = NewRegistry(DefaultDir)
+	})
+	return defaultRegistry
+}
+
+// NewRegistry 创建数据集注册表
+func NewRegistry(dir string) *Registry {
+	return &Registry{dir: dir}
+}
+
+// Snapshot 当前的数据集快照，首次调用时加载
+func (r *Registry) Snapshot() *Snapshot {
+	if snapshot := r.current.Load(); snapshot != nil {
+		return snapshot
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if snapshot := r.current.Load(); snapshot != nil {
+		return snapshot
+	}
+	snapshot := r.load(nil)
+	r.current.Store(snapshot)
+	return snapshot
+}
+
+// Reload 重新加载所有数据集并原子替换快照
+// 进行中的检测继续使用旧快照；某个数据集加载失败时沿用之前的数据
+func (r *Registry) Reload() *Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := r.load(r.current.Load())
+	r.current.Store(snapshot)
+	return snapshot
+}
+
+// load 加载所有数据集，previous 不为nil时加载失败的数据集沿用其中的数据
+func (r *Registry) load(previous *Snapshot) *Snapshot {
+	snapshot := newSnapshot()
+
+	for _, src := range sources {
+		status := Status{Name: src.name, Path: filepath.Join(r.dir, src.name), Optional: src.optional}
+
+		err := src.load(status.Path, snapshot, &status)
+		switch {
+		case err == nil:
+			if info, err := os.Stat(status.Path); err == nil {
+				status.file = fmt.Sprintf("%d/%d", info.Size(), info.ModTime().UnixNano())
+			}
+		case previous != nil && previous.loaded(src.name):
+			src.keep(snapshot, previous)
+			prev, _ := previous.Status(src.name)
+			status.Entries = prev.Entries
+			status.Version = prev.Version
+			status.Invalid = prev.Invalid
+			status.file = prev.file
+			status.Error = err.Error()
+			status.Stale = true
+		default:
+			status.Entries = 0
+			status.Invalid = 0
+			status.Error = err.Error()
+			status.missing = os.IsNotExist(err)
+		}
+		snapshot.Statuses = append(snapshot.Statuses, status)
+	}
+
+	snapshot.LoadedAt = time.Now()
+	snapshot.Fingerprint = fingerprint(snapshot.Statuses)
+	return snapshot
+}
+
+// fingerprint 根据各数据集使用的文件计算指纹
+// 只与文件内容的版本有关，数据未变化时重新加载或重启后指纹相同
+func fingerprint(statuses []Status) string {
+	hash := sha256.New()
+	for _, status := range statuses {
+		fmt.Fprintf(hash, "%s=%s\n", status.Name, status.file)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// newSnapshot 创建空快照，未加载的数据集为空集合
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		GFWList:     domainmatch.New(),
+		HotWebsites: domainmatch.New(),
+		Pages:       &PageSignatures{},
+		CDN: &CDNKeywords{
+			CNAMEStrongSuffix:      domainmatch.New(),
+			HTTPStrongHeader:       make(map[string]bool),
+			HTTPMediumHeader:       make(map[string]bool),
+			HTTPValueCDNDomains:    make(map[string]bool),
+			ASNStrongExact:         make(map[string]bool),
+			NSHintSuffix:           domainmatch.New(),
+			CertIssuerHint:         make(map[string]bool),
+			ExcludeServerTokens:    make(map[string]bool),
+			ExcludeKeywordsGeneric: make(map[string]bool),
+		},
+	}
+}
+
+// Status 指定数据集的加载结果
+func (s *Snapshot) Status(name string) (Status, bool) {
+	for _, status := range s.Statuses {
+		if status.Name == name {
+			return status, true
+		}
+	}
+	return Status{}, false
+}
+
+// loaded 数据集是否有可用的数据（本次加载成功或沿用了之前的数据）
+func (s *Snapshot) loaded(name string) bool {
+	status, exists := s.Status(name)
+	return exists && (status.Error == "" || status.Stale)
+}
+
+// Errors 需要提示的加载错误，可选数据文件不存在时不提示
+func (s *Snapshot) Errors() []Status {
+	var errors []Status
+	for _, status := range s.Statuses {
+		if status.Error == "" || (status.Optional && status.missing) {
+			continue
+		}
+		errors = append(errors, status)
+	}
+	return errors
+}
+
+// Summary 各数据集的条目数，用于启动和重新加载时的提示
+func (s *Snapshot) Summary() string {
+	var parts []string
+	for _, status := range s.Statuses {
+		switch {
+		case status.Error != "" && !status.Stale:
+			switch {
+			case status.Optional && status.missing:
+				parts = append(parts, status.Name+" 未找到（可选）")
+			case status.missing:
+				parts = append(parts, status.Name+" 未找到")
+			default:
+				parts = append(parts, status.Name+" 加载失败")
+			}
+		case status.Version != "":
+			parts = append(parts, fmt.Sprintf("%s %s", status.Name, status.Version))
+		case status.Invalid > 0:
+			parts = append(parts, fmt.Sprintf("%s %d条（忽略%d条无效规则）", status.Name, status.Entries, status.Invalid))
+		default:
+			parts = append(parts, fmt.Sprintf("%s %d条", status.Name, status.Entries))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1,101 +1,44 @@
 package detectors
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"strings"
 
 	"RealityChecker/internal/types"
 )
 
 // BlockedStage 被墙检测阶段
-type BlockedStage struct {
-	gfwlist map[string]bool
-}
+// GFW域名列表来自数据集快照
+type BlockedStage struct{}
 
 // NewBlockedStage 创建被墙检测阶段
 func NewBlockedStage() *BlockedStage {
-	stage := &BlockedStage{
-		gfwlist: make(map[string]bool),
-	}
-	stage.loadGFWList()
-	return stage
+	return &BlockedStage{}
 }
 
 // Execute 执行被墙检测
-func (bs *BlockedStage) Execute(ctx *types.PipelineContext) error {
+func (bs *BlockedStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
 	// 检查是否被墙
-	isBlocked, reason := bs.checkBlocked(ctx.Domain)
-
-	ctx.Result.Blocked = &types.BlockedResult{
-		IsBlocked:      isBlocked,
-		BlockedReasons: []string{reason},
-		MatchType:      "gfwlist",
-	}
+	rule, isBlocked := datasetsOf(ctx).GFWList.Match(ctx.Domain)
 
+	reason := ""
 	if isBlocked {
-		ctx.EarlyExit = true
-		return fmt.Errorf("域名被墙（%s）", reason)
+		reason = fmt.Sprintf("仅参考黑名单，匹配 %s", rule)
 	}
-	return nil
-}
-
-// checkBlocked 检查是否被墙
-func (bs *BlockedStage) checkBlocked(domain string) (bool, string) {
-	domain = strings.ToLower(domain)
-
-	// 检查GFWList
-	if bs.gfwlist[domain] {
-		return true, "仅参考黑名单"
-	}
-
-	// 检查通配符匹配
-	parts := strings.Split(domain, ".")
-	for i := 0; i < len(parts); i++ {
-		wildcard := "*." + strings.Join(parts[i:], ".")
-		if bs.gfwlist[wildcard] {
-			return true, fmt.Sprintf("通配符匹配: %s", wildcard)
-		}
-	}
-
-	return false, ""
-}
 
-// loadGFWList 加载GFWList
-func (bs *BlockedStage) loadGFWList() {
-	file, err := os.Open("data/gfwlist.conf")
-	if err != nil {
-		return
+	partial := &types.PartialResult{
+		Blocked: &types.BlockedResult{
+			IsBlocked:      isBlocked,
+			BlockedReasons: []string{reason},
+			MatchType:      "gfwlist",
+			MatchedRule:    matchedRule(rule),
+		},
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	inPayload := false
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		if line == "payload:" {
-			inPayload = true
-			continue
-		}
-
-		if !inPayload {
-			continue
-		}
-
-		if strings.HasPrefix(line, "- '") && strings.HasSuffix(line, "'") {
-			domain := strings.TrimPrefix(line, "- '")
-			domain = strings.TrimSuffix(domain, "'")
-
-			domain = strings.TrimPrefix(domain, "+.")
-
-			if domain != "" {
-				bs.gfwlist[domain] = true
-			}
-		}
+	if isBlocked {
+		partial.AddEvidence(reason)
+		return partial, fmt.Errorf("域名被墙（%s）", reason)
 	}
+	return partial, nil
 }
 
 // CanEarlyExit 是否可以早期退出
@@ -112,3 +55,13 @@ func (bs *BlockedStage) Priority() int {
 func (bs *BlockedStage) Name() string {
 	return "blocked"
 }
+
+// Requires 依赖的结果字段
+func (bs *BlockedStage) Requires() []string {
+	return nil
+}
+
+// Produces 产出的结果字段
+func (bs *BlockedStage) Produces() []string {
+	return []string{types.FieldBlocked}
+}
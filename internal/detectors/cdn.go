@@ -1,53 +1,33 @@
 package detectors
 
 import (
-	"bufio"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
-	"os"
 	"strings"
-	"time"
 
+	"RealityChecker/internal/dataset"
+	"RealityChecker/internal/domainmatch"
 	"RealityChecker/internal/types"
 )
 
 // CDNStage CDN检测阶段
+// CDN特征关键字来自数据集快照
 type CDNStage struct {
-	cnameStrongSuffix      map[string]bool
-	httpStrongHeader       map[string]bool
-	httpMediumHeader       map[string]bool
-	httpValueCdnDomains    map[string]bool
-	asnStrongExact         map[string]bool
-	nsHintSuffix           map[string]bool
-	certIssuerHint         map[string]bool
-	excludeServerTokens    map[string]bool
-	excludeKeywordsGeneric map[string]bool
+	keywords *dataset.CDNKeywords
 }
 
-// NewCDNStage 创建CDN检测阶段
-func NewCDNStage() *CDNStage {
-	stage := &CDNStage{
-		cnameStrongSuffix:      make(map[string]bool),
-		httpStrongHeader:       make(map[string]bool),
-		httpMediumHeader:       make(map[string]bool),
-		httpValueCdnDomains:    make(map[string]bool),
-		asnStrongExact:         make(map[string]bool),
-		nsHintSuffix:           make(map[string]bool),
-		certIssuerHint:         make(map[string]bool),
-		excludeServerTokens:    make(map[string]bool),
-		excludeKeywordsGeneric: make(map[string]bool),
-	}
-	stage.loadCDNKeywords()
-	return stage
+// NewCDNStage 使用指定的CDN特征关键字创建CDN检测阶段
+func NewCDNStage(keywords *dataset.CDNKeywords) *CDNStage {
+	return &CDNStage{keywords: keywords}
 }
 
 // Execute 执行CDN检测 (已废弃 - CDN检测已合并到ComprehensiveTLSStage)
 // 保留此方法以维持接口兼容性，但不再使用
-func (cs *CDNStage) Execute(ctx *types.PipelineContext) error {
+func (cs *CDNStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
 	// 此方法已废弃，CDN检测已合并到ComprehensiveTLSStage中
-	return nil
+	return nil, nil
 }
 
 // detectCDN 检测CDN
@@ -55,13 +35,13 @@ func (cs *CDNStage) Execute(ctx *types.PipelineContext) error {
 // 高置信度方法：CNAME记录、HTTP响应头、ASN查询等
 // 中等置信度方法：NS记录、通用HTTP头等
 // 低置信度方法：证书签发者等
-func (cs *CDNStage) detectCDN(domain string, networkResult *types.NetworkResult) (bool, string, string, string) {
+func (cs *CDNStage) detectCDN(ctx *types.PipelineContext, domain string, networkResult *types.NetworkResult) (bool, string, string, string) {
 	// 高置信度检测方法（优先级顺序）
 	highConfidenceChecks := []func() (string, string){
 		func() (string, string) { return cs.checkCNAMEStrongSuffix(domain) },
 		func() (string, string) { return cs.checkHTTPStrongHeader(networkResult) },
 		func() (string, string) { return cs.checkHTTPValueCdnDomains(networkResult) },
-		func() (string, string) { return cs.checkASNStrongExact(domain) },
+		func() (string, string) { return cs.checkASNStrongExact(ctx, domain) },
 	}
 
 	// 中等置信度检测方法
@@ -72,7 +52,7 @@ func (cs *CDNStage) detectCDN(domain string, networkResult *types.NetworkResult)
 
 	// 低置信度检测方法
 	lowConfidenceChecks := []func() (string, string){
-		func() (string, string) { return cs.checkCertIssuerHint(domain) },
+		func() (string, string) { return cs.checkCertIssuerHint(ctx, domain) },
 	}
 
 	// 按置信度顺序检测
@@ -105,20 +85,10 @@ func (cs *CDNStage) checkCNAMEStrongSuffix(domain string) (string, string) {
 		return "", ""
 	}
 
-	cnameLower := strings.ToLower(cname)
-
-	// 检查CNAME记录是否包含CDN后缀
-	cnameClean := strings.TrimSuffix(cnameLower, ".")
-
-	for suffix := range cs.cnameStrongSuffix {
-		// 移除注释部分
-		cleanSuffix := strings.Split(suffix, "#")[0]
-		cleanSuffix = strings.TrimSpace(cleanSuffix)
-		suffixLower := strings.ToLower(cleanSuffix)
-		if strings.Contains(cnameClean, suffixLower) {
-			provider := cs.getProviderFromSuffix(suffix)
-			return provider, fmt.Sprintf("CNAME记录特征: CNAME记录包含%s", cleanSuffix)
-		}
+	// 检查CNAME记录是否以CDN专属后缀结尾
+	if rule, ok := cs.keywords.CNAMEStrongSuffix.Match(cname); ok {
+		provider := cs.getProviderFromSuffix(rule.Pattern)
+		return provider, fmt.Sprintf("CNAME记录特征: %s 匹配 %s", domainmatch.Normalize(cname), rule)
 	}
 
 	return "", ""
@@ -131,7 +101,7 @@ func (cs *CDNStage) checkHTTPStrongHeader(networkResult *types.NetworkResult) (s
 	}
 
 	// 检查HTTP强响应头
-	for headerName := range cs.httpStrongHeader {
+	for headerName := range cs.keywords.HTTPStrongHeader {
 		// 检查header名称
 		for respHeaderName, respHeaderValue := range networkResult.Headers {
 			if strings.EqualFold(respHeaderName, headerName) {
@@ -156,13 +126,13 @@ func (cs *CDNStage) checkHTTPStrongHeader(networkResult *types.NetworkResult) (s
 }
 
 // checkASNStrongExact 检查ASN强特征
-func (cs *CDNStage) checkASNStrongExact(domain string) (string, string) {
+func (cs *CDNStage) checkASNStrongExact(ctx *types.PipelineContext, domain string) (string, string) {
 	// TODO: 需要ASN查询功能
 	// 实际实现需要集成ASN查询库或API来查询真实的ASN信息
 	// 当前使用关键字库中的ASN列表，但需要真实的ASN查询功能
 
 	// 解析IP地址
-	ips, err := net.LookupIP(domain)
+	ips, err := lookupIP(ctx, domain)
 	if err != nil || len(ips) == 0 {
 		return "", ""
 	}
@@ -182,7 +152,7 @@ func (cs *CDNStage) checkASNStrongExact(domain string) (string, string) {
 	}
 
 	// 这里需要真实的ASN查询，暂时返回空
-	// 未来需要集成ASN查询库，然后与cs.asnStrongExact中的关键字比较
+	// 未来需要集成ASN查询库，然后与cs.keywords.ASNStrongExact中的关键字比较
 
 	return "", ""
 }
@@ -197,7 +167,7 @@ func (cs *CDNStage) checkHTTPValueCdnDomains(networkResult *types.NetworkResult)
 	for headerName, respHeaderValue := range networkResult.Headers {
 		respHeaderValueLower := strings.ToLower(respHeaderValue)
 
-		for cdnDomain := range cs.httpValueCdnDomains {
+		for cdnDomain := range cs.keywords.HTTPValueCDNDomains {
 			// 移除注释部分
 			cleanDomain := strings.Split(cdnDomain, "#")[0]
 			cleanDomain = strings.TrimSpace(cleanDomain)
@@ -221,12 +191,9 @@ func (cs *CDNStage) checkNSHintSuffix(domain string) (string, string) {
 	}
 
 	for _, ns := range nsRecords {
-		nsHost := strings.ToLower(ns.Host)
-		for hint := range cs.nsHintSuffix {
-			if strings.Contains(nsHost, strings.ToLower(hint)) {
-				provider := cs.getProviderFromNShint(hint)
-				return provider, fmt.Sprintf("NS记录: %s", ns.Host)
-			}
+		if rule, ok := cs.keywords.NSHintSuffix.Match(ns.Host); ok {
+			provider := cs.getProviderFromNShint(rule.Pattern)
+			return provider, fmt.Sprintf("NS记录: %s 匹配 %s", ns.Host, rule)
 		}
 	}
 
@@ -234,16 +201,11 @@ func (cs *CDNStage) checkNSHintSuffix(domain string) (string, string) {
 }
 
 // checkCertIssuerHint 检查证书签发者提示
-func (cs *CDNStage) checkCertIssuerHint(domain string) (string, string) {
-	const (
-		certPort    = ":443"
-		certTimeout = 6 * time.Second // 进一步增加CDN证书检测超时时间，减少误判
-	)
+func (cs *CDNStage) checkCertIssuerHint(ctx *types.PipelineContext, domain string) (string, string) {
+	const certPort = ":443"
 
 	// 建立TLS连接获取证书
-	conn, err := tls.DialWithDialer(&net.Dialer{
-		Timeout: certTimeout,
-	}, "tcp", domain+certPort, &tls.Config{
+	conn, err := dialTLS(ctx, domain+certPort, &tls.Config{
 		ServerName: domain,
 	})
 	if err != nil {
@@ -259,7 +221,7 @@ func (cs *CDNStage) checkCertIssuerHint(domain string) (string, string) {
 	issuerLower := strings.ToLower(issuer)
 
 	// 使用关键字库中的证书签发者列表
-	for certIssuer := range cs.certIssuerHint {
+	for certIssuer := range cs.keywords.CertIssuerHint {
 		// 移除注释部分
 		cleanIssuer := strings.Split(certIssuer, "#")[0]
 		cleanIssuer = strings.TrimSpace(cleanIssuer)
@@ -280,7 +242,7 @@ func (cs *CDNStage) checkHTTPMediumHeader(networkResult *types.NetworkResult) (s
 	}
 
 	// 检查HTTP中等响应头
-	for headerName := range cs.httpMediumHeader {
+	for headerName := range cs.keywords.HTTPMediumHeader {
 		for respHeaderName, respHeaderValue := range networkResult.Headers {
 			if strings.EqualFold(respHeaderName, headerName) {
 				provider := cs.getProviderFromHeader(headerName)
@@ -313,67 +275,6 @@ func (cs *CDNStage) getProviderFromHeader(header string) string {
 	return "CDN"
 }
 
-// loadCDNKeywords 加载CDN关键词
-func (cs *CDNStage) loadCDNKeywords() {
-	file, err := os.Open("data/cdn_keywords.txt")
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	currentSection := ""
-	loadedCount := 0
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// 检查是否是节标题
-		if strings.HasSuffix(line, ":") {
-			currentSection = line
-			continue
-		}
-
-		// 根据节标题分类加载
-		switch currentSection {
-		case "cname_strong_suffix:":
-			cs.cnameStrongSuffix[line] = true
-			loadedCount++
-		case "http_strong_header:":
-			cs.httpStrongHeader[line] = true
-			loadedCount++
-		case "http_medium_header:":
-			cs.httpMediumHeader[line] = true
-			loadedCount++
-		case "http_value_cdn_domains:":
-			cs.httpValueCdnDomains[line] = true
-			loadedCount++
-		case "asn_strong_exact:":
-			cs.asnStrongExact[line] = true
-			loadedCount++
-		case "ns_hint_suffix:":
-			cs.nsHintSuffix[line] = true
-			loadedCount++
-		case "cert_issuer_hint:":
-			cs.certIssuerHint[line] = true
-			loadedCount++
-		case "exclude_server_tokens:":
-			cs.excludeServerTokens[line] = true
-		case "exclude_keywords_generic:":
-			cs.excludeKeywordsGeneric[line] = true
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return
-	}
-
-	// 成功加载关键字（静默模式）
-}
-
 // CanEarlyExit 是否可以早期退出
 func (cs *CDNStage) CanEarlyExit() bool {
 	return false
@@ -389,11 +290,21 @@ func (cs *CDNStage) Name() string {
 	return "cdn"
 }
 
+// Requires 依赖的结果字段
+func (cs *CDNStage) Requires() []string {
+	return nil // 已废弃，不参与流水线
+}
+
+// Produces 产出的结果字段
+func (cs *CDNStage) Produces() []string {
+	return nil
+}
+
 // detectCDNWithManager 使用连接管理器检测CDN
 func (cs *CDNStage) detectCDNWithManager(ctx *types.PipelineContext, domain string, networkResult *types.NetworkResult) (bool, string, string, string) {
 	// 如果连接管理器不可用，回退到直接连接
 	if ctx.Connections == nil {
-		return cs.detectCDN(domain, networkResult)
+		return cs.detectCDN(ctx, domain, networkResult)
 	}
 
 	// 使用连接管理器获取TLS连接
@@ -402,13 +313,13 @@ func (cs *CDNStage) detectCDNWithManager(ctx *types.PipelineContext, domain stri
 		ReturnConnection(string, net.Conn)
 	})
 	if !ok {
-		return cs.detectCDN(domain, networkResult)
+		return cs.detectCDN(ctx, domain, networkResult)
 	}
 
 	tlsConn, err := connMgr.GetTLSConnection(ctx.Context, domain)
 	if err != nil {
 		// 如果连接失败，回退到原有逻辑
-		return cs.detectCDN(domain, networkResult)
+		return cs.detectCDN(ctx, domain, networkResult)
 	}
 	defer connMgr.ReturnConnection(domain, tlsConn)
 
@@ -429,5 +340,5 @@ func (cs *CDNStage) detectCDNWithManager(ctx *types.PipelineContext, domain stri
 	}
 
 	// 使用增强的网络结果进行CDN检测
-	return cs.detectCDN(domain, enhancedNetworkResult)
+	return cs.detectCDN(ctx, domain, enhancedNetworkResult)
 }
@@ -0,0 +1,128 @@
+package detectors
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+
+	"RealityChecker/internal/types"
+)
+
+// checkCertificateConsistency 多次握手比较叶子证书
+// 负载均衡后的不同后端可能返回不同的证书，Reality客户端会因此间歇性失败
+func (cts *ComprehensiveTLSStage) checkCertificateConsistency(ctx *types.PipelineContext, domain string, handshakes int) []types.ObservedCertificate {
+	connMgr, ok := ctx.Connections.(interface {
+		GetTLSConnectionToIP(context.Context, string, string) (*tls.Conn, error)
+		CloseTLSConnection(*tls.Conn)
+	})
+	if !ok {
+		return nil
+	}
+
+	ips, err := lookupIP(ctx, domain)
+	if err != nil || len(ips) == 0 {
+		return nil
+	}
+
+	// 按指纹归并观察到的证书，保持首次出现的顺序
+	var observed []*types.ObservedCertificate
+	byFingerprint := make(map[string]*types.ObservedCertificate)
+
+	for i := 0; i < handshakes; i++ {
+		// 轮流使用不同的解析地址
+		ip := ips[i%len(ips)].String()
+
+		conn, err := connMgr.GetTLSConnectionToIP(ctx.Context, ip, domain)
+		if err != nil {
+			continue
+		}
+		state := conn.ConnectionState()
+		connMgr.CloseTLSConnection(conn)
+
+		fingerprint := leafFingerprint(state)
+		if fingerprint == "" {
+			continue
+		}
+
+		cert, exists := byFingerprint[fingerprint]
+		if !exists {
+			leaf := state.PeerCertificates[0]
+			cert = &types.ObservedCertificate{
+				SHA256:  fingerprint,
+				Issuer:  leaf.Issuer.String(),
+				KeyType: certKeyType(leaf),
+				SANs:    leaf.DNSNames,
+			}
+			byFingerprint[fingerprint] = cert
+			observed = append(observed, cert)
+		}
+		cert.Count++
+		if !containsString(cert.IPs, ip) {
+			cert.IPs = append(cert.IPs, ip)
+		}
+	}
+
+	result := make([]types.ObservedCertificate, len(observed))
+	for i, cert := range observed {
+		result[i] = *cert
+	}
+	return result
+}
+
+// describeCertificateDifferences 描述不同证书之间的差异维度
+func describeCertificateDifferences(certs []types.ObservedCertificate) string {
+	issuers := make(map[string]bool)
+	keyTypes := make(map[string]bool)
+	sanSets := make(map[string]bool)
+	for _, cert := range certs {
+		issuers[cert.Issuer] = true
+		keyTypes[cert.KeyType] = true
+		sans := append([]string(nil), cert.SANs...)
+		sort.Strings(sans)
+		sanSets[strings.Join(sans, ",")] = true
+	}
+
+	var differences []string
+	if len(issuers) > 1 {
+		differences = append(differences, "签发者")
+	}
+	if len(keyTypes) > 1 {
+		differences = append(differences, "密钥类型")
+	}
+	if len(sanSets) > 1 {
+		differences = append(differences, "SAN")
+	}
+	if len(differences) == 0 {
+		return "仅指纹不同"
+	}
+	return strings.Join(differences, "、") + "不同"
+}
+
+// certKeyType 证书公钥类型描述，例如 RSA-2048、ECDSA-P-256
+func certKeyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
+
+// containsString 判断切片是否包含字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
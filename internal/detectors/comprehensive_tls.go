@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"net"
 	"strings"
 	"time"
 
@@ -21,28 +20,71 @@ func NewComprehensiveTLSStage() *ComprehensiveTLSStage {
 }
 
 // Execute 执行综合TLS检测
-func (cts *ComprehensiveTLSStage) Execute(ctx *types.PipelineContext) error {
+func (cts *ComprehensiveTLSStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
 	// 使用最终域名进行TLS检测
 	finalDomain := ctx.Domain
 	if ctx.Result.Network != nil && ctx.Result.Network.FinalDomain != "" {
 		finalDomain = ctx.Result.Network.FinalDomain
 	}
 
-	// 执行综合TLS检测
-	tlsResult := cts.performComprehensiveTLSDetection(ctx, finalDomain)
+	// 执行综合TLS检测，重定向到同一最终域名的输入共用一次探测
+	// HTTP响应头未识别出CDN时同时通过证书检测CDN
+	detectCDN := ctx.Result.CDN == nil
+	key := "tls|" + strings.ToLower(finalDomain)
+	if detectCDN {
+		key += "|cdn"
+	}
+	value, shared := sharedProbe(ctx, key, func() interface{} {
+		probe := &tlsProbe{ComprehensiveTLSResult: cts.performComprehensiveTLSDetection(ctx, finalDomain)}
+		if detectCDN {
+			probe.CDN = cts.performCDNDetection(probe.Certificate)
+		}
+		return probe
+	})
+	probe := value.(*tlsProbe)
+	tlsResult := probe.ComprehensiveTLSResult
 
 	// 设置所有TLS相关结果
-	ctx.Result.TLS = tlsResult.TLS
-	ctx.Result.SNI = tlsResult.SNI
-	ctx.Result.Certificate = tlsResult.Certificate
+	partial := &types.PartialResult{
+		TLS:          tlsResult.TLS,
+		SNI:          tlsResult.SNI,
+		Certificate:  tlsResult.Certificate,
+		ProbedTarget: strings.ToLower(finalDomain),
+		SharedProbe:  shared,
+	}
+	if shared {
+		partial.AddEvidence("复用 " + finalDomain + " 的TLS探测结果")
+	}
+
+	if tlsResult.TLS != nil {
+		partial.AddEvidence(fmt.Sprintf("TLS1.3=%t X25519=%t HTTP/2=%t 握手%dms", tlsResult.TLS.SupportsTLS13,
+			tlsResult.TLS.SupportsX25519, tlsResult.TLS.SupportsHTTP2, tlsResult.TLS.HandshakeTime.Milliseconds()))
+	}
+	if certificate := tlsResult.Certificate; certificate != nil && certificate.Issuer != "" {
+		partial.AddEvidence(fmt.Sprintf("证书签发者 %s，剩余 %d 天", certificate.Issuer, certificate.DaysUntilExpiry))
+	}
+
+	if certificate := tlsResult.Certificate; certificate != nil && certificate.Varies {
+		partial.AddWarning(fmt.Sprintf("证书不一致: %d次握手观察到%d张不同的叶子证书（%s）",
+			ctx.Config.Detection.CertHandshakes, len(certificate.ObservedCertificates),
+			describeCertificateDifferences(certificate.ObservedCertificates)))
+	}
 
 	// 在TLS检测完成后，检查是否需要CDN检测
-	if ctx.Result.CDN == nil {
-		cdnResult := cts.performCDNDetection(ctx, finalDomain)
-		ctx.Result.CDN = cdnResult
+	if detectCDN {
+		partial.CDN = probe.CDN
+		if partial.CDN != nil && partial.CDN.IsCDN {
+			partial.AddEvidence(fmt.Sprintf("证书识别CDN: %s（%s）", partial.CDN.CDNProvider, partial.CDN.Evidence))
+		}
 	}
 
-	return nil
+	return partial, nil
+}
+
+// tlsProbe 可在重定向到同一最终域名的输入之间共用的TLS探测结果
+type tlsProbe struct {
+	*ComprehensiveTLSResult
+	CDN *types.CDNResult // 证书识别的CDN，HTTP响应头已识别出CDN时不检测
 }
 
 // ComprehensiveTLSResult 综合TLS检测结果
@@ -67,7 +109,12 @@ func (cts *ComprehensiveTLSStage) performComprehensiveTLSDetection(ctx *types.Pi
 
 	// 第一次握手：正常TLS握手，检测TLS1.3、HTTP/2、SNI、证书
 	startTime := time.Now()
-	normalConn, err := connMgr.GetTLSConnection(ctx.Context, domain)
+	var normalConn *tls.Conn
+	err := withRetry(ctx, "TLS握手 "+domain, func() error {
+		var err error
+		normalConn, err = connMgr.GetTLSConnection(ctx.Context, domain)
+		return err
+	})
 	if err != nil {
 		// 连接失败时，normalConn可能为nil，不需要关闭
 		return cts.createFailedResult(startTime)
@@ -89,11 +136,20 @@ func (cts *ComprehensiveTLSStage) performComprehensiveTLSDetection(ctx *types.Pi
 	}
 
 	// 第二次握手：强制X25519握手，检测X25519支持
-	supportsX25519 := cts.checkX25519Support(domain, 3*time.Second)
+	supportsX25519 := cts.checkX25519Support(ctx, domain)
 
 	// 更新TLS结果中的X25519支持
 	firstResult.TLS.SupportsX25519 = supportsX25519
 
+	// 可选：多次握手检查证书是否一致
+	if ctx.Config != nil && ctx.Config.Detection.CertHandshakes > 1 {
+		observed := cts.checkCertificateConsistency(ctx, domain, ctx.Config.Detection.CertHandshakes)
+		if len(observed) > 1 {
+			firstResult.Certificate.Varies = true
+			firstResult.Certificate.ObservedCertificates = observed
+		}
+	}
+
 	return firstResult
 }
 
@@ -204,7 +260,7 @@ func (cts *ComprehensiveTLSStage) createFailedResult(startTime time.Time) *Compr
 }
 
 // checkX25519Support 检查X25519支持（正确的检测方法）
-func (cts *ComprehensiveTLSStage) checkX25519Support(domain string, timeout time.Duration) bool {
+func (cts *ComprehensiveTLSStage) checkX25519Support(ctx *types.PipelineContext, domain string) bool {
 	const port = ":443"
 
 	// 专门做一次"仅X25519"的握手
@@ -216,10 +272,12 @@ func (cts *ComprehensiveTLSStage) checkX25519Support(domain string, timeout time
 		MaxVersion:       tls.VersionTLS13,
 	}
 
-	conn, err := tls.DialWithDialer(&net.Dialer{
-		Timeout: timeout,
-	}, "tcp", domain+port, x25519Config)
-
+	var conn *tls.Conn
+	err := withRetry(ctx, "X25519握手 "+domain, func() error {
+		var err error
+		conn, err = dialTLS(ctx, domain+port, x25519Config)
+		return err
+	})
 	if err != nil {
 		// X25519握手失败，说明不支持X25519
 		return false
@@ -248,6 +306,16 @@ func (cts *ComprehensiveTLSStage) Name() string {
 	return "comprehensive_tls"
 }
 
+// Requires 依赖的结果字段
+func (cts *ComprehensiveTLSStage) Requires() []string {
+	return []string{types.FieldNetwork, types.FieldHTTPCDN, types.FieldLocationCheck} // 已有HTTP CDN结果时不再做证书CDN检测，最终域名位于国内时不再探测
+}
+
+// Produces 产出的结果字段
+func (cts *ComprehensiveTLSStage) Produces() []string {
+	return []string{types.FieldTLS, types.FieldCertCDN}
+}
+
 // checkCriticalRequirements 检查关键要求
 func (cts *ComprehensiveTLSStage) checkCriticalRequirements(result *ComprehensiveTLSResult) bool {
 	// 检查TLS1.3支持
@@ -274,12 +342,13 @@ func (cts *ComprehensiveTLSStage) checkCriticalRequirements(result *Comprehensiv
 }
 
 // performCDNDetection 执行证书CDN检测
-func (cts *ComprehensiveTLSStage) performCDNDetection(ctx *types.PipelineContext, domain string) *types.CDNResult {
+// certificate 为本阶段握手得到的证书，流水线上下文中的结果此时还没有证书
+func (cts *ComprehensiveTLSStage) performCDNDetection(certificate *types.CertificateResult) *types.CDNResult {
 	// 只执行证书相关的CDN检测（低置信度）
 	// 使用已有的证书信息，避免重复TLS连接
-	if ctx.Result.Certificate != nil {
+	if certificate != nil {
 		// 检查证书签发者
-		issuer := ctx.Result.Certificate.Issuer
+		issuer := certificate.Issuer
 		issuerLower := strings.ToLower(issuer)
 
 		// 检查是否包含CDN特征
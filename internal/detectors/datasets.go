@@ -0,0 +1,28 @@
+package detectors
+
+import (
+	"RealityChecker/internal/dataset"
+	"RealityChecker/internal/domainmatch"
+	"RealityChecker/internal/types"
+)
+
+// datasetsOf 本次检测使用的数据集快照，流水线未提供时使用默认注册表的当前快照
+func datasetsOf(ctx *types.PipelineContext) *dataset.Snapshot {
+	if snapshot, ok := ctx.Datasets.(*dataset.Snapshot); ok && snapshot != nil {
+		return snapshot
+	}
+	return dataset.Default().Snapshot()
+}
+
+// matchedRule 转换为结果中记录的规则来源
+func matchedRule(rule *domainmatch.Rule) *types.MatchedRule {
+	if rule == nil {
+		return nil
+	}
+	return &types.MatchedRule{
+		Type:    string(rule.Type),
+		Pattern: rule.Pattern,
+		File:    rule.File,
+		Line:    rule.Line,
+	}
+}
@@ -0,0 +1,69 @@
+package detectors
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"RealityChecker/internal/network"
+	"RealityChecker/internal/types"
+)
+
+// sharedNetwork 连接管理器提供的共享DNS缓存和拨号
+// 同一目标的各阶段经由它解析和建立连接，只发起一次DNS查询
+type sharedNetwork interface {
+	LookupIP(ctx context.Context, host string) ([]net.IP, error)
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+	Transport() *http.Transport
+}
+
+// networkOf 获取共享网络，连接管理器不可用时返回 nil
+func networkOf(ctx *types.PipelineContext) sharedNetwork {
+	if shared, ok := ctx.Connections.(sharedNetwork); ok {
+		return shared
+	}
+	return nil
+}
+
+// lookupIP 使用检测上下文解析域名
+func lookupIP(ctx *types.PipelineContext, domain string) ([]net.IP, error) {
+	lookupCtx, cancel := context.WithTimeout(requestContext(ctx), operationTimeout(ctx))
+	defer cancel()
+	if shared := networkOf(ctx); shared != nil {
+		return shared.LookupIP(lookupCtx, domain)
+	}
+	return net.DefaultResolver.LookupIP(lookupCtx, "ip", domain)
+}
+
+// dialContext 使用检测上下文建立TCP连接
+func dialContext(ctx *types.PipelineContext, address string) (net.Conn, error) {
+	dialCtx, cancel := context.WithTimeout(requestContext(ctx), operationTimeout(ctx))
+	defer cancel()
+	if shared := networkOf(ctx); shared != nil {
+		return shared.DialContext(dialCtx, "tcp", address)
+	}
+	if ctx.Config != nil {
+		// 连接管理器不可用时同样遵守代理和源地址配置
+		return network.NewDialer(&ctx.Config.Network).DialContext(dialCtx, "tcp", address)
+	}
+	dialer := &net.Dialer{Timeout: operationTimeout(ctx)}
+	return dialer.DialContext(dialCtx, "tcp", address)
+}
+
+// dialTLS 使用检测上下文建立TLS连接并完成握手
+func dialTLS(ctx *types.PipelineContext, address string, config *tls.Config) (*tls.Conn, error) {
+	rawConn, err := dialContext(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	handshakeCtx, cancel := context.WithTimeout(requestContext(ctx), operationTimeout(ctx))
+	defer cancel()
+	conn := tls.Client(rawConn, config)
+	if err := conn.HandshakeContext(handshakeCtx); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
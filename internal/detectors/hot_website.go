@@ -1,29 +1,24 @@
 package detectors
 
 import (
-	"bufio"
-	"os"
+	"fmt"
 	"strings"
 
+	"RealityChecker/internal/domainmatch"
 	"RealityChecker/internal/types"
 )
 
 // HotWebsiteStage 热门网站检测阶段
-type HotWebsiteStage struct {
-	hotWebsites map[string]bool
-}
+// 热门网站列表来自数据集快照
+type HotWebsiteStage struct{}
 
 // NewHotWebsiteStage 创建热门网站检测阶段
 func NewHotWebsiteStage() *HotWebsiteStage {
-	stage := &HotWebsiteStage{
-		hotWebsites: make(map[string]bool),
-	}
-	stage.loadHotWebsites()
-	return stage
+	return &HotWebsiteStage{}
 }
 
 // Execute 执行热门网站检测
-func (hws *HotWebsiteStage) Execute(ctx *types.PipelineContext) error {
+func (hws *HotWebsiteStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
 
 	// 使用最终域名进行热门网站检测
 	finalDomain := ctx.Domain
@@ -32,107 +27,32 @@ func (hws *HotWebsiteStage) Execute(ctx *types.PipelineContext) error {
 	}
 
 	// 检测是否为热门网站
-	isHotWebsite := hws.detectHotWebsite(finalDomain)
-
-	// 更新CDN结果
-	if ctx.Result.CDN != nil {
-		ctx.Result.CDN.IsHotWebsite = isHotWebsite
-	} else {
-		ctx.Result.CDN = &types.CDNResult{
-			IsHotWebsite: isHotWebsite,
-		}
-	}
+	rule, isHotWebsite := hws.detectHotWebsite(datasetsOf(ctx).HotWebsites, finalDomain)
 
 	// 热门网站检测只是信息性的，不影响适合性判断
 	// 热门网站只是建议不推荐，但不是硬性要求
-
-	return nil
-}
-
-// detectHotWebsite 检测热门网站
-func (hws *HotWebsiteStage) detectHotWebsite(domain string) bool {
-	domain = strings.ToLower(domain)
-
-	// 1. 精确匹配
-	if hws.hotWebsites[domain] {
-		return true
+	// 检测结果由流水线合并到CDN结果中
+	partial := &types.PartialResult{HotWebsite: &isHotWebsite}
+	if isHotWebsite {
+		partial.AddEvidence(fmt.Sprintf("%s 在热门网站列表中，匹配 %s", finalDomain, rule))
 	}
-
-	// 2. 通配符匹配
-	if hws.matchWildcard(domain) {
-		return true
-	}
-
-	// 3. www.前缀处理
-	if strings.HasPrefix(domain, "www.") {
-		domainWithoutWWW := domain[4:]
-		// 精确匹配
-		if hws.hotWebsites[domainWithoutWWW] {
-			return true
-		}
-		// 通配符匹配
-		if hws.matchWildcard(domainWithoutWWW) {
-			return true
-		}
-	} else {
-		domainWithWWW := "www." + domain
-		// 精确匹配
-		if hws.hotWebsites[domainWithWWW] {
-			return true
-		}
-		// 通配符匹配
-		if hws.matchWildcard(domainWithWWW) {
-			return true
-		}
-	}
-
-	return false
+	return partial, nil
 }
 
-// matchWildcard 通配符匹配
-func (hws *HotWebsiteStage) matchWildcard(domain string) bool {
-	// 遍历所有热门网站模式
-	for pattern := range hws.hotWebsites {
-		if strings.HasPrefix(pattern, "*.") {
-			// 提取通配符的基础域名
-			baseDomain := pattern[2:] // 去掉 "*."
-
-			// 检查域名是否匹配通配符模式
-			if hws.isSubdomain(domain, baseDomain) {
-				return true
-			}
-		}
-	}
-	return false
-}
+// detectHotWebsite 检测热门网站，返回命中的规则
+// 列表中的 example.com 和 www.example.com 视为同一网站
+func (hws *HotWebsiteStage) detectHotWebsite(hotWebsites *domainmatch.Matcher, domain string) (*domainmatch.Rule, bool) {
+	domain = domainmatch.Normalize(domain)
 
-// isSubdomain 检查是否为子域名
-func (hws *HotWebsiteStage) isSubdomain(domain, baseDomain string) bool {
-	// 1. 直接匹配：domain == baseDomain
-	if domain == baseDomain {
-		return true
+	if rule, ok := hotWebsites.Match(domain); ok {
+		return rule, true
 	}
 
-	// 2. 子域名匹配：domain 以 "." + baseDomain 结尾
-	suffix := "." + baseDomain
-	return strings.HasSuffix(domain, suffix)
-}
-
-// loadHotWebsites 加载热门网站列表
-func (hws *HotWebsiteStage) loadHotWebsites() {
-	file, err := os.Open("data/hot_websites.txt")
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			hws.hotWebsites[strings.ToLower(line)] = true
-		}
+	// www.前缀处理
+	if strings.HasPrefix(domain, "www.") {
+		return hotWebsites.Match(domain[4:])
 	}
+	return hotWebsites.Match("www." + domain)
 }
 
 // CanEarlyExit 是否可以早期退出
@@ -149,3 +69,13 @@ func (hws *HotWebsiteStage) Priority() int {
 func (hws *HotWebsiteStage) Name() string {
 	return "hot_website"
 }
+
+// Requires 依赖的结果字段
+func (hws *HotWebsiteStage) Requires() []string {
+	return []string{types.FieldNetwork, types.FieldCertCDN} // 热门网站标记写入CDN结果
+}
+
+// Produces 产出的结果字段
+func (hws *HotWebsiteStage) Produces() []string {
+	return []string{types.FieldHotWebsite}
+}
@@ -0,0 +1,44 @@
+package detectors
+
+import (
+	"context"
+	"net/http"
+
+	"RealityChecker/internal/types"
+)
+
+// 模拟浏览器的请求头
+const (
+	probeUserAgent      = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36"
+	probeAcceptHeader   = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	probeAcceptLanguage = "en-US,en;q=0.9"
+)
+
+// newProbeClient 创建探测用HTTP客户端，禁用自动重定向
+// 连接管理器可用时复用其共享传输，经由共享DNS缓存建立连接
+func newProbeClient(ctx *types.PipelineContext) *http.Client {
+	var transport http.RoundTripper
+	if shared := networkOf(ctx); shared != nil {
+		transport = shared.Transport()
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   operationTimeout(ctx),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// newProbeRequest 创建带浏览器请求头的GET请求，请求随上下文取消
+func newProbeRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", probeUserAgent)
+	req.Header.Set("Accept", probeAcceptHeader)
+	req.Header.Set("Accept-Language", probeAcceptLanguage)
+	return req, nil
+}
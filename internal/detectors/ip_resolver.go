@@ -1,10 +1,8 @@
 package detectors
 
 import (
-	"context"
 	"fmt"
 	"net"
-	"time"
 
 	"RealityChecker/internal/types"
 )
@@ -18,63 +16,57 @@ func NewIPResolverStage() *IPResolverStage {
 }
 
 // Execute 执行IP解析
-func (irs *IPResolverStage) Execute(ctx *types.PipelineContext) error {
+func (irs *IPResolverStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
 
 	// 解析IP地址
-	ip, err := irs.resolveIP(ctx.Domain)
+	var ip string
+	err := withRetry(ctx, "DNS解析 "+ctx.Domain, func() error {
+		var err error
+		ip, err = irs.resolveIP(ctx, ctx.Domain)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("IP解析失败: %v", err)
+		return nil, fmt.Errorf("IP解析失败: %v", err)
 	}
 
 	// 快速连通性测试
-	if !irs.quickConnectivityTest(ip) {
-		return fmt.Errorf("网络不可达")
+	if !irs.quickConnectivityTest(ctx, ip) {
+		return nil, fmt.Errorf("网络不可达")
 	}
 
-	// 设置IP地址到Location结果中
-	if ctx.Result.Location == nil {
-		ctx.Result.Location = &types.LocationResult{}
-	}
-	ctx.Result.Location.IPAddress = ip
-
-	return nil
+	// IP地址由流水线合并到Location结果中
+	partial := &types.PartialResult{IPAddress: ip}
+	partial.AddEvidence("解析地址: " + ip)
+	return partial, nil
 }
 
 // quickConnectivityTest 快速连通性测试
-func (irs *IPResolverStage) quickConnectivityTest(ip string) bool {
-	// 测试HTTPS端口443的连通性
-	conn, err := net.DialTimeout("tcp", ip+":443", 2*time.Second)
-	if err != nil {
-		// 如果HTTPS不可达，尝试HTTP端口80
-		conn, err = net.DialTimeout("tcp", ip+":80", 2*time.Second)
-		if err != nil {
-			return false
+func (irs *IPResolverStage) quickConnectivityTest(ctx *types.PipelineContext, ip string) bool {
+	// 先测试HTTPS端口443，不可达时尝试HTTP端口80
+	for _, port := range []string{"443", "80"} {
+		var conn net.Conn
+		err := withRetry(ctx, "TCP连接 "+net.JoinHostPort(ip, port), func() error {
+			var err error
+			conn, err = dialContext(ctx, net.JoinHostPort(ip, port))
+			return err
+		})
+		if err == nil {
+			conn.Close()
+			return true
 		}
 	}
-	conn.Close()
-	return true
+	return false
 }
 
 // resolveIP 解析IP地址
-func (irs *IPResolverStage) resolveIP(domain string) (string, error) {
+func (irs *IPResolverStage) resolveIP(ctx *types.PipelineContext, domain string) (string, error) {
 	// 检查是否已经是IP地址
 	if net.ParseIP(domain) != nil {
 		return domain, nil
 	}
 
-	// 使用自定义DNS解析器，设置更短的超时
-	resolver := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: 2 * time.Second, // DNS查询超时2秒
-			}
-			return d.DialContext(ctx, network, address)
-		},
-	}
-
-	// 解析域名
-	ips, err := resolver.LookupIPAddr(context.Background(), domain)
+	// 经由共享DNS缓存解析域名，后续阶段连接时复用结果
+	ips, err := lookupIP(ctx, domain)
 	if err != nil {
 		return "", err
 	}
@@ -84,14 +76,14 @@ func (irs *IPResolverStage) resolveIP(domain string) (string, error) {
 	}
 
 	// 优先选择IPv4地址
-	for _, ipAddr := range ips {
-		if ipAddr.IP.To4() != nil {
-			return ipAddr.IP.String(), nil
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String(), nil
 		}
 	}
 
 	// 如果没有IPv4，使用IPv6
-	return ips[0].IP.String(), nil
+	return ips[0].String(), nil
 }
 
 // CanEarlyExit 是否可以早期退出
@@ -108,3 +100,13 @@ func (irs *IPResolverStage) Priority() int {
 func (irs *IPResolverStage) Name() string {
 	return "ip_resolver"
 }
+
+// Requires 依赖的结果字段
+func (irs *IPResolverStage) Requires() []string {
+	return []string{types.FieldBlocked} // 解析原始域名，地理位置检测先于所有HTTP探测
+}
+
+// Produces 产出的结果字段
+func (irs *IPResolverStage) Produces() []string {
+	return []string{types.FieldResolvedIP}
+}
@@ -10,46 +10,52 @@ import (
 )
 
 // LocationStage 地理位置检测阶段
-type LocationStage struct {
-	geoipDB *geoip2.Reader
-}
+// GeoIP数据库来自数据集快照
+type LocationStage struct{}
 
 // NewLocationStage 创建地理位置检测阶段
 func NewLocationStage() *LocationStage {
-	stage := &LocationStage{}
-	stage.loadGeoIPDatabase()
-	return stage
+	return &LocationStage{}
 }
 
 // Execute 执行地理位置检测
-func (ls *LocationStage) Execute(ctx *types.PipelineContext) error {
-
-	// 解析IP地址
-	ip, err := ls.resolveIP(ctx.Domain)
-	if err != nil {
-		return fmt.Errorf("IP解析失败: %v", err)
+func (ls *LocationStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
+
+	// 优先使用IP解析阶段的结果，避免重复解析
+	var ip string
+	if ctx.Result.Location != nil && ctx.Result.Location.IPAddress != "" {
+		ip = ctx.Result.Location.IPAddress
+	} else {
+		resolved, err := ls.resolveIP(ctx, ctx.Domain)
+		if err != nil {
+			return nil, fmt.Errorf("IP解析失败: %v", err)
+		}
+		ip = resolved
 	}
 
 	// 获取地理位置
-	country, isDomestic := ls.getLocation(ip)
-
-	ctx.Result.Location = &types.LocationResult{
-		Country:    country,
-		IsDomestic: isDomestic,
-		IPAddress:  ip,
+	country, isDomestic := ls.getLocation(datasetsOf(ctx).Country, ip)
+
+	partial := &types.PartialResult{
+		Location: &types.LocationResult{
+			Country:    country,
+			IsDomestic: isDomestic,
+			IPAddress:  ip,
+		},
 	}
 
+	partial.AddEvidence(fmt.Sprintf("%s 位于 %s", ip, country))
+
 	if isDomestic {
-		ctx.EarlyExit = true
-		return fmt.Errorf("国内网站（仅参考GeoIP）")
+		return partial, fmt.Errorf("国内网站（仅参考GeoIP）")
 	}
 
-	return nil
+	return partial, nil
 }
 
 // resolveIP 解析IP地址
-func (ls *LocationStage) resolveIP(domain string) (string, error) {
-	ips, err := net.LookupIP(domain)
+func (ls *LocationStage) resolveIP(ctx *types.PipelineContext, domain string) (string, error) {
+	ips, err := lookupIP(ctx, domain)
 	if err != nil {
 		return "", err
 	}
@@ -69,12 +75,12 @@ func (ls *LocationStage) resolveIP(domain string) (string, error) {
 }
 
 // getLocation 获取地理位置
-func (ls *LocationStage) getLocation(ip string) (string, bool) {
+func (ls *LocationStage) getLocation(geoipDB *geoip2.Reader, ip string) (string, bool) {
 	// 注意：这里传入的是IP地址，不是域名，所以不需要检查域名特征
 
 	// 使用GeoIP数据库
-	if ls.geoipDB != nil {
-		record, err := ls.geoipDB.Country(net.ParseIP(ip))
+	if geoipDB != nil {
+		record, err := geoipDB.Country(net.ParseIP(ip))
 		if err == nil {
 			country := record.Country.Names["zh-CN"]
 			if country == "" {
@@ -92,15 +98,6 @@ func (ls *LocationStage) getLocation(ip string) (string, bool) {
 	return "未知", false
 }
 
-// loadGeoIPDatabase 加载GeoIP数据库
-func (ls *LocationStage) loadGeoIPDatabase() {
-	db, err := geoip2.Open("data/Country.mmdb")
-	if err != nil {
-		return
-	}
-	ls.geoipDB = db
-}
-
 // CanEarlyExit 是否可以早期退出
 func (ls *LocationStage) CanEarlyExit() bool {
 	return true
@@ -115,3 +112,13 @@ func (ls *LocationStage) Priority() int {
 func (ls *LocationStage) Name() string {
 	return "location"
 }
+
+// Requires 依赖的结果字段
+func (ls *LocationStage) Requires() []string {
+	return []string{types.FieldResolvedIP}
+}
+
+// Produces 产出的结果字段
+func (ls *LocationStage) Produces() []string {
+	return []string{types.FieldLocation}
+}
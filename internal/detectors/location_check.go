@@ -1,11 +1,15 @@
 package detectors
 
 import (
+	"fmt"
+	"strings"
+
 	"RealityChecker/internal/types"
 )
 
 // LocationCheckStage 地理位置检查阶段
-// 检查IP地理位置是否符合Reality要求
+// 地理位置检测在HTTP探测之前针对原始域名进行；重定向到其他域名时，
+// 在TLS等后续探测之前检查最终域名的地理位置
 type LocationCheckStage struct{}
 
 // NewLocationCheckStage 创建地理位置检查阶段
@@ -14,19 +18,37 @@ func NewLocationCheckStage() *LocationCheckStage {
 }
 
 // Execute 执行地理位置检查
-func (lcs *LocationCheckStage) Execute(ctx *types.PipelineContext) error {
+func (lcs *LocationCheckStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
 	// 检查地理位置结果是否存在
 	if ctx.Result.Location == nil {
-		return nil
+		return nil, nil
+	}
+
+	// 没有重定向到其他域名时，原始域名的检测结果即为最终结果
+	network := ctx.Result.Network
+	if network == nil || !network.IsRedirected || strings.EqualFold(ctx.Domain, ctx.Result.Domain) {
+		return nil, nil
 	}
 
-	// 检查是否为中国
-	if ctx.Result.Location.IsDomestic {
-		// 标记为不适合，但不立即终止，让Pipeline继续执行
-		// 最终结果会在Batch Manager中处理
+	ls := &LocationStage{}
+	ip, err := ls.resolveIP(ctx, ctx.Domain)
+	if err != nil {
+		// 最终域名无法解析时由后续探测报告错误
+		return nil, nil
 	}
+	country, isDomestic := ls.getLocation(datasetsOf(ctx).Country, ip)
 
-	return nil
+	partial := &types.PartialResult{}
+	partial.AddEvidence(fmt.Sprintf("最终域名 %s 的地址 %s 位于 %s", ctx.Domain, ip, country))
+	if isDomestic {
+		location := *ctx.Result.Location
+		location.Country = country
+		location.IsDomestic = true
+		location.IPAddress = ip
+		partial.Location = &location
+		return partial, fmt.Errorf("最终域名为国内网站（仅参考GeoIP）")
+	}
+	return partial, nil
 }
 
 // CanEarlyExit 是否可以早期退出
@@ -43,3 +65,13 @@ func (lcs *LocationCheckStage) Priority() int {
 func (lcs *LocationCheckStage) Name() string {
 	return "location_check"
 }
+
+// Requires 依赖的结果字段
+func (lcs *LocationCheckStage) Requires() []string {
+	return []string{types.FieldLocation, types.FieldNetwork}
+}
+
+// Produces 产出的结果字段
+func (lcs *LocationCheckStage) Produces() []string {
+	return []string{types.FieldLocationCheck}
+}
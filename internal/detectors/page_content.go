@@ -0,0 +1,124 @@
+package detectors
+
+import (
+	"fmt"
+	"strings"
+
+	"RealityChecker/internal/dataset"
+	"RealityChecker/internal/types"
+)
+
+// PageContentStage 页面内容分类阶段
+// 根据页面特征库识别WAF挑战页、停放域名和Web服务器默认页面
+// 页面特征库来自数据集快照
+type PageContentStage struct{}
+
+// NewPageContentStage 创建页面内容分类阶段
+func NewPageContentStage() *PageContentStage {
+	return &PageContentStage{}
+}
+
+// Execute 执行页面内容分类
+func (pcs *PageContentStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
+	// 页面内容来自重定向检测阶段
+	network := ctx.Result.Network
+	if network == nil {
+		return nil, nil
+	}
+
+	pageType, signature := pcs.classify(datasetsOf(ctx).Pages, network)
+
+	partial := &types.PartialResult{
+		PageStatus: &types.PageStatusResult{
+			StatusCode:       network.StatusCode,
+			IsAccessible:     network.Accessible,
+			ResponseTime:     network.ResponseTime.Milliseconds(),
+			PageType:         pageType,
+			MatchedSignature: signature,
+		},
+	}
+	if signature != "" {
+		partial.AddEvidence(fmt.Sprintf("命中%s特征: %s", types.PageTypeName(pageType), signature))
+	}
+
+	// 内容分类只记录结果，适合性由流水线统一评估
+	return partial, nil
+}
+
+// classify 对页面进行分类，返回页面类型和命中的特征
+func (pcs *PageContentStage) classify(pages *dataset.PageSignatures, network *types.NetworkResult) (string, string) {
+	// 先检查挑战页特有的响应头
+	for _, signature := range pages.WAFChallengeHeader {
+		if pcs.matchHeader(network.Headers, signature) {
+			return types.PageTypeWAFChallenge, signature
+		}
+	}
+
+	if !network.Accessible || len(network.BodySnippet) == 0 {
+		return types.PageTypeUnknown, ""
+	}
+
+	body := strings.ToLower(string(network.BodySnippet))
+
+	// 按特征独特性从高到低检查
+	checks := []struct {
+		pageType   string
+		signatures []string
+	}{
+		{types.PageTypeWAFChallenge, pages.WAFChallenge},
+		{types.PageTypeParked, pages.ParkedDomain},
+		{types.PageTypeDefaultServer, pages.DefaultPage},
+	}
+
+	for _, check := range checks {
+		for _, signature := range check.signatures {
+			if strings.Contains(body, signature) {
+				return check.pageType, signature
+			}
+		}
+	}
+
+	return types.PageTypeNormal, ""
+}
+
+// matchHeader 检查响应头特征（格式：头名: 值）
+func (pcs *PageContentStage) matchHeader(headers map[string]string, signature string) bool {
+	name, value, _ := strings.Cut(signature, ":")
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+
+	for respName, respValue := range headers {
+		if !strings.EqualFold(respName, name) {
+			continue
+		}
+		if value == "" || strings.Contains(strings.ToLower(respValue), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEarlyExit 是否可以早期退出
+func (pcs *PageContentStage) CanEarlyExit() bool {
+	return false // 内容分类只读取已有结果，可与网络检测并发执行
+}
+
+// Priority 优先级
+func (pcs *PageContentStage) Priority() int {
+	return 6 // 页面内容分类在重定向检测之后
+}
+
+// Name 阶段名称
+func (pcs *PageContentStage) Name() string {
+	return "page_content"
+}
+
+// Requires 依赖的结果字段
+func (pcs *PageContentStage) Requires() []string {
+	return []string{types.FieldNetwork}
+}
+
+// Produces 产出的结果字段
+func (pcs *PageContentStage) Produces() []string {
+	return []string{types.FieldPageStatus}
+}
@@ -0,0 +1,167 @@
+package detectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// pluginProtocolVersion 插件协议版本
+const pluginProtocolVersion = 1
+
+// maxPluginStderr 错误信息中保留的插件stderr长度
+const maxPluginStderr = 512
+
+// pluginWaitDelay 插件被结束后等待输出管道关闭的最长时间
+const pluginWaitDelay = time.Second
+
+// PluginStage 外部插件检测阶段
+// 通过stdin向插件发送检测目标和已有的检测结果（JSON），从stdout读取插件的判定
+type PluginStage struct {
+	config types.PluginConfig
+}
+
+// PluginRequest 发送给插件的请求
+type PluginRequest struct {
+	Version int                    `json:"version"`
+	Target  PluginTarget           `json:"target"`
+	Result  *types.DetectionResult `json:"result"`
+}
+
+// PluginTarget 发送给插件的检测目标
+// 发生重定向时 FinalDomain 为最终域名，否则与 Domain 相同
+type PluginTarget struct {
+	Domain      string `json:"domain"`
+	FinalDomain string `json:"final_domain"`
+	IP          string `json:"ip,omitempty"`
+}
+
+// PluginResponse 插件返回的结果
+type PluginResponse struct {
+	Verdict  string                 `json:"verdict"`
+	Reason   string                 `json:"reason"`
+	Fields   map[string]interface{} `json:"fields"`
+	Warnings []string               `json:"warnings"`
+	Evidence []string               `json:"evidence"`
+}
+
+// NewPluginStage 创建外部插件检测阶段
+func NewPluginStage(config types.PluginConfig) *PluginStage {
+	return &PluginStage{config: config}
+}
+
+// Validate 校验插件配置
+func (ps *PluginStage) Validate() error {
+	if ps.config.Name == "" {
+		return fmt.Errorf("插件缺少名称: %s", ps.config.Command)
+	}
+	if _, err := exec.LookPath(ps.config.Command); err != nil {
+		return fmt.Errorf("插件 %s 的命令不可用: %v", ps.config.Name, err)
+	}
+	return nil
+}
+
+// Execute 执行外部插件
+func (ps *PluginStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
+	// 流水线已将 ctx.Domain 替换为重定向后的最终域名，输入的域名保留在结果中
+	request, err := json.Marshal(PluginRequest{
+		Version: pluginProtocolVersion,
+		Target:  PluginTarget{Domain: ctx.Result.Domain, FinalDomain: ctx.Domain, IP: ctx.TargetIP},
+		Result:  ctx.Result,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("插件 %s 请求生成失败: %v", ps.config.Name, err)
+	}
+
+	pluginCtx, cancel := context.WithTimeout(requestContext(ctx), ps.config.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(pluginCtx, ps.config.Command, ps.config.Args...)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// 超时或中断时结束插件的整个进程组；遗留的子进程仍占用输出管道时最多再等待 pluginWaitDelay
+	setPluginProcessGroup(cmd)
+	cmd.WaitDelay = pluginWaitDelay
+
+	if err := cmd.Run(); err != nil {
+		if pluginCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("插件 %s 执行超时: %w", ps.config.Name, context.DeadlineExceeded)
+		}
+		return nil, fmt.Errorf("插件 %s 执行失败: %v%s", ps.config.Name, err, ps.stderrSuffix(stderr.String()))
+	}
+
+	var response PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("插件 %s 输出无法解析: %v", ps.config.Name, err)
+	}
+
+	switch response.Verdict {
+	case types.PluginVerdictPass, types.PluginVerdictFail:
+	case "":
+		response.Verdict = types.PluginVerdictPass
+	default:
+		return nil, fmt.Errorf("插件 %s 返回未知判定: %s", ps.config.Name, response.Verdict)
+	}
+
+	partial := &types.PartialResult{
+		Plugin: &types.PluginResult{
+			Name:     ps.config.Name,
+			Verdict:  response.Verdict,
+			Reason:   response.Reason,
+			Fields:   response.Fields,
+			Warnings: response.Warnings,
+		},
+		Evidence: response.Evidence,
+	}
+	for _, warning := range response.Warnings {
+		partial.AddWarning(fmt.Sprintf("插件 %s: %s", ps.config.Name, warning))
+	}
+
+	// 判定结果由流水线统一评估
+	return partial, nil
+}
+
+// stderrSuffix 截取插件stderr用于错误信息
+func (ps *PluginStage) stderrSuffix(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return ""
+	}
+	if len(stderr) > maxPluginStderr {
+		stderr = stderr[:maxPluginStderr] + "..."
+	}
+	return "（" + stderr + "）"
+}
+
+// CanEarlyExit 是否可以早期退出
+func (ps *PluginStage) CanEarlyExit() bool {
+	return false // 插件失败不影响内置检测
+}
+
+// Priority 优先级
+func (ps *PluginStage) Priority() int {
+	return 100 // 插件在内置检测之后
+}
+
+// Name 阶段名称
+func (ps *PluginStage) Name() string {
+	return types.FieldPluginPrefix + ps.config.Name
+}
+
+// Requires 依赖的结果字段
+func (ps *PluginStage) Requires() []string {
+	return ps.config.Requires
+}
+
+// Produces 产出的结果字段
+func (ps *PluginStage) Produces() []string {
+	return []string{types.FieldPluginPrefix + ps.config.Name}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package detectors
+
+import "os/exec"
+
+// setPluginProcessGroup 非Unix系统只结束插件进程本身
+func setPluginProcessGroup(cmd *exec.Cmd) {}
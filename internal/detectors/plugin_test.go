@@ -0,0 +1,165 @@
+package detectors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// stubPluginEnv 设置后测试程序作为插件运行，值为插件的行为
+const stubPluginEnv = "REALITY_CHECKER_STUB_PLUGIN"
+
+// TestMain 作为插件运行时不执行测试
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(stubPluginEnv); mode != "" {
+		os.Exit(runStubPlugin(mode))
+	}
+	os.Exit(m.Run())
+}
+
+// runStubPlugin 测试用的插件：echo 返回收到的目标，hang 和 hang-child 不退出，exit 以非0退出
+func runStubPlugin(mode string) int {
+	switch mode {
+	case "echo":
+		var request PluginRequest
+		if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		json.NewEncoder(os.Stdout).Encode(PluginResponse{
+			Verdict:  types.PluginVerdictFail,
+			Reason:   "命中黑名单",
+			Fields:   map[string]interface{}{"target": request.Target, "version": request.Version, "result_domain": request.Result.Domain},
+			Warnings: []string{"名单较旧"},
+			Evidence: []string{"blocklist: " + request.Target.Domain},
+		})
+	case "hang":
+		time.Sleep(time.Minute)
+	case "hang-child":
+		// 子进程继承输出管道，只结束插件进程时管道不会关闭
+		child := exec.Command(os.Args[0])
+		child.Env = append(os.Environ(), stubPluginEnv+"=hang")
+		child.Stdout = os.Stdout
+		child.Start()
+		time.Sleep(time.Minute)
+	case "exit":
+		fmt.Fprintln(os.Stderr, "数据库连接失败")
+		return 3
+	case "bad-verdict":
+		fmt.Println(`{"verdict":"maybe"}`)
+	}
+	return 0
+}
+
+// newStubPlugin 运行测试程序作为插件的阶段
+func newStubPlugin(t *testing.T, mode string, timeout time.Duration) *PluginStage {
+	t.Helper()
+	t.Setenv(stubPluginEnv, mode)
+	return NewPluginStage(types.PluginConfig{Name: "stub", Command: os.Args[0], Timeout: timeout})
+}
+
+// redirectedContext 输入域名重定向到其他域名后的阶段上下文
+func redirectedContext() *types.PipelineContext {
+	return &types.PipelineContext{
+		Domain:   "www.example.com",
+		TargetIP: "192.0.2.1",
+		Context:  context.Background(),
+		Result: &types.DetectionResult{
+			Domain:  "example.com",
+			Network: &types.NetworkResult{Accessible: true, IsRedirected: true, FinalDomain: "www.example.com"},
+		},
+	}
+}
+
+func TestPluginRequestAndResponse(t *testing.T) {
+	stage := newStubPlugin(t, "echo", 10*time.Second)
+	if err := stage.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	partial, err := stage.Execute(redirectedContext())
+	if err != nil {
+		t.Fatalf("插件执行失败: %v", err)
+	}
+
+	plugin := partial.Plugin
+	if plugin.Name != "stub" || plugin.Verdict != types.PluginVerdictFail || plugin.Reason != "命中黑名单" {
+		t.Errorf("插件结果 = %+v", plugin)
+	}
+	target, _ := plugin.Fields["target"].(map[string]interface{})
+	want := map[string]string{"domain": "example.com", "final_domain": "www.example.com", "ip": "192.0.2.1"}
+	for field, value := range want {
+		if target[field] != value {
+			t.Errorf("插件收到的 %s = %v，应为 %s", field, target[field], value)
+		}
+	}
+	if plugin.Fields["version"] != float64(pluginProtocolVersion) || plugin.Fields["result_domain"] != "example.com" {
+		t.Errorf("插件收到的请求不完整: %v", plugin.Fields)
+	}
+	if len(partial.Evidence) != 1 || partial.Evidence[0] != "blocklist: example.com" {
+		t.Errorf("证据 = %v", partial.Evidence)
+	}
+	if len(partial.Warnings) != 1 || partial.Warnings[0] != "插件 stub: 名单较旧" {
+		t.Errorf("警告 = %v", partial.Warnings)
+	}
+}
+
+func TestPluginFailures(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string // 错误信息应包含的内容
+	}{
+		{"exit", "数据库连接失败"},
+		{"bad-verdict", "未知判定: maybe"},
+		{"none", "输出无法解析"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			_, err := newStubPlugin(t, tt.mode, 10*time.Second).Execute(redirectedContext())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("错误 = %v，应包含 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPluginTimeoutKillsProcess(t *testing.T) {
+	for _, mode := range []string{"hang", "hang-child"} {
+		t.Run(mode, func(t *testing.T) {
+			stage := newStubPlugin(t, mode, 300*time.Millisecond)
+
+			start := time.Now()
+			_, err := stage.Execute(redirectedContext())
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("错误 = %v，应为执行超时", err)
+			}
+			// 超时后结束插件（及其子进程），最多再等待 pluginWaitDelay
+			if elapsed > 300*time.Millisecond+pluginWaitDelay+2*time.Second {
+				t.Errorf("超时后 %v 才返回", elapsed)
+			}
+		})
+	}
+}
+
+func TestPluginCancelled(t *testing.T) {
+	stage := newStubPlugin(t, "hang", 10*time.Second)
+	ctx := redirectedContext()
+	cancelled, cancel := context.WithCancel(context.Background())
+	ctx.Context = cancelled
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	if _, err := stage.Execute(ctx); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("中断时应结束插件并返回执行失败，得到 %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package detectors
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setPluginProcessGroup 插件在独立的进程组中运行，取消时结束整个进程组
+// 插件启动的子进程（例如shell脚本中的命令）也会一起结束，不会继续占用输出管道
+func setPluginProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
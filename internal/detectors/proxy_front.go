@@ -0,0 +1,260 @@
+package detectors
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"RealityChecker/internal/types"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// ProxyFrontStage 代理前置检测阶段
+// 扫描得到的IP可能是他人的Reality/Trojan服务器：它们转发大站的TLS握手，
+// 证书与大站一致，但IP所属网络与域名真实地址完全不同
+// ASN数据库（可选）来自数据集快照，没有ASN数据时按网段比较
+type ProxyFrontStage struct{}
+
+// 没有ASN数据时判断是否属于同一网络的网段长度
+const (
+	proxyFrontPrefixV4 = 16
+	proxyFrontPrefixV6 = 32
+)
+
+// NewProxyFrontStage 创建代理前置检测阶段
+func NewProxyFrontStage() *ProxyFrontStage {
+	return &ProxyFrontStage{}
+}
+
+// Execute 执行代理前置检测
+func (pfs *ProxyFrontStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
+	// 只有带扫描IP的目标（CSV）才需要检测
+	if ctx.TargetIP == "" || net.ParseIP(ctx.TargetIP) == nil {
+		return nil, nil
+	}
+
+	// 国内网站本身不适合，无需再探测扫描IP
+	if ctx.Result.Location != nil && ctx.Result.Location.IsDomestic {
+		return nil, nil
+	}
+
+	connMgr, ok := ctx.Connections.(interface {
+		GetTLSConnectionToIP(context.Context, string, string) (*tls.Conn, error)
+		CloseTLSConnection(*tls.Conn)
+	})
+	if !ok {
+		return nil, nil
+	}
+
+	// 证书是扫描IP针对原始域名返回的，因此使用原始域名作为SNI
+	serverName := ctx.Result.Domain
+	result := &types.ProxyFrontResult{
+		TargetIP: ctx.TargetIP,
+	}
+	partial := &types.PartialResult{ProxyFront: result}
+
+	// 解析域名的真实地址
+	dnsIPs, err := lookupIP(ctx, serverName)
+	if err != nil || len(dnsIPs) == 0 {
+		result.Evidence = append(result.Evidence, "域名无法解析，无法比较")
+		return partial, nil
+	}
+	targetIP := net.ParseIP(ctx.TargetIP)
+	for _, ip := range dnsIPs {
+		result.DNSAddresses = append(result.DNSAddresses, ip.String())
+		if ip.Equal(targetIP) {
+			result.InDNS = true
+		}
+	}
+
+	// 目标IP就是域名的解析地址，不是代理前置
+	if result.InDNS {
+		result.Evidence = append(result.Evidence, "目标IP在域名DNS解析结果中")
+		return partial, nil
+	}
+
+	// 分别握手目标IP和DNS地址
+	targetState, err := pfs.handshake(ctx, connMgr, ctx.TargetIP, serverName)
+	if err != nil {
+		result.Evidence = append(result.Evidence, fmt.Sprintf("目标IP握手失败: %v", err))
+		return partial, nil
+	}
+	referenceIP := preferIPv4(dnsIPs)
+	dnsState, err := pfs.handshake(ctx, connMgr, referenceIP.String(), serverName)
+	if err != nil {
+		result.Evidence = append(result.Evidence, fmt.Sprintf("DNS地址 %s 握手失败: %v", referenceIP, err))
+		return partial, nil
+	}
+
+	// 比较证书和TLS参数
+	result.TargetCertSHA256 = leafFingerprint(targetState)
+	result.DNSCertSHA256 = leafFingerprint(dnsState)
+	result.CertMatch = result.TargetCertSHA256 != "" && result.TargetCertSHA256 == result.DNSCertSHA256
+	result.TargetFingerprint = tlsFingerprint(targetState)
+	result.DNSFingerprint = tlsFingerprint(dnsState)
+	result.FingerprintMatch = result.TargetFingerprint == result.DNSFingerprint
+
+	// 比较IP归属（ASN），同时比较网段供没有ASN数据时使用
+	asnKnown := pfs.compareASN(datasetsOf(ctx).ASN, result, targetIP, dnsIPs)
+	result.PrefixMismatch = !inSamePrefix(targetIP, dnsIPs)
+
+	if result.CertMatch {
+		result.Evidence = append(result.Evidence, "目标IP返回与域名真实地址相同的证书")
+	} else {
+		result.Evidence = append(result.Evidence, "目标IP返回的证书与域名真实地址不同")
+	}
+	if !result.FingerprintMatch {
+		result.Evidence = append(result.Evidence, fmt.Sprintf("TLS参数不同: %s / %s", result.TargetFingerprint, result.DNSFingerprint))
+	}
+	switch {
+	case asnKnown && result.ASNMismatch:
+		result.Evidence = append(result.Evidence, fmt.Sprintf("IP归属不同: %s / %s", result.TargetASN, strings.Join(result.DomainASNs, ",")))
+	case !asnKnown:
+		result.Evidence = append(result.Evidence, "ASN不可用，根据证书和网段判断")
+		if result.PrefixMismatch {
+			result.Evidence = append(result.Evidence, fmt.Sprintf("目标IP与域名真实地址不在同一网段: %s / %s", ctx.TargetIP, strings.Join(result.DNSAddresses, ",")))
+		}
+	}
+
+	// 目标IP不在域名的解析结果中，却返回相同的证书，且位于不相关的网络，说明目标IP在转发大站的握手
+	// Reality等代理转发真实的握手，TLS参数与真实服务器一致，因此不以TLS参数作为依据
+	if asnKnown {
+		result.IsLikelyProxy = result.CertMatch && result.ASNMismatch
+	} else {
+		result.IsLikelyProxy = result.CertMatch && result.PrefixMismatch
+	}
+	partial.Evidence = result.Evidence
+	if result.IsLikelyProxy {
+		partial.AddWarning(fmt.Sprintf("疑似代理前置: %s 转发 %s 的证书", ctx.TargetIP, serverName))
+	}
+
+	return partial, nil
+}
+
+// handshake 握手并返回连接状态
+func (pfs *ProxyFrontStage) handshake(ctx *types.PipelineContext, connMgr interface {
+	GetTLSConnectionToIP(context.Context, string, string) (*tls.Conn, error)
+	CloseTLSConnection(*tls.Conn)
+}, ip, serverName string) (tls.ConnectionState, error) {
+	var conn *tls.Conn
+	err := withRetry(ctx, "TLS握手 "+ip, func() error {
+		var err error
+		conn, err = connMgr.GetTLSConnectionToIP(ctx.Context, ip, serverName)
+		return err
+	})
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer connMgr.CloseTLSConnection(conn)
+	return conn.ConnectionState(), nil
+}
+
+// compareASN 比较目标IP与域名真实地址的ASN，返回ASN数据是否可用
+func (pfs *ProxyFrontStage) compareASN(asnDB *geoip2.Reader, result *types.ProxyFrontResult, targetIP net.IP, dnsIPs []net.IP) bool {
+	targetASN, ok := pfs.lookupASN(asnDB, targetIP)
+	if !ok {
+		return false
+	}
+	result.TargetASN = targetASN
+
+	seen := make(map[string]bool)
+	for _, ip := range dnsIPs {
+		asn, ok := pfs.lookupASN(asnDB, ip)
+		if !ok || seen[asn] {
+			continue
+		}
+		seen[asn] = true
+		result.DomainASNs = append(result.DomainASNs, asn)
+	}
+	if len(result.DomainASNs) == 0 {
+		return false
+	}
+
+	result.ASNMismatch = !seen[targetASN]
+	return true
+}
+
+// inSamePrefix 目标IP是否与任一地址位于同一网段
+func inSamePrefix(targetIP net.IP, ips []net.IP) bool {
+	bits, length := proxyFrontPrefixV6, 8*net.IPv6len
+	if targetIP.To4() != nil {
+		bits, length = proxyFrontPrefixV4, 8*net.IPv4len
+	}
+	mask := net.CIDRMask(bits, length)
+	network := targetIP.Mask(mask)
+	for _, ip := range ips {
+		if (ip.To4() != nil) != (targetIP.To4() != nil) {
+			continue
+		}
+		if ip.Mask(mask).Equal(network) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupASN 查询IP所属ASN
+func (pfs *ProxyFrontStage) lookupASN(asnDB *geoip2.Reader, ip net.IP) (string, bool) {
+	if asnDB == nil {
+		return "", false
+	}
+	record, err := asnDB.ASN(ip)
+	if err != nil || record.AutonomousSystemNumber == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("AS%d", record.AutonomousSystemNumber), true
+}
+
+// leafFingerprint 计算叶子证书SHA256指纹
+func leafFingerprint(state tls.ConnectionState) string {
+	if len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// tlsFingerprint 由TLS版本、密码套件和ALPN组成的握手参数指纹
+func tlsFingerprint(state tls.ConnectionState) string {
+	return fmt.Sprintf("%s/%s/%s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), state.NegotiatedProtocol)
+}
+
+// preferIPv4 优先选择IPv4地址
+func preferIPv4(ips []net.IP) net.IP {
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	return ips[0]
+}
+
+// CanEarlyExit 是否可以早期退出
+func (pfs *ProxyFrontStage) CanEarlyExit() bool {
+	return false // 需要网络连接，与其他网络检测并发执行
+}
+
+// Priority 优先级
+func (pfs *ProxyFrontStage) Priority() int {
+	return 7 // 代理前置检测在综合TLS检测之后
+}
+
+// Name 阶段名称
+func (pfs *ProxyFrontStage) Name() string {
+	return "proxy_front"
+}
+
+// Requires 依赖的结果字段
+func (pfs *ProxyFrontStage) Requires() []string {
+	return []string{types.FieldLocation} // 使用原始域名的地理位置，不依赖重定向结果
+}
+
+// Produces 产出的结果字段
+func (pfs *ProxyFrontStage) Produces() []string {
+	return []string{types.FieldProxyFront}
+}
@@ -1,12 +1,16 @@
 package detectors
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"RealityChecker/internal/types"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 // RedirectStage 重定向检测阶段
@@ -18,21 +22,21 @@ func NewRedirectStage() *RedirectStage {
 }
 
 // Execute 执行重定向检测
-func (rs *RedirectStage) Execute(ctx *types.PipelineContext) error {
+func (rs *RedirectStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
 
 	// 创建HTTP客户端，禁用自动重定向
-	client := &http.Client{
-		Timeout: 3 * time.Second, // 减少HTTP客户端超时时间到3秒
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+	client := newProbeClient(ctx)
+
+	maxRedirects := 5
+	if ctx.Config != nil && ctx.Config.Detection.MaxRedirects > 0 {
+		maxRedirects = ctx.Config.Detection.MaxRedirects
 	}
 
 	// 跟踪重定向
-	result := rs.followRedirects(client, ctx.Domain)
+	result := rs.followRedirects(ctx, client, ctx.Domain, maxRedirects)
 
 	// 设置网络结果
-	ctx.Result.Network = &types.NetworkResult{
+	network := &types.NetworkResult{
 		Accessible:    result.Accessible,
 		StatusCode:    result.StatusCode,
 		FinalDomain:   result.FinalDomain,
@@ -42,20 +46,40 @@ func (rs *RedirectStage) Execute(ctx *types.PipelineContext) error {
 		URL:           result.URL,
 		ResponseTime:  time.Since(ctx.StartTime),
 		Headers:       result.Headers, // 保存HTTP响应头
+		BodySnippet:   result.BodySnippet,
+		Hops:          result.Hops,
+		FinalURL:      result.URL,
+		RedirectScope: types.RedirectScopeNone,
+	}
+	if len(result.Hops) > 1 {
+		network.RedirectScope = classifyRedirectScope(ctx.Domain, result.FinalDomain)
+	}
+	partial := &types.PartialResult{Network: network}
+	for _, hop := range result.Hops {
+		switch {
+		case hop.Error != "":
+			partial.AddEvidence(fmt.Sprintf("%s 请求失败: %s", hop.URL, hop.Error))
+		case hop.Location != "":
+			partial.AddEvidence(fmt.Sprintf("%s -> %d %s", hop.URL, hop.StatusCode, hop.Location))
+		default:
+			partial.AddEvidence(fmt.Sprintf("%s -> %d", hop.URL, hop.StatusCode))
+		}
 	}
 
-	// 在重定向检测阶段进行HTTP CDN检测
-	httpCDNResult := rs.performHTTPCDNDetection(ctx, result.FinalDomain, ctx.Result.Network)
-	if httpCDNResult != nil {
-		ctx.Result.CDN = httpCDNResult
+	// 跨站重定向：最终站点可作为备选目标
+	if network.RedirectScope == types.RedirectScopeCrossSite {
+		partial.AlternativeCandidate = result.FinalDomain
+		partial.AddWarning(fmt.Sprintf("跨站重定向: %s -> %s，可改为检测 %s", ctx.Domain, result.FinalDomain, result.FinalDomain))
 	}
 
-	// 更新域名
-	if result.IsRedirected {
-		ctx.Domain = result.FinalDomain
+	// 在重定向检测阶段进行HTTP CDN检测
+	partial.CDN = rs.performHTTPCDNDetection(ctx, result.FinalDomain, network)
+	if partial.CDN != nil {
+		partial.AddEvidence(fmt.Sprintf("HTTP响应头识别CDN: %s（%s）", partial.CDN.CDNProvider, partial.CDN.Evidence))
 	}
 
-	return nil
+	// 最终域名由流水线传递给后续阶段
+	return partial, nil
 }
 
 // RedirectResult 重定向结果
@@ -65,17 +89,30 @@ type RedirectResult struct {
 	FinalDomain   string
 	RedirectChain []string
 	IsRedirected  bool
-	RedirectCount int
+	RedirectCount int // 主机名变化的跳转次数，仅路径或协议变化的跳转只记录在Hops中
 	URL           string
-	Headers       map[string]string // HTTP响应头
+	Headers       map[string]string   // 最终响应的HTTP响应头
+	BodySnippet   []byte              // 最终页面响应体（有限长度）
+	Hops          []types.RedirectHop // 每一跳的请求记录
+}
+
+// maxBodySnippet 最终页面响应体最多读取的字节数
+const maxBodySnippet = 64 * 1024
+
+// hopHeaders 每一跳记录的响应头
+var hopHeaders = []string{
+	"Server",
+	"Location",
+	"Via",
+	"Content-Type",
+	"Strict-Transport-Security",
+	"Set-Cookie",
 }
 
 // followRedirects 跟踪重定向
-func (rs *RedirectStage) followRedirects(client *http.Client, domain string) *RedirectResult {
-	const (
-		maxRedirects = 5
-		httpsScheme  = "https://"
-	)
+// 记录每一跳，包括仅路径或协议变化的跳转（如 http -> https、/ -> /en/）
+func (rs *RedirectStage) followRedirects(ctx *types.PipelineContext, client *http.Client, domain string, maxRedirects int) *RedirectResult {
+	const httpsScheme = "https://"
 
 	result := &RedirectResult{
 		Accessible:    false,
@@ -88,34 +125,38 @@ func (rs *RedirectStage) followRedirects(client *http.Client, domain string) *Re
 	}
 
 	currentURL := httpsScheme + domain
+	visited := map[string]bool{currentURL: true}
 
-	for i := 0; i < maxRedirects; i++ {
-		req, err := http.NewRequest("GET", currentURL, nil)
+	for i := 0; i <= maxRedirects; i++ {
+		// 添加浏览器头
+		req, err := newProbeRequest(requestContext(ctx), currentURL)
 		if err != nil {
 			break
 		}
 
-		// 添加浏览器头
-		const (
-			userAgent      = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36"
-			acceptHeader   = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
-			acceptLanguage = "en-US,en;q=0.9"
-		)
-
-		req.Header.Set("User-Agent", userAgent)
-		req.Header.Set("Accept", acceptHeader)
-		req.Header.Set("Accept-Language", acceptLanguage)
-
-		resp, err := client.Do(req)
+		hopStart := time.Now()
+		var resp *http.Response
+		err = withRetry(ctx, "GET "+currentURL, func() error {
+			var err error
+			resp, err = client.Do(req)
+			return err
+		})
+		hop := types.RedirectHop{
+			URL:      currentURL,
+			Duration: time.Since(hopStart),
+		}
 		if err != nil {
+			hop.Error = err.Error()
+			result.Hops = append(result.Hops, hop)
 			break
 		}
 
 		result.Accessible = true
 		result.StatusCode = resp.StatusCode
 		result.URL = currentURL
+		hop.StatusCode = resp.StatusCode
 
-		// 保存HTTP响应头
+		// 保存最终响应的HTTP响应头
 		result.Headers = make(map[string]string)
 		for name, values := range resp.Header {
 			if len(values) > 0 {
@@ -123,56 +164,104 @@ func (rs *RedirectStage) followRedirects(client *http.Client, domain string) *Re
 			}
 		}
 
-		// 检查是否有重定向
-		const (
-			redirectMin = 300
-			redirectMax = 400
-		)
-		if resp.StatusCode >= redirectMin && resp.StatusCode < redirectMax {
-			location := resp.Header.Get("Location")
-			if location != "" {
-				// 处理相对URL
-				const (
-					rootPathPrefix = "/"
-					httpPrefix     = "http"
-				)
-
-				if strings.HasPrefix(location, rootPathPrefix) {
-					parsedURL, _ := url.Parse(currentURL)
-					location = parsedURL.Scheme + "://" + parsedURL.Host + location
-				} else if !strings.HasPrefix(location, httpPrefix) {
-					location = httpsScheme + location
+		// 每一跳只保存关键响应头
+		for _, name := range hopHeaders {
+			if value := resp.Header.Get(name); value != "" {
+				if hop.Headers == nil {
+					hop.Headers = make(map[string]string)
 				}
+				hop.Headers[name] = value
+			}
+		}
 
-				parsedLocation, err := url.Parse(location)
-				if err == nil {
-					newDomain := parsedLocation.Hostname()
-					if newDomain != domain && newDomain != "" {
-						result.RedirectChain = append(result.RedirectChain, newDomain)
-						result.IsRedirected = true
-						result.RedirectCount++
-						currentURL = location
-						domain = newDomain
-						resp.Body.Close()
-						continue
-					}
+		// 检查是否有重定向
+		nextURL := ""
+		if isRedirectStatus(resp.StatusCode) {
+			nextURL = rs.resolveLocation(currentURL, resp.Header.Get("Location"))
+			hop.Location = nextURL
+		}
+		result.Hops = append(result.Hops, hop)
+
+		if nextURL != "" && !visited[nextURL] && i < maxRedirects {
+			visited[nextURL] = true
+
+			// 主机名变化时记录到重定向链并计数
+			if parsedNext, err := url.Parse(nextURL); err == nil {
+				newDomain := parsedNext.Hostname()
+				if !strings.EqualFold(newDomain, result.RedirectChain[len(result.RedirectChain)-1]) {
+					result.RedirectChain = append(result.RedirectChain, newDomain)
+					result.RedirectCount++
 				}
 			}
+
+			currentURL = nextURL
+			resp.Body.Close()
+			continue
 		}
 
-		// 没有重定向或重定向结束
-		parsedURL, _ := url.Parse(currentURL)
-		result.FinalDomain = parsedURL.Hostname()
+		// 没有重定向或重定向结束，读取有限长度的页面内容供内容分类使用
+		result.BodySnippet, _ = io.ReadAll(io.LimitReader(resp.Body, maxBodySnippet))
 		resp.Body.Close()
 		break
 	}
 
+	// 最终域名以最后一次成功请求的URL为准
+	if parsedURL, err := url.Parse(result.URL); err == nil && parsedURL.Hostname() != "" {
+		result.FinalDomain = parsedURL.Hostname()
+	}
+	result.IsRedirected = !strings.EqualFold(result.FinalDomain, domain)
+
 	return result
 }
 
+// resolveLocation 将Location头解析为绝对URL
+func (rs *RedirectStage) resolveLocation(currentURL, location string) string {
+	if location == "" {
+		return ""
+	}
+
+	base, err := url.Parse(currentURL)
+	if err != nil {
+		return ""
+	}
+
+	// 形如 "www.example.com/path" 的不规范地址按HTTPS处理
+	if !strings.Contains(location, "://") && !strings.HasPrefix(location, "/") && strings.Contains(strings.SplitN(location, "/", 2)[0], ".") {
+		location = "https://" + location
+	}
+
+	ref, err := base.Parse(location)
+	if err != nil || ref.Hostname() == "" {
+		return ""
+	}
+	if ref.Scheme != "http" && ref.Scheme != "https" {
+		return ""
+	}
+	ref.Fragment = ""
+	return ref.String()
+}
+
+// classifyRedirectScope 根据公共后缀列表判断最终目标与原域名的关系
+func classifyRedirectScope(originalDomain, finalDomain string) string {
+	original := strings.ToLower(strings.TrimSuffix(originalDomain, "."))
+	final := strings.ToLower(strings.TrimSuffix(finalDomain, "."))
+
+	if final == "" || original == final {
+		return types.RedirectScopeSameHost
+	}
+
+	originalSite, err1 := publicsuffix.EffectiveTLDPlusOne(original)
+	finalSite, err2 := publicsuffix.EffectiveTLDPlusOne(final)
+	if err1 == nil && err2 == nil && originalSite == finalSite {
+		return types.RedirectScopeSameSite
+	}
+
+	return types.RedirectScopeCrossSite
+}
+
 // CanEarlyExit 是否可以早期退出
 func (rs *RedirectStage) CanEarlyExit() bool {
-	return true // 重定向检测必须在TLS检测之前执行
+	return false // 重定向检测不会判定不适合，执行顺序由依赖关系保证
 }
 
 // Priority 优先级
@@ -185,10 +274,20 @@ func (rs *RedirectStage) Name() string {
 	return "redirect"
 }
 
+// Requires 依赖的结果字段
+func (rs *RedirectStage) Requires() []string {
+	return []string{types.FieldBlocked, types.FieldLocation} // 不访问被墙或位于国内的域名
+}
+
+// Produces 产出的结果字段
+func (rs *RedirectStage) Produces() []string {
+	return []string{types.FieldNetwork, types.FieldHTTPCDN}
+}
+
 // performHTTPCDNDetection 执行HTTP CDN检测
 func (rs *RedirectStage) performHTTPCDNDetection(ctx *types.PipelineContext, domain string, networkResult *types.NetworkResult) *types.CDNResult {
-	// 创建CDN检测阶段
-	cdnStage := NewCDNStage()
+	// 使用本次检测的数据集快照，不再每次读取关键字文件
+	cdnStage := NewCDNStage(datasetsOf(ctx).CDN)
 
 	// 只执行HTTP相关的CDN检测方法
 	isCDN, provider, confidence, evidence := rs.performHTTPCDNChecks(cdnStage, networkResult)
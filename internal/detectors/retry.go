@@ -0,0 +1,105 @@
+package detectors
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// 未配置时使用的网络参数
+const (
+	defaultOperationTimeout = 3 * time.Second
+	defaultRetryBackoff     = 300 * time.Millisecond
+	minOperationTimeout     = 100 * time.Millisecond
+)
+
+// operationTimeout 单次网络操作的超时时间
+// 取配置的网络超时，并且不超过检测上下文剩余的时间
+func operationTimeout(ctx *types.PipelineContext) time.Duration {
+	timeout := defaultOperationTimeout
+	if ctx.Config != nil && ctx.Config.Network.Timeout > 0 {
+		timeout = ctx.Config.Network.Timeout
+	}
+
+	if ctx.Context != nil {
+		if deadline, ok := ctx.Context.Deadline(); ok {
+			remaining := time.Until(deadline)
+			if remaining < minOperationTimeout {
+				remaining = minOperationTimeout
+			}
+			if remaining < timeout {
+				timeout = remaining
+			}
+		}
+	}
+
+	return timeout
+}
+
+// requestContext 检测上下文，网络操作都应受其约束以便及时响应中断
+func requestContext(ctx *types.PipelineContext) context.Context {
+	if ctx.Context == nil {
+		return context.Background()
+	}
+	return ctx.Context
+}
+
+// withRetry 执行网络操作，遇到临时性错误时按指数退避重试
+// 每次重试都会记录到阶段执行记录中，上下文取消后不再重试
+func withRetry(ctx *types.PipelineContext, operation string, fn func() error) error {
+	retries := 0
+	backoff := defaultRetryBackoff
+	if ctx.Config != nil {
+		retries = ctx.Config.Network.Retries
+		if ctx.Config.Network.RetryBackoff > 0 {
+			backoff = ctx.Config.Network.RetryBackoff
+		}
+	}
+
+	parent := requestContext(ctx)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > retries || !isTransientError(err) || parent.Err() != nil {
+			return err
+		}
+
+		if ctx.Trace != nil {
+			ctx.Trace.RecordRetry(types.RetryRecord{
+				Operation: operation,
+				Attempt:   attempt,
+				Error:     err.Error(),
+				Backoff:   backoff,
+			})
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-parent.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// isTransientError 判断是否为值得重试的临时性网络错误
+// 只有超时和连接被重置可以重试；连接被拒绝、对方关闭连接（EOF）、证书错误等不重试
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,25 @@
+package detectors
+
+import (
+	"context"
+
+	"RealityChecker/internal/types"
+)
+
+// probeGroup 引擎提供的探测合并组，多个输入的相同探测只执行一次
+type probeGroup interface {
+	Do(ctx context.Context, key string, probe func() (interface{}, bool)) (interface{}, bool)
+}
+
+// sharedProbe 执行可在多个输入之间共用的探测，返回结果和是否复用了其他输入的结果
+// 本目标超时或被中断时探测结果不完整，不共用
+func sharedProbe(ctx *types.PipelineContext, key string, probe func() interface{}) (interface{}, bool) {
+	group, ok := ctx.Probes.(probeGroup)
+	if !ok {
+		return probe(), false
+	}
+	return group.Do(requestContext(ctx), key, func() (interface{}, bool) {
+		value := probe()
+		return value, requestContext(ctx).Err() == nil
+	})
+}
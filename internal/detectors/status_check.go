@@ -1,11 +1,21 @@
 package detectors
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
 	"RealityChecker/internal/types"
 )
 
 // StatusCheckStage 状态码检查阶段
-// 检查HTTP状态码是否符合Reality要求
+// 检查HTTP状态码是否符合Reality要求，并检查随机路径和80端口的响应是否自然
 type StatusCheckStage struct{}
 
 // NewStatusCheckStage 创建状态码检查阶段
@@ -14,10 +24,10 @@ func NewStatusCheckStage() *StatusCheckStage {
 }
 
 // Execute 执行状态码检查
-func (scs *StatusCheckStage) Execute(ctx *types.PipelineContext) error {
+func (scs *StatusCheckStage) Execute(ctx *types.PipelineContext) (*types.PartialResult, error) {
 	// 检查网络结果是否存在
 	if ctx.Result.Network == nil {
-		return nil
+		return nil, nil
 	}
 
 	// 获取状态码
@@ -26,9 +36,179 @@ func (scs *StatusCheckStage) Execute(ctx *types.PipelineContext) error {
 
 	// 分类状态码
 	category := types.ClassifyStatusCode(statusCode, accessible)
-	ctx.Result.StatusCodeCategory = category
+	partial := &types.PartialResult{StatusCodeCategory: category}
+	partial.AddEvidence(fmt.Sprintf("状态码 %d 分类为 %s", statusCode, category))
+
+	// 主页不可达时无需进一步检查自然度
+	if !accessible {
+		return partial, nil
+	}
+
+	// 检查站点自然度（随机路径、80端口）
+	naturalness := scs.checkNaturalness(ctx)
+	partial.Naturalness = naturalness
+	for _, issue := range naturalness.Issues {
+		partial.AddWarning("伪装较弱: " + issue)
+		partial.AddEvidence(issue)
+	}
+
+	return partial, nil
+}
+
+// checkNaturalness 并发检查随机路径和80端口
+func (scs *StatusCheckStage) checkNaturalness(ctx *types.PipelineContext) *types.NaturalnessResult {
+	domain := ctx.Domain
+	client := newProbeClient(ctx)
+	result := &types.NaturalnessResult{
+		RandomPathURL: "https://" + domain + "/" + randomPath(),
+	}
+
+	var (
+		wg         sync.WaitGroup
+		randomBody []byte
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		result.RandomPathStatus, result.RandomPathLocation, randomBody = scs.fetch(ctx, client, result.RandomPathURL, true)
+	}()
+	go func() {
+		defer wg.Done()
+		result.HTTPStatus, result.HTTPLocation, _ = scs.fetch(ctx, client, "http://"+domain+"/", false)
+	}()
+	wg.Wait()
+
+	result.HTTPRedirectsToHTTPS = isRedirectStatus(result.HTTPStatus) &&
+		strings.HasPrefix(strings.ToLower(result.HTTPLocation), "https://")
+
+	// 随机路径：真实网站应返回404，与首页响应相同或跳转回首页说明所有路径都被同样处理
+	rootIssue := scs.compareWithRoot(ctx.Result.Network, domain, result, randomBody)
+	result.RandomPathLikeRoot = rootIssue != ""
+	switch {
+	case result.RandomPathStatus == 0:
+		result.Issues = append(result.Issues, "随机路径请求失败")
+	case result.RandomPathLikeRoot:
+		result.Issues = append(result.Issues, rootIssue)
+	case result.RandomPathStatus == http.StatusOK:
+		result.Issues = append(result.Issues, "不存在的路径也返回200")
+	}
+
+	// 80端口：真实网站通常跳转到HTTPS
+	switch {
+	case result.HTTPStatus == 0:
+		result.Issues = append(result.Issues, "80端口未开放")
+	case result.HTTPRedirectsToHTTPS:
+		// 正常跳转
+	case isRedirectStatus(result.HTTPStatus):
+		result.Issues = append(result.Issues, fmt.Sprintf("80端口跳转到非HTTPS地址: %s", result.HTTPLocation))
+	default:
+		result.Issues = append(result.Issues, fmt.Sprintf("80端口未跳转HTTPS（状态码 %d）", result.HTTPStatus))
+	}
+
+	result.WeakCamouflage = len(result.Issues) > 0
+	return result
+}
+
+// compareWithRoot 比较随机路径与首页的响应，相同时返回问题描述
+// 首页的响应取重定向检测阶段记录的该域名的第一跳，页面内容只在最终页面属于该域名时比较
+func (scs *StatusCheckStage) compareWithRoot(network *types.NetworkResult, domain string, result *types.NaturalnessResult, randomBody []byte) string {
+	if network == nil || result.RandomPathStatus == 0 {
+		return ""
+	}
+	var root *types.RedirectHop
+	for i := range network.Hops {
+		if hop := &network.Hops[i]; hop.Error == "" && strings.EqualFold(hostOf(hop.URL), domain) {
+			root = hop
+			break
+		}
+	}
+	if root == nil {
+		return ""
+	}
+
+	// 泛跳转：随机路径跳转到本站首页，或与首页跳转到同一地址
+	if isRedirectStatus(result.RandomPathStatus) && result.RandomPathLocation != "" {
+		if isRootURL(result.RandomPathLocation, domain) ||
+			strings.EqualFold(result.RandomPathLocation, root.Location) ||
+			strings.EqualFold(result.RandomPathLocation, network.FinalURL) {
+			return fmt.Sprintf("不存在的路径跳转到首页（%d %s）", result.RandomPathStatus, result.RandomPathLocation)
+		}
+		return ""
+	}
+
+	// 泛解析：随机路径与首页返回相同的状态码和内容
+	if strings.EqualFold(hostOf(network.FinalURL), domain) && result.RandomPathStatus == network.StatusCode &&
+		len(randomBody) > 0 && bytes.Equal(randomBody, network.BodySnippet) {
+		return fmt.Sprintf("不存在的路径返回与首页相同的内容（状态码 %d）", result.RandomPathStatus)
+	}
+	return ""
+}
+
+// isRootURL URL是否指向指定域名的首页
+func isRootURL(rawURL, domain string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(parsed.Hostname(), domain) && (parsed.Path == "" || parsed.Path == "/") && parsed.RawQuery == ""
+}
+
+// hostOf URL的主机名，无法解析时为空
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+// fetch 请求URL，返回状态码、Location头和（readBody时）有限长度的响应体，请求失败时状态码为0
+func (scs *StatusCheckStage) fetch(ctx *types.PipelineContext, client *http.Client, rawURL string, readBody bool) (int, string, []byte) {
+	req, err := newProbeRequest(requestContext(ctx), rawURL)
+	if err != nil {
+		return 0, "", nil
+	}
+
+	var resp *http.Response
+	err = withRetry(ctx, "GET "+rawURL, func() error {
+		var err error
+		resp, err = client.Do(req)
+		return err
+	})
+	if err != nil {
+		return 0, "", nil
+	}
+	defer resp.Body.Close()
 
-	return nil
+	location := resp.Header.Get("Location")
+	if location != "" {
+		// 处理相对地址
+		if base, err := url.Parse(rawURL); err == nil {
+			if ref, err := base.Parse(location); err == nil {
+				location = ref.String()
+			}
+		}
+	}
+
+	var body []byte
+	if readBody {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, maxBodySnippet))
+	}
+	return resp.StatusCode, location, body
+}
+
+// isRedirectStatus 是否为重定向状态码
+func isRedirectStatus(statusCode int) bool {
+	return statusCode >= 300 && statusCode < 400
+}
+
+// randomPath 生成一个几乎不可能存在的随机路径
+func randomPath() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "reality-checker-not-found"
+	}
+	return hex.EncodeToString(buf)
 }
 
 // CanEarlyExit 是否可以早期退出
@@ -45,3 +225,13 @@ func (scs *StatusCheckStage) Priority() int {
 func (scs *StatusCheckStage) Name() string {
 	return "status_check"
 }
+
+// Requires 依赖的结果字段
+func (scs *StatusCheckStage) Requires() []string {
+	return []string{types.FieldNetwork, types.FieldLocationCheck} // 最终域名位于国内时不再探测
+}
+
+// Produces 产出的结果字段
+func (scs *StatusCheckStage) Produces() []string {
+	return []string{types.FieldStatusCategory, types.FieldNaturalness}
+}
@@ -0,0 +1,218 @@
+package domainmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RuleType 规则类型
+type RuleType string
+
+// 规则类型常量
+const (
+	Exact   RuleType = "exact"   // 完全相同的域名
+	Suffix  RuleType = "suffix"  // 域名本身及其所有子域名
+	Keyword RuleType = "keyword" // 域名中包含关键字
+	Regex   RuleType = "regex"   // 域名匹配正则表达式
+)
+
+// Rule 域名规则及其来源
+type Rule struct {
+	Type    RuleType
+	Pattern string // 规范化后的域名、关键字或正则表达式
+	File    string // 规则所在的数据文件
+	Line    int    // 规则所在的行号，从1开始
+}
+
+// Origin 规则来源，例如 gfwlist.conf:123
+func (r *Rule) Origin() string {
+	return fmt.Sprintf("%s:%d", r.File, r.Line)
+}
+
+// String 规则的原始写法和来源
+func (r *Rule) String() string {
+	var pattern string
+	switch r.Type {
+	case Suffix:
+		pattern = "+." + r.Pattern
+	case Keyword:
+		pattern = "keyword:" + r.Pattern
+	case Regex:
+		pattern = "regexp:" + r.Pattern
+	default:
+		pattern = r.Pattern
+	}
+	return fmt.Sprintf("%s（%s）", pattern, r.Origin())
+}
+
+// ParseRule 解析一行规则
+// 支持 +.example.com、*.example.com、.example.com 和 domain:example.com（后缀），
+// full:example.com 和不带前缀的域名（完全匹配），keyword:xxx（关键字），regexp:xxx（正则）
+func ParseRule(line string) (RuleType, string) {
+	switch {
+	case strings.HasPrefix(line, "+."):
+		return Suffix, line[2:]
+	case strings.HasPrefix(line, "*."):
+		return Suffix, line[2:]
+	case strings.HasPrefix(line, "."):
+		return Suffix, line[1:]
+	case strings.HasPrefix(line, "domain:"):
+		return Suffix, strings.TrimPrefix(line, "domain:")
+	case strings.HasPrefix(line, "full:"):
+		return Exact, strings.TrimPrefix(line, "full:")
+	case strings.HasPrefix(line, "keyword:"):
+		return Keyword, strings.TrimPrefix(line, "keyword:")
+	case strings.HasPrefix(line, "regexp:"):
+		return Regex, strings.TrimPrefix(line, "regexp:")
+	default:
+		return Exact, line
+	}
+}
+
+// Matcher 域名匹配器
+// 完全匹配和后缀规则存放在按标签反向排列的前缀树中（com -> example -> www），
+// 查询只需沿域名标签走一遍，与规则数量无关；关键字和正则规则逐条检查
+type Matcher struct {
+	root     *node
+	keywords []*Rule
+	regexps  []*regexRule
+	size     int
+}
+
+// node 前缀树节点，对应域名的一个标签
+type node struct {
+	children map[string]*node
+	exact    *Rule // 到此为止的域名完全匹配的规则
+	suffix   *Rule // 到此为止的域名及其子域名匹配的规则
+}
+
+// regexRule 编译后的正则规则
+type regexRule struct {
+	rule *Rule
+	re   *regexp.Regexp
+}
+
+// New 创建空的域名匹配器
+func New() *Matcher {
+	return &Matcher{root: &node{}}
+}
+
+// Add 添加规则，同一域名的同类规则只保留先添加的
+func (m *Matcher) Add(ruleType RuleType, pattern, file string, line int) error {
+	rule := &Rule{Type: ruleType, File: file, Line: line}
+
+	switch ruleType {
+	case Exact, Suffix:
+		rule.Pattern = Normalize(pattern)
+		if rule.Pattern == "" {
+			return fmt.Errorf("%s:%d 域名为空", file, line)
+		}
+		current := m.root
+		labels := strings.Split(rule.Pattern, ".")
+		for i := len(labels) - 1; i >= 0; i-- {
+			child, exists := current.children[labels[i]]
+			if !exists {
+				if current.children == nil {
+					current.children = make(map[string]*node)
+				}
+				child = &node{}
+				current.children[labels[i]] = child
+			}
+			current = child
+		}
+		if ruleType == Exact {
+			if current.exact != nil {
+				return nil
+			}
+			current.exact = rule
+		} else {
+			if current.suffix != nil {
+				return nil
+			}
+			current.suffix = rule
+		}
+	case Keyword:
+		rule.Pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if rule.Pattern == "" {
+			return fmt.Errorf("%s:%d 关键字为空", file, line)
+		}
+		m.keywords = append(m.keywords, rule)
+	case Regex:
+		rule.Pattern = strings.TrimSpace(pattern)
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s:%d 正则表达式无效: %v", file, line, err)
+		}
+		m.regexps = append(m.regexps, &regexRule{rule: rule, re: re})
+	default:
+		return fmt.Errorf("%s:%d 不支持的规则类型: %s", file, line, ruleType)
+	}
+
+	m.size++
+	return nil
+}
+
+// Len 规则数量
+func (m *Matcher) Len() int {
+	if m == nil {
+		return 0
+	}
+	return m.size
+}
+
+// Match 查找匹配域名的规则
+// 优先级：完全匹配、最长的后缀、关键字、正则，同类规则按添加顺序
+func (m *Matcher) Match(domain string) (*Rule, bool) {
+	if m == nil {
+		return nil, false
+	}
+	domain = Normalize(domain)
+	if domain == "" {
+		return nil, false
+	}
+
+	var suffix *Rule
+	current := m.root
+	rest := domain
+	for current != nil && rest != "" {
+		label := rest
+		if i := strings.LastIndexByte(rest, '.'); i >= 0 {
+			label = rest[i+1:]
+			rest = rest[:i]
+		} else {
+			rest = ""
+		}
+
+		current = current.children[label]
+		if current == nil {
+			break
+		}
+		if current.suffix != nil {
+			suffix = current.suffix
+		}
+		if rest == "" && current.exact != nil {
+			return current.exact, true
+		}
+	}
+	if suffix != nil {
+		return suffix, true
+	}
+
+	for _, rule := range m.keywords {
+		if strings.Contains(domain, rule.Pattern) {
+			return rule, true
+		}
+	}
+	for _, regex := range m.regexps {
+		if regex.re.MatchString(domain) {
+			return regex.rule, true
+		}
+	}
+	return nil, false
+}
+
+// Normalize 规范化域名：小写，去掉首尾空白和末尾的点
+func Normalize(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
@@ -0,0 +1,267 @@
+package domainmatch
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+)
+
+// ruleLine 测试用的一行规则及其行号
+type ruleLine struct {
+	line   string
+	number int
+}
+
+// newTestMatcher 按 hot_websites.txt 的写法添加规则
+func newTestMatcher(t *testing.T, rules ...ruleLine) *Matcher {
+	t.Helper()
+
+	matcher := New()
+	for _, rule := range rules {
+		ruleType, pattern := ParseRule(rule.line)
+		if err := matcher.Add(ruleType, pattern, "rules.txt", rule.number); err != nil {
+			t.Fatalf("添加规则 %s 失败: %v", rule.line, err)
+		}
+	}
+	return matcher
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line     string
+		wantType RuleType
+		pattern  string
+	}{
+		{"example.com", Exact, "example.com"},
+		{"full:example.com", Exact, "example.com"},
+		{"+.example.com", Suffix, "example.com"},
+		{"*.example.com", Suffix, "example.com"},
+		{".example.com", Suffix, "example.com"},
+		{"domain:example.com", Suffix, "example.com"},
+		{"keyword:google", Keyword, "google"},
+		{`regexp:^cdn\d+\.example\.com$`, Regex, `^cdn\d+\.example\.com$`},
+	}
+
+	for _, tt := range tests {
+		ruleType, pattern := ParseRule(tt.line)
+		if ruleType != tt.wantType || pattern != tt.pattern {
+			t.Errorf("ParseRule(%q) = %s %q，应为 %s %q", tt.line, ruleType, pattern, tt.wantType, tt.pattern)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	matcher := newTestMatcher(t,
+		ruleLine{"full:exact.org", 1},
+		ruleLine{"plain.org", 2},
+		ruleLine{"+.plus.org", 3},
+		ruleLine{"*.star.org", 4},
+		ruleLine{"domain:domain.org", 5},
+		ruleLine{".dot.org", 6},
+		ruleLine{"keyword:tracker", 7},
+		ruleLine{`regexp:^cdn\d+\.regex\.org$`, 8},
+		// 最长后缀优先
+		ruleLine{"+.example.com", 9},
+		ruleLine{"+.api.example.com", 10},
+		// 完全匹配优先于后缀
+		ruleLine{"www.api.example.com", 11},
+		// 后缀优先于关键字和正则
+		ruleLine{"keyword:example", 12},
+		ruleLine{`regexp:example\.com$`, 13},
+		// 同一域名的同类规则只保留先添加的
+		ruleLine{"+.plus.org", 14},
+	)
+
+	tests := []struct {
+		domain   string
+		wantLine int // 0 表示不匹配
+	}{
+		// 完全匹配不包含子域名
+		{"exact.org", 1},
+		{"www.exact.org", 0},
+		{"plain.org", 2},
+		{"sub.plain.org", 0},
+
+		// 后缀规则包含域名本身及其子域名
+		{"plus.org", 3},
+		{"a.b.plus.org", 3},
+		{"star.org", 4},
+		{"www.star.org", 4},
+		{"domain.org", 5},
+		{"mail.domain.org", 5},
+		{"dot.org", 6},
+		{"x.dot.org", 6},
+		{"notplus.org", 0},
+
+		// 关键字和正则
+		{"tracker.net", 7},
+		{"ads-tracker-eu.io", 7},
+		{"cdn12.regex.org", 8},
+		{"cdn.regex.org", 0},
+
+		// 优先级
+		{"example.com", 9},
+		{"www.example.com", 9},
+		{"api.example.com", 10},
+		{"v2.api.example.com", 10},
+		{"www.api.example.com", 11},
+		{"example.net", 12},
+
+		// 规范化：大小写、末尾的点和空白
+		{"WWW.Plus.ORG.", 3},
+		{"  exact.org  ", 1},
+		{"", 0},
+		{"org", 0},
+	}
+
+	for _, tt := range tests {
+		rule, ok := matcher.Match(tt.domain)
+		if tt.wantLine == 0 {
+			if ok {
+				t.Errorf("%q 不应匹配，命中 %s", tt.domain, rule)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%q 应匹配第 %d 行的规则", tt.domain, tt.wantLine)
+			continue
+		}
+		if rule.Line != tt.wantLine {
+			t.Errorf("%q 命中 %s，应为第 %d 行", tt.domain, rule, tt.wantLine)
+		}
+	}
+}
+
+func TestMatchProvenance(t *testing.T) {
+	matcher := newTestMatcher(t,
+		ruleLine{"+.google.com", 42},
+		ruleLine{"keyword:facebook", 43},
+		ruleLine{`regexp:^tw\d+\.example$`, 44},
+		ruleLine{"full:t.co", 45},
+	)
+
+	tests := []struct {
+		domain     string
+		wantType   RuleType
+		wantOrigin string
+		wantString string
+	}{
+		{"www.google.com", Suffix, "rules.txt:42", "+.google.com（rules.txt:42）"},
+		{"facebook.net", Keyword, "rules.txt:43", "keyword:facebook（rules.txt:43）"},
+		{"tw1.example", Regex, "rules.txt:44", `regexp:^tw\d+\.example$（rules.txt:44）`},
+		{"t.co", Exact, "rules.txt:45", "t.co（rules.txt:45）"},
+	}
+
+	for _, tt := range tests {
+		rule, ok := matcher.Match(tt.domain)
+		if !ok {
+			t.Errorf("%q 应匹配", tt.domain)
+			continue
+		}
+		if rule.Type != tt.wantType || rule.Origin() != tt.wantOrigin || rule.String() != tt.wantString {
+			t.Errorf("%q 命中 %s %s %s，应为 %s %s %s", tt.domain, rule.Type, rule.Origin(), rule, tt.wantType, tt.wantOrigin, tt.wantString)
+		}
+	}
+}
+
+func TestAddInvalid(t *testing.T) {
+	matcher := New()
+	if err := matcher.Add(Suffix, " ", "rules.txt", 1); err == nil {
+		t.Error("空域名应返回错误")
+	}
+	if err := matcher.Add(Keyword, "", "rules.txt", 2); err == nil {
+		t.Error("空关键字应返回错误")
+	}
+	if err := matcher.Add(Regex, "(", "rules.txt", 3); err == nil || !strings.Contains(err.Error(), "rules.txt:3") {
+		t.Errorf("无效正则应返回带行号的错误，得到 %v", err)
+	}
+	if matcher.Len() != 0 {
+		t.Errorf("无效规则不应计入，Len() = %d", matcher.Len())
+	}
+
+	var nilMatcher *Matcher
+	if _, ok := nilMatcher.Match("example.com"); ok || nilMatcher.Len() != 0 {
+		t.Error("nil 匹配器不应匹配任何域名")
+	}
+}
+
+// gfwlistPath 仓库中的GFW列表
+const gfwlistPath = "../../data/gfwlist.conf"
+
+// loadGFWListRules 读取 gfwlist.conf 中 payload 下的规则
+func loadGFWListRules(b *testing.B) []ruleLine {
+	b.Helper()
+
+	file, err := os.Open(gfwlistPath)
+	if err != nil {
+		b.Skipf("没有GFW列表: %v", err)
+	}
+	defer file.Close()
+
+	var rules []ruleLine
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "- '") && strings.HasSuffix(line, "'") {
+			rules = append(rules, ruleLine{strings.TrimSuffix(strings.TrimPrefix(line, "- '"), "'"), lineNumber})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		b.Fatal(err)
+	}
+	return rules
+}
+
+// benchmarkDomains 命中和不命中的域名，包括多级子域名
+var benchmarkDomains = []string{
+	"www.google.com",
+	"mail.google.com",
+	"www.apple.com",
+	"a.b.c.d.example.org",
+	"www.microsoft.com",
+	"video.twitter.com",
+	"cdn.jsdelivr.net",
+	"not-in-the-list.example",
+}
+
+// BenchmarkMatch 使用 gfwlist.conf 对比前缀树匹配与之前逐条比较后缀的做法
+// 前缀树只沿域名标签走一遍，与规则数量无关；逐条比较随规则数量线性增长
+func BenchmarkMatch(b *testing.B) {
+	rules := loadGFWListRules(b)
+
+	b.Run("trie", func(b *testing.B) {
+		matcher := New()
+		for _, rule := range rules {
+			ruleType, pattern := ParseRule(rule.line)
+			matcher.Add(ruleType, pattern, "gfwlist.conf", rule.number)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			matcher.Match(benchmarkDomains[i%len(benchmarkDomains)])
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		patterns := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			_, pattern := ParseRule(rule.line)
+			patterns = append(patterns, Normalize(pattern))
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			domain := Normalize(benchmarkDomains[i%len(benchmarkDomains)])
+			for _, pattern := range patterns {
+				if domain == pattern || strings.HasSuffix(domain, "."+pattern) {
+					break
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,266 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"RealityChecker/internal/types"
+)
+
+// Dialer 出站拨号器
+// 配置上游代理时所有TCP连接经由代理建立，否则直连；配置源地址时所有连接从该地址发出
+type Dialer struct {
+	timeout time.Duration
+	proxy   types.ProxyConfig
+	source  string // 绑定的源地址或网卡名称
+	source4 net.IP // 连接IPv4地址时使用的源地址
+	source6 net.IP // 连接IPv6地址时使用的源地址
+	err     error  // 代理或源地址配置错误，Start时报告
+}
+
+// NewDialer 创建出站拨号器
+func NewDialer(config *types.NetworkConfig) *Dialer {
+	d := &Dialer{timeout: config.Timeout, proxy: config.Proxy}
+	if d.proxy.Enabled() {
+		d.err = validateProxy(d.proxy)
+	}
+	if err := d.bindSource(config); err != nil && d.err == nil {
+		d.err = err
+	}
+	return d
+}
+
+// bindSource 解析配置的源地址或网卡
+func (d *Dialer) bindSource(config *types.NetworkConfig) error {
+	switch {
+	case config.SourceAddress != "":
+		ip := net.ParseIP(config.SourceAddress)
+		if ip == nil {
+			return fmt.Errorf("源地址无效: %s", config.SourceAddress)
+		}
+		d.source = config.SourceAddress
+		d.setSource(ip)
+	case config.Interface != "":
+		iface, err := net.InterfaceByName(config.Interface)
+		if err != nil {
+			return fmt.Errorf("网卡 %s 不可用: %v", config.Interface, err)
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return fmt.Errorf("读取网卡 %s 的地址失败: %v", config.Interface, err)
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+				d.setSource(ipNet.IP)
+			}
+		}
+		if d.source4 == nil && d.source6 == nil {
+			return fmt.Errorf("网卡 %s 没有可用的地址", config.Interface)
+		}
+		d.source = config.Interface
+	}
+	return nil
+}
+
+// setSource 记录每个地址族的第一个源地址
+func (d *Dialer) setSource(ip net.IP) {
+	if ip.To4() != nil {
+		if d.source4 == nil {
+			d.source4 = ip
+		}
+	} else if d.source6 == nil {
+		d.source6 = ip
+	}
+}
+
+// localAddr 连接目标地址时绑定的本机地址，未配置源地址时返回 nil
+// 目标为域名时优先使用IPv4源地址，net.Dialer 只会尝试与之同族的解析结果
+func (d *Dialer) localAddr(network, address string) net.Addr {
+	if d.source4 == nil && d.source6 == nil {
+		return nil
+	}
+
+	source := d.source4
+	host, _, _ := net.SplitHostPort(address)
+	if ip := net.ParseIP(host); (ip != nil && ip.To4() == nil) || source == nil {
+		if d.source6 != nil {
+			source = d.source6
+		}
+	}
+
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: source}
+	}
+	return &net.TCPAddr{IP: source}
+}
+
+// netDialer 创建绑定源地址的 net.Dialer
+func (d *Dialer) netDialer(network, address string) *net.Dialer {
+	return &net.Dialer{Timeout: d.timeout, LocalAddr: d.localAddr(network, address)}
+}
+
+// validateProxy 校验代理配置
+func validateProxy(p types.ProxyConfig) error {
+	switch p.Type {
+	case types.ProxyTypeSOCKS5, types.ProxyTypeHTTP:
+	default:
+		return fmt.Errorf("不支持的代理类型: %s（可选 %s、%s）", p.Type, types.ProxyTypeSOCKS5, types.ProxyTypeHTTP)
+	}
+	if _, _, err := net.SplitHostPort(p.Address); err != nil {
+		return fmt.Errorf("代理地址无效: %s", p.Address)
+	}
+	return nil
+}
+
+// Err 代理配置错误
+func (d *Dialer) Err() error {
+	return d.err
+}
+
+// Proxied 是否经由代理连接
+func (d *Dialer) Proxied() bool {
+	return d.proxy.Enabled()
+}
+
+// Source 绑定的源地址或网卡，未配置时为空
+func (d *Dialer) Source() string {
+	return d.source
+}
+
+// Egress 出口名称，代理地址不包含认证信息
+func (d *Dialer) Egress() string {
+	if !d.Proxied() {
+		return types.EgressDirect
+	}
+	return d.proxy.Type + "://" + d.proxy.Address
+}
+
+// DialContext 建立连接，经由代理时只支持TCP
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if !d.Proxied() {
+		return d.netDialer(network, address).DialContext(ctx, network, address)
+	}
+	if !strings.HasPrefix(network, "tcp") {
+		return nil, fmt.Errorf("代理 %s 不支持 %s 连接", d.Egress(), network)
+	}
+
+	switch d.proxy.Type {
+	case types.ProxyTypeSOCKS5:
+		return d.dialSOCKS5(ctx, address)
+	default:
+		return d.dialHTTPConnect(ctx, address)
+	}
+}
+
+// HTTPTransport 经由该拨号器建立连接的HTTP传输
+func (d *Dialer) HTTPTransport() *http.Transport {
+	return newTransport(d.DialContext, d.Proxied())
+}
+
+// newTransport 创建HTTP传输，配置上游代理时不再使用环境变量中的代理
+func newTransport(dial func(context.Context, string, string) (net.Conn, error), proxied bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+	if proxied {
+		transport.Proxy = nil
+	}
+	return transport
+}
+
+// dialSOCKS5 经由SOCKS5代理建立连接
+func (d *Dialer) dialSOCKS5(ctx context.Context, address string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if d.proxy.Username != "" {
+		auth = &proxy.Auth{User: d.proxy.Username, Password: d.proxy.Password}
+	}
+
+	socks, err := proxy.SOCKS5("tcp", d.proxy.Address, auth, d.netDialer("tcp", d.proxy.Address))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := socks.(proxy.ContextDialer).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("经由代理 %s 连接 %s 失败: %w", d.Egress(), address, err)
+	}
+	return conn, nil
+}
+
+// dialHTTPConnect 经由HTTP代理的CONNECT隧道建立连接
+func (d *Dialer) dialHTTPConnect(ctx context.Context, address string) (net.Conn, error) {
+	conn, err := d.netDialer("tcp", d.proxy.Address).DialContext(ctx, "tcp", d.proxy.Address)
+	if err != nil {
+		return nil, fmt.Errorf("连接代理 %s 失败: %w", d.Egress(), err)
+	}
+
+	// 握手期间随上下文取消
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	request := "CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\n"
+	if d.proxy.Username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.proxy.Username + ":" + d.proxy.Password))
+		request += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	request += "\r\n"
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("经由代理 %s 连接 %s 失败: %w", d.Egress(), address, err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("经由代理 %s 连接 %s 失败: %w", d.Egress(), address, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("经由代理 %s 连接 %s 失败: %s", d.Egress(), address, resp.Status)
+	}
+
+	if ctx.Err() != nil {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	conn.SetDeadline(time.Time{})
+
+	// 代理在响应之后已发送的数据需要先交给调用方
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 先读取缓冲区中剩余数据的连接
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read 读取数据
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
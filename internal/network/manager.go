@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"net"
+	"net/http"
 	"sync"
 	"time"
 
@@ -17,6 +18,10 @@ type ConnectionManager struct {
 	tlsConnections  map[string]*TLSConnectionPool  // TLS连接池
 	mu              sync.RWMutex
 	stats           *types.ConnectionStats
+	dialer          *Dialer         // 出站拨号器，配置上游代理时经由代理连接
+	resolver        *Resolver       // 共享DNS缓存
+	limiter         *rateLimiter    // 按目标IP和网段的连接限制
+	transport       *http.Transport // 共享HTTP传输，经由DialContext建立连接
 }
 
 // HTTPConnectionPool HTTP连接池
@@ -39,7 +44,7 @@ type TLSConnectionPool struct {
 
 // NewConnectionManager 创建连接管理器
 func NewConnectionManager(config *types.Config) *ConnectionManager {
-	return &ConnectionManager{
+	cm := &ConnectionManager{
 		config:          config,
 		httpConnections: make(map[string]*HTTPConnectionPool),
 		tlsConnections:  make(map[string]*TLSConnectionPool),
@@ -49,10 +54,22 @@ func NewConnectionManager(config *types.Config) *ConnectionManager {
 			FailedConnections: 0,
 		},
 	}
+	cm.dialer = NewDialer(&config.Network)
+	cm.resolver = NewResolver(config, cm.dialer)
+	cm.limiter = newRateLimiter(config.Network.RateLimit)
+	cm.transport = newTransport(cm.DialContext, cm.dialer.Proxied())
+	if cm.limiter.limitsConcurrency() {
+		// 空闲的保持连接会一直占用并发名额，探测请求不复用连接
+		cm.transport.DisableKeepAlives = true
+	}
+	return cm
 }
 
 // Start 启动连接管理器
 func (cm *ConnectionManager) Start() error {
+	if err := cm.dialer.Err(); err != nil {
+		return err
+	}
 	// 启动连接清理协程
 	go cm.cleanupConnections()
 	return nil
@@ -81,14 +98,88 @@ func (cm *ConnectionManager) Stop() error {
 	}
 	cm.tlsConnections = make(map[string]*TLSConnectionPool)
 
+	cm.transport.CloseIdleConnections()
 	return nil
 }
 
+// LookupIP 通过共享DNS缓存解析域名
+func (cm *ConnectionManager) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return cm.resolver.LookupIP(ctx, host)
+}
+
+// DialContext 建立连接，域名经共享DNS缓存解析后依次尝试各地址
+// 配置上游代理时经由代理连接解析得到的地址；每个地址受IP和网段连接限制约束
+func (cm *ConnectionManager) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := cm.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		release, err := cm.limiter.acquire(ctx, ip)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := cm.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return &limitedConn{Conn: conn, release: release}, nil
+		}
+		release()
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// Egress 探测使用的出口
+func (cm *ConnectionManager) Egress() string {
+	return cm.dialer.Egress()
+}
+
+// Source 探测绑定的源地址或网卡
+func (cm *ConnectionManager) Source() string {
+	return cm.dialer.Source()
+}
+
+// Transport 共享HTTP传输，连接经由共享DNS缓存建立
+func (cm *ConnectionManager) Transport() *http.Transport {
+	return cm.transport
+}
+
+// dialContext 建立TCP连接，受上下文取消和网络超时约束
+func (cm *ConnectionManager) dialContext(ctx context.Context, address string) (net.Conn, error) {
+	return cm.DialContext(ctx, "tcp", address)
+}
+
+// handshakeContext 执行TLS握手，受上下文取消和网络超时约束
+func (cm *ConnectionManager) handshakeContext(ctx context.Context, tlsConn *tls.Conn) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	handshakeCtx, cancel := context.WithTimeout(ctx, cm.config.Network.Timeout)
+	defer cancel()
+	return tlsConn.HandshakeContext(handshakeCtx)
+}
+
 // GetHTTPConnection 获取HTTP连接
 func (cm *ConnectionManager) GetHTTPConnection(ctx context.Context, domain string) (net.Conn, error) {
 	// 总是创建新的HTTP连接
 	const httpPort = ":80"
-	conn, err := net.DialTimeout("tcp", domain+httpPort, cm.config.Network.Timeout)
+	conn, err := cm.dialContext(ctx, domain+httpPort)
 	if err != nil {
 		cm.mu.Lock()
 		cm.stats.FailedConnections++
@@ -106,7 +197,7 @@ func (cm *ConnectionManager) GetHTTPConnection(ctx context.Context, domain strin
 func (cm *ConnectionManager) GetTLSConnection(ctx context.Context, domain string) (*tls.Conn, error) {
 	// 总是创建新的TLS连接，确保ALPN协商正确
 	const tlsPort = ":443"
-	tcpConn, err := net.DialTimeout("tcp", domain+tlsPort, cm.config.Network.Timeout)
+	tcpConn, err := cm.dialContext(ctx, domain+tlsPort)
 	if err != nil {
 		cm.mu.Lock()
 		cm.stats.FailedConnections++
@@ -121,7 +212,7 @@ func (cm *ConnectionManager) GetTLSConnection(ctx context.Context, domain string
 	})
 
 	// 执行TLS握手
-	if err := tlsConn.Handshake(); err != nil {
+	if err := cm.handshakeContext(ctx, tlsConn); err != nil {
 		tcpConn.Close()
 		cm.mu.Lock()
 		cm.stats.FailedConnections++
@@ -140,7 +231,7 @@ func (cm *ConnectionManager) GetTLSConnection(ctx context.Context, domain string
 func (cm *ConnectionManager) GetX25519TLSConnection(ctx context.Context, domain string) (*tls.Conn, error) {
 	// 创建强制X25519的TLS连接
 	const tlsPort = ":443"
-	tcpConn, err := net.DialTimeout("tcp", domain+tlsPort, cm.config.Network.Timeout)
+	tcpConn, err := cm.dialContext(ctx, domain+tlsPort)
 	if err != nil {
 		cm.mu.Lock()
 		cm.stats.FailedConnections++
@@ -156,7 +247,41 @@ func (cm *ConnectionManager) GetX25519TLSConnection(ctx context.Context, domain
 	})
 
 	// 执行TLS握手
-	if err := tlsConn.Handshake(); err != nil {
+	if err := cm.handshakeContext(ctx, tlsConn); err != nil {
+		tcpConn.Close()
+		cm.mu.Lock()
+		cm.stats.FailedConnections++
+		cm.mu.Unlock()
+		return nil, err
+	}
+
+	cm.mu.Lock()
+	cm.stats.TotalConnections++
+	cm.stats.ActiveConnections++
+	cm.mu.Unlock()
+	return tlsConn, nil
+}
+
+// GetTLSConnectionToIP 使用指定SNI连接指定IP
+// 不校验证书，用于比较不同地址返回的证书和TLS参数
+func (cm *ConnectionManager) GetTLSConnectionToIP(ctx context.Context, ip, serverName string) (*tls.Conn, error) {
+	const tlsPort = "443"
+	tcpConn, err := cm.dialContext(ctx, net.JoinHostPort(ip, tlsPort))
+	if err != nil {
+		cm.mu.Lock()
+		cm.stats.FailedConnections++
+		cm.mu.Unlock()
+		return nil, err
+	}
+
+	tlsConn := tls.Client(tcpConn, &tls.Config{
+		ServerName:         serverName,
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true, // 需要拿到任意证书用于比较
+	})
+
+	// 执行TLS握手
+	if err := cm.handshakeContext(ctx, tlsConn); err != nil {
 		tcpConn.Close()
 		cm.mu.Lock()
 		cm.stats.FailedConnections++
@@ -193,12 +318,17 @@ func (cm *ConnectionManager) CloseTLSConnection(conn *tls.Conn) {
 
 // GetStats 获取连接统计信息
 func (cm *ConnectionManager) GetStats() *types.ConnectionStats {
+	hits, misses := cm.resolver.Stats()
+
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 	return &types.ConnectionStats{
 		ActiveConnections: cm.stats.ActiveConnections,
 		TotalConnections:  cm.stats.TotalConnections,
 		FailedConnections: cm.stats.FailedConnections,
+		DNSHits:           hits,
+		DNSMisses:         misses,
+		RateLimited:       cm.limiter.waits(),
 	}
 }
 
@@ -208,6 +338,8 @@ func (cm *ConnectionManager) cleanupConnections() {
 	defer ticker.Stop()
 
 	for range ticker.C {
+		cm.limiter.prune()
+
 		cm.mu.Lock()
 		now := time.Now()
 
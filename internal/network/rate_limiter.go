@@ -0,0 +1,242 @@
+package network
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// rateLimiter 按目标IP和网段限制并发连接数和新建连接速率
+// CSV扫描结果中大量域名位于同一IP或同一网段，不加限制的并发探测容易触发对方限流
+type rateLimiter struct {
+	config types.RateLimitConfig
+	clock  clock
+
+	mu      sync.Mutex
+	entries map[string]*limitEntry
+	waited  int64 // 因限制而等待的连接数
+}
+
+// limitEntry 单个IP或网段的限制状态
+type limitEntry struct {
+	slots   chan struct{} // 并发名额，为 nil 时不限制
+	tokens  float64       // 令牌桶剩余令牌
+	last    time.Time     // 上次补充令牌的时间
+	touched time.Time     // 上次使用的时间，用于清理
+}
+
+// clock 限流器使用的时钟，测试中替换为可控的时钟
+type clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool) // 返回到期通道和停止函数
+}
+
+// systemClock 系统时钟
+type systemClock struct{}
+
+// Now 当前时间
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer 创建定时器
+func (systemClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+// newRateLimiter 创建限流器
+func newRateLimiter(config types.RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config:  config,
+		clock:   systemClock{},
+		entries: make(map[string]*limitEntry),
+	}
+}
+
+// enabled 是否启用了任何限制
+func (l *rateLimiter) enabled() bool {
+	c := l.config
+	return c.PerIPConcurrent > 0 || c.PerIPRate > 0 || c.PerPrefixConcurrent > 0 || c.PerPrefixRate > 0
+}
+
+// limitsConcurrency 是否限制并发连接数
+func (l *rateLimiter) limitsConcurrency() bool {
+	return l.config.PerIPConcurrent > 0 || l.config.PerPrefixConcurrent > 0
+}
+
+// acquire 等待连接目标IP的名额，返回释放函数
+// 先占网段名额再占IP名额，顺序固定避免互相等待
+func (l *rateLimiter) acquire(ctx context.Context, ip net.IP) (func(), error) {
+	if !l.enabled() {
+		return func() {}, nil
+	}
+
+	releasePrefix, prefixWaited, err := l.acquireKey(ctx, l.prefixKey(ip), l.config.PerPrefixConcurrent, l.config.PerPrefixRate)
+	if err != nil {
+		return nil, err
+	}
+	releaseIP, ipWaited, err := l.acquireKey(ctx, ip.String(), l.config.PerIPConcurrent, l.config.PerIPRate)
+	if err != nil {
+		releasePrefix()
+		return nil, err
+	}
+
+	if prefixWaited || ipWaited {
+		l.mu.Lock()
+		l.waited++
+		l.mu.Unlock()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			releaseIP()
+			releasePrefix()
+		})
+	}, nil
+}
+
+// prefixKey 目标IP所在网段
+func (l *rateLimiter) prefixKey(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		bits := l.config.PrefixLength
+		return ip4.Mask(net.CIDRMask(bits, 32)).String() + "/" + strconv.Itoa(bits)
+	}
+	bits := l.config.PrefixLengthV6
+	return ip.Mask(net.CIDRMask(bits, 128)).String() + "/" + strconv.Itoa(bits)
+}
+
+// acquireKey 占用一个并发名额并取得一个令牌，返回释放函数和是否发生了等待
+func (l *rateLimiter) acquireKey(ctx context.Context, key string, concurrent int, rate float64) (func(), bool, error) {
+	if concurrent <= 0 && rate <= 0 {
+		return func() {}, false, nil
+	}
+
+	entry := l.entry(key, concurrent, rate)
+	waited := false
+
+	release := func() {}
+	if entry.slots != nil {
+		select {
+		case entry.slots <- struct{}{}:
+		default:
+			waited = true
+			select {
+			case entry.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, waited, ctx.Err()
+			}
+		}
+		release = func() { <-entry.slots }
+	}
+
+	if rate > 0 {
+		if delay := l.reserve(entry, rate); delay > 0 {
+			waited = true
+			expired, stop := l.clock.NewTimer(delay)
+			select {
+			case <-expired:
+			case <-ctx.Done():
+				stop()
+				l.cancelReservation(entry)
+				release()
+				return nil, waited, ctx.Err()
+			}
+		}
+	}
+
+	return release, waited, nil
+}
+
+// entry 获取或创建限制状态
+func (l *rateLimiter) entry(key string, concurrent int, rate float64) *limitEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, exists := l.entries[key]
+	if !exists {
+		entry = &limitEntry{tokens: burst(rate), last: l.clock.Now()}
+		if concurrent > 0 {
+			entry.slots = make(chan struct{}, concurrent)
+		}
+		l.entries[key] = entry
+	}
+	entry.touched = l.clock.Now()
+	return entry
+}
+
+// reserve 从令牌桶取一个令牌，令牌不足时返回需要等待的时间
+func (l *rateLimiter) reserve(entry *limitEntry, rate float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	entry.tokens += now.Sub(entry.last).Seconds() * rate
+	if max := burst(rate); entry.tokens > max {
+		entry.tokens = max
+	}
+	entry.last = now
+
+	entry.tokens--
+	if entry.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-entry.tokens / rate * float64(time.Second))
+}
+
+// cancelReservation 放弃等待时归还令牌
+func (l *rateLimiter) cancelReservation(entry *limitEntry) {
+	l.mu.Lock()
+	entry.tokens++
+	l.mu.Unlock()
+}
+
+// prune 清理一分钟内未使用且没有占用名额的状态
+func (l *rateLimiter) prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	for key, entry := range l.entries {
+		if entry.slots != nil && len(entry.slots) > 0 {
+			continue
+		}
+		if now.Sub(entry.touched) < time.Minute {
+			continue
+		}
+		delete(l.entries, key)
+	}
+}
+
+// waits 因限制而等待的连接数
+func (l *rateLimiter) waits() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waited
+}
+
+// burst 令牌桶容量，允许短时间内连续建立每秒速率个连接
+func burst(rate float64) float64 {
+	if rate < 1 {
+		return 1
+	}
+	return rate
+}
+
+// limitedConn 关闭时释放限流名额的连接
+type limitedConn struct {
+	net.Conn
+	release func()
+}
+
+// Close 关闭连接并释放名额
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.release()
+	return err
+}
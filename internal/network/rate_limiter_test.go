@@ -0,0 +1,380 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// fakeClock 手动推进的时钟，创建的定时器通过 timers 通知测试
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	pending []*fakeTimer
+	timers  chan time.Duration // 每创建一个定时器发送其时长
+}
+
+// fakeTimer 等待到期的定时器
+type fakeTimer struct {
+	deadline time.Time
+	fired    chan time.Time
+	stopped  bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		timers: make(chan time.Duration, 16),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mu.Lock()
+	timer := &fakeTimer{deadline: c.now.Add(d), fired: make(chan time.Time, 1)}
+	c.pending = append(c.pending, timer)
+	c.mu.Unlock()
+
+	c.timers <- d
+	return timer.fired, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		timer.stopped = true
+		return true
+	}
+}
+
+// Advance 推进时钟，触发到期的定时器
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	remaining := c.pending[:0]
+	for _, timer := range c.pending {
+		if timer.stopped {
+			continue
+		}
+		if !c.now.Before(timer.deadline) {
+			timer.fired <- c.now
+			continue
+		}
+		remaining = append(remaining, timer)
+	}
+	c.pending = remaining
+}
+
+// newTestLimiter 使用手动时钟的限流器
+func newTestLimiter(config types.RateLimitConfig) (*rateLimiter, *fakeClock) {
+	if config.PrefixLength == 0 {
+		config.PrefixLength = 24
+	}
+	if config.PrefixLengthV6 == 0 {
+		config.PrefixLengthV6 = 48
+	}
+	clock := newFakeClock()
+	limiter := newRateLimiter(config)
+	limiter.clock = clock
+	return limiter, clock
+}
+
+// cancelledContext 已取消的上下文，名额已满时 acquire 立即返回错误而不是等待
+func cancelledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// acquireAsync 在后台等待名额
+func acquireAsync(ctx context.Context, limiter *rateLimiter, ip string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := limiter.acquire(ctx, net.ParseIP(ip))
+		done <- err
+	}()
+	return done
+}
+
+// expectTimer 等待限流器为令牌创建定时器并检查时长
+func expectTimer(t *testing.T, clock *fakeClock, want time.Duration) {
+	t.Helper()
+	select {
+	case got := <-clock.timers:
+		if got != want {
+			t.Fatalf("等待 %v，应为 %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("应等待令牌")
+	}
+}
+
+// expectNoTimer 检查没有为令牌等待
+func expectNoTimer(t *testing.T, clock *fakeClock) {
+	t.Helper()
+	select {
+	case got := <-clock.timers:
+		t.Fatalf("不应等待令牌，等待了 %v", got)
+	default:
+	}
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  types.RateLimitConfig
+		held    []string // 先占用名额的IP
+		blocked []string // 名额已满的IP
+		allowed []string // 仍有名额的IP
+	}{
+		{
+			name:    "同一IP",
+			config:  types.RateLimitConfig{PerIPConcurrent: 2},
+			held:    []string{"192.0.2.1", "192.0.2.1"},
+			blocked: []string{"192.0.2.1"},
+			allowed: []string{"192.0.2.2", "198.51.100.1"},
+		},
+		{
+			name:    "同一IPv4网段",
+			config:  types.RateLimitConfig{PerPrefixConcurrent: 2},
+			held:    []string{"192.0.2.1", "192.0.2.2"},
+			blocked: []string{"192.0.2.200"},
+			allowed: []string{"192.0.3.1", "198.51.100.1"},
+		},
+		{
+			name:    "同一IPv6网段",
+			config:  types.RateLimitConfig{PerPrefixConcurrent: 1},
+			held:    []string{"2001:db8:1::1"},
+			blocked: []string{"2001:db8:1:ffff::2"},
+			allowed: []string{"2001:db8:2::1"},
+		},
+		{
+			name:    "网段和IP同时限制",
+			config:  types.RateLimitConfig{PerIPConcurrent: 1, PerPrefixConcurrent: 3},
+			held:    []string{"192.0.2.1", "192.0.2.2"},
+			blocked: []string{"192.0.2.1", "192.0.2.2"},
+			allowed: []string{"192.0.2.3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, _ := newTestLimiter(tt.config)
+
+			var releases []func()
+			for _, ip := range tt.held {
+				release, err := limiter.acquire(context.Background(), net.ParseIP(ip))
+				if err != nil {
+					t.Fatalf("占用 %s 的名额失败: %v", ip, err)
+				}
+				releases = append(releases, release)
+			}
+
+			for _, ip := range tt.blocked {
+				if _, err := limiter.acquire(cancelledContext(), net.ParseIP(ip)); err == nil {
+					t.Errorf("%s 的名额已满，应等待", ip)
+				}
+			}
+			for _, ip := range tt.allowed {
+				release, err := limiter.acquire(cancelledContext(), net.ParseIP(ip))
+				if err != nil {
+					t.Errorf("%s 应有名额: %v", ip, err)
+					continue
+				}
+				release()
+			}
+
+			// 释放后名额可以再次使用，重复释放不会多出名额
+			for _, release := range releases {
+				release()
+				release()
+			}
+			for _, ip := range tt.blocked {
+				release, err := limiter.acquire(cancelledContext(), net.ParseIP(ip))
+				if err != nil {
+					t.Errorf("释放后 %s 应有名额: %v", ip, err)
+					continue
+				}
+				defer release()
+			}
+		})
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   types.RateLimitConfig
+		burst    []string      // 令牌桶容量内立即建立的连接
+		next     string        // 超出容量后需要等待的连接
+		wait     time.Duration // 需要等待的时间
+		separate string        // 不受影响的IP
+	}{
+		{
+			name:     "同一IP每秒2个",
+			config:   types.RateLimitConfig{PerIPRate: 2},
+			burst:    []string{"192.0.2.1", "192.0.2.1"},
+			next:     "192.0.2.1",
+			wait:     500 * time.Millisecond,
+			separate: "192.0.2.2",
+		},
+		{
+			name:     "低于每秒1个时容量为1",
+			config:   types.RateLimitConfig{PerIPRate: 0.5},
+			burst:    []string{"192.0.2.1"},
+			next:     "192.0.2.1",
+			wait:     2 * time.Second,
+			separate: "192.0.2.2",
+		},
+		{
+			name:     "同一网段每秒1个",
+			config:   types.RateLimitConfig{PerPrefixRate: 1},
+			burst:    []string{"192.0.2.1"},
+			next:     "192.0.2.2",
+			wait:     time.Second,
+			separate: "192.0.3.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, clock := newTestLimiter(tt.config)
+
+			for _, ip := range tt.burst {
+				if _, err := limiter.acquire(context.Background(), net.ParseIP(ip)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expectNoTimer(t, clock)
+
+			done := acquireAsync(context.Background(), limiter, tt.next)
+			expectTimer(t, clock, tt.wait)
+
+			// 其他IP或网段不受影响
+			if _, err := limiter.acquire(context.Background(), net.ParseIP(tt.separate)); err != nil {
+				t.Fatal(err)
+			}
+			expectNoTimer(t, clock)
+
+			clock.Advance(tt.wait - time.Millisecond)
+			select {
+			case err := <-done:
+				t.Fatalf("未到时间不应取得令牌: %v", err)
+			default:
+			}
+			clock.Advance(time.Millisecond)
+			if err := <-done; err != nil {
+				t.Fatalf("到时间后应取得令牌: %v", err)
+			}
+			if limiter.waits() != 1 {
+				t.Errorf("waits() = %d，应为 1", limiter.waits())
+			}
+		})
+	}
+}
+
+func TestRateRefill(t *testing.T) {
+	limiter, clock := newTestLimiter(types.RateLimitConfig{PerIPRate: 2})
+	ip := net.ParseIP("192.0.2.1")
+
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.acquire(context.Background(), ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 空闲一段时间后补充令牌，但不超过容量
+	clock.Advance(10 * time.Second)
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.acquire(context.Background(), ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectNoTimer(t, clock)
+
+	done := acquireAsync(context.Background(), limiter, "192.0.2.1")
+	expectTimer(t, clock, 500*time.Millisecond)
+	clock.Advance(500 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancelWhileWaitingForToken(t *testing.T) {
+	limiter, clock := newTestLimiter(types.RateLimitConfig{PerIPConcurrent: 2, PerIPRate: 1})
+	ip := net.ParseIP("192.0.2.1")
+
+	release, err := limiter.acquire(context.Background(), ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := acquireAsync(ctx, limiter, "192.0.2.1")
+	expectTimer(t, clock, time.Second)
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("取消后应返回错误")
+	}
+
+	// 放弃等待时归还令牌和并发名额：一秒后恰好补充一个令牌，不需要等待
+	clock.Advance(time.Second)
+	second, err := limiter.acquire(cancelledContext(), ip)
+	if err != nil {
+		t.Fatalf("放弃等待后应归还名额和令牌: %v", err)
+	}
+	second()
+	expectNoTimer(t, clock)
+}
+
+func TestPrune(t *testing.T) {
+	limiter, clock := newTestLimiter(types.RateLimitConfig{PerIPConcurrent: 1})
+
+	held, err := limiter.acquire(context.Background(), net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held()
+	idle, err := limiter.acquire(context.Background(), net.ParseIP("192.0.2.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle()
+
+	clock.Advance(30 * time.Second)
+	limiter.prune()
+	if len(limiter.entries) != 2 {
+		t.Fatalf("一分钟内使用过的状态应保留，剩余 %d 个", len(limiter.entries))
+	}
+
+	clock.Advance(time.Minute)
+	limiter.prune()
+	if _, exists := limiter.entries["192.0.2.2"]; exists {
+		t.Error("空闲超过一分钟的状态应清理")
+	}
+	if _, exists := limiter.entries["192.0.2.1"]; !exists {
+		t.Error("仍占用名额的状态不应清理")
+	}
+}
+
+func TestDisabledLimiter(t *testing.T) {
+	limiter, clock := newTestLimiter(types.RateLimitConfig{})
+	for i := 0; i < 100; i++ {
+		if _, err := limiter.acquire(cancelledContext(), net.ParseIP("192.0.2.1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectNoTimer(t, clock)
+	if len(limiter.entries) != 0 || limiter.waits() != 0 {
+		t.Error("未启用限制时不应记录状态")
+	}
+}
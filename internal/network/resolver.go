@@ -0,0 +1,506 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"RealityChecker/internal/types"
+)
+
+// DNS缓存参数
+const (
+	minDNSTTL      = 10 * time.Second // 记录TTL过短时至少缓存到单个目标检测完成
+	maxDNSTTL      = time.Hour
+	negativeDNSTTL = 30 * time.Second // 域名不存在的结果缓存时间
+	systemDNSTTL   = minDNSTTL        // 系统解析器不返回TTL，只缓存最短时间，更长的缓存由系统解析器按记录TTL处理
+	dnsSweepPeriod = time.Minute      // 写入缓存时清理过期条目的间隔
+	maxDNSEntries  = 10000            // 缓存条目上限，超出时移除最早写入的条目
+	maxUDPSize     = 1232
+	dohContentType = "application/dns-message"
+)
+
+// Resolver 共享DNS解析器
+// 按记录TTL缓存A/AAAA查询结果，同一域名的并发查询只发起一次
+// 默认使用系统解析器；设置 use_dns_servers 时向配置的DNS服务器查询
+// 配置上游代理时总是经由代理向DNS服务器使用DNS over TCP或DoH查询，不回退到本机解析器
+type Resolver struct {
+	config *types.Config
+	dialer *Dialer
+	doh    *http.Client
+
+	mu       sync.Mutex
+	cache    types.DNSCache
+	inflight map[string]*dnsLookup
+	swept    time.Time // 上次清理过期条目的时间
+	hits     int64
+	misses   int64
+}
+
+// dnsLookup 进行中的查询
+type dnsLookup struct {
+	done      chan struct{}
+	ips       []net.IP
+	err       error
+	cancelled bool // 发起查询的调用方超时或被中断，结果不可共用
+}
+
+// NewResolver 创建DNS解析器
+func NewResolver(config *types.Config, dialer *Dialer) *Resolver {
+	return &Resolver{
+		config:   config,
+		dialer:   dialer,
+		doh:      &http.Client{Transport: dialer.HTTPTransport()},
+		cache:    types.DNSCache{Cache: make(map[string]*types.DNSEntry), TTL: systemDNSTTL},
+		inflight: make(map[string]*dnsLookup),
+	}
+}
+
+// LookupIP 解析域名，IPv4地址在前
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for {
+		r.mu.Lock()
+		if entry, ok := r.cachedEntry(host); ok {
+			r.hits++
+			r.mu.Unlock()
+			return entryResult(host, entry)
+		}
+
+		lookup, ok := r.inflight[host]
+		if !ok {
+			r.misses++
+			lookup = &dnsLookup{done: make(chan struct{})}
+			r.inflight[host] = lookup
+			r.mu.Unlock()
+			return r.run(ctx, host, lookup)
+		}
+
+		// 已有相同域名的查询在进行，等待其结果，共用结果时计为命中
+		r.mu.Unlock()
+		select {
+		case <-lookup.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !lookup.cancelled {
+			r.mu.Lock()
+			r.hits++
+			r.mu.Unlock()
+			return lookup.ips, lookup.err
+		}
+		// 发起查询的调用方超时或被中断，使用自己的上下文重新查询，本次等待不计数
+	}
+}
+
+// run 执行查询并唤醒等待同一域名的调用方
+func (r *Resolver) run(ctx context.Context, host string, lookup *dnsLookup) ([]net.IP, error) {
+	ips, ttl, err := r.resolve(ctx, host)
+
+	r.mu.Lock()
+	delete(r.inflight, host)
+	if r.config.Cache.DNSEnabled {
+		r.store(host, ips, ttl, err)
+	}
+	r.mu.Unlock()
+
+	lookup.ips, lookup.err = ips, err
+	lookup.cancelled = err != nil && ctx.Err() != nil
+	close(lookup.done)
+	return ips, err
+}
+
+// Stats DNS缓存命中和未命中次数
+func (r *Resolver) Stats() (hits, misses int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hits, r.misses
+}
+
+// cachedEntry 查找未过期的缓存条目，调用方持有锁
+func (r *Resolver) cachedEntry(host string) (*types.DNSEntry, bool) {
+	if !r.config.Cache.DNSEnabled {
+		return nil, false
+	}
+	entry, exists := r.cache.Cache[host]
+	if !exists {
+		return nil, false
+	}
+	if time.Since(entry.Timestamp) > entry.TTL {
+		delete(r.cache.Cache, host)
+		return nil, false
+	}
+	return entry, true
+}
+
+// store 缓存查询结果，域名不存在时短暂缓存，其他错误不缓存。调用方持有锁
+func (r *Resolver) store(host string, ips []net.IP, ttl time.Duration, err error) {
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		ttl = negativeDNSTTL
+	default:
+		return
+	}
+
+	if ttl < minDNSTTL {
+		ttl = minDNSTTL
+	}
+	if ttl > maxDNSTTL {
+		ttl = maxDNSTTL
+	}
+
+	now := time.Now()
+	r.sweep(now)
+
+	entry := &types.DNSEntry{Timestamp: now, TTL: ttl}
+	for _, ip := range ips {
+		entry.IPs = append(entry.IPs, ip.String())
+	}
+	r.cache.Cache[host] = entry
+}
+
+// sweep 清理过期条目，条目数达到上限时移除最早写入的条目。调用方持有锁
+// 过期条目按间隔清理，不必在每次写入时遍历缓存
+func (r *Resolver) sweep(now time.Time) {
+	if now.Sub(r.swept) >= dnsSweepPeriod || len(r.cache.Cache) >= maxDNSEntries {
+		r.swept = now
+		for host, entry := range r.cache.Cache {
+			if now.Sub(entry.Timestamp) > entry.TTL {
+				delete(r.cache.Cache, host)
+			}
+		}
+	}
+
+	for len(r.cache.Cache) >= maxDNSEntries {
+		oldestHost := ""
+		var oldest time.Time
+		for host, entry := range r.cache.Cache {
+			if oldestHost == "" || entry.Timestamp.Before(oldest) {
+				oldestHost, oldest = host, entry.Timestamp
+			}
+		}
+		delete(r.cache.Cache, oldestHost)
+	}
+}
+
+// entryResult 将缓存条目转换为查询结果
+func entryResult(host string, entry *types.DNSEntry) ([]net.IP, error) {
+	if len(entry.IPs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	ips := make([]net.IP, 0, len(entry.IPs))
+	for _, ip := range entry.IPs {
+		ips = append(ips, net.ParseIP(ip))
+	}
+	return ips, nil
+}
+
+// resolve 解析域名
+// 直连且未设置 use_dns_servers 时使用系统解析器；否则依次向配置的DNS服务器查询，直连时全部因网络原因失败后回退到系统解析器
+func (r *Resolver) resolve(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if !r.config.Network.UseDNSServers && !r.dialer.Proxied() {
+		return r.resolveSystem(ctx, host)
+	}
+
+	var lastErr error
+	for _, server := range r.config.Network.DNSServers {
+		ips, ttl, err := r.queryServer(ctx, server, host)
+		if err == nil {
+			return ips, ttl, nil
+		}
+		lastErr = err
+
+		// 域名不存在是确定的结果，不再询问其他服务器
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, 0, err
+		}
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+	}
+
+	// 经由代理时本机解析器的结果不代表出口处的解析结果
+	if r.dialer.Proxied() {
+		if lastErr == nil {
+			lastErr = &net.DNSError{Err: "未配置DNS服务器", Name: host}
+		}
+		return nil, 0, lastErr
+	}
+
+	return r.resolveSystem(ctx, host)
+}
+
+// resolveSystem 使用系统解析器
+// 系统解析器不返回记录TTL，结果只缓存 systemDNSTTL，足够单个目标检测期间共用
+func (r *Resolver) resolveSystem(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return sortIPv4First(ips), systemDNSTTL, nil
+}
+
+// queryServer 向单个DNS服务器并发查询A和AAAA记录
+func (r *Resolver) queryServer(ctx context.Context, server, host string) ([]net.IP, time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil && !isDoH(server) {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	type answer struct {
+		ips []net.IP
+		ttl time.Duration
+		err error
+	}
+	answers := make(chan answer, 2)
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		go func(qtype dnsmessage.Type) {
+			ips, ttl, err := r.query(ctx, server, host, qtype)
+			answers <- answer{ips, ttl, err}
+		}(qtype)
+	}
+
+	var ips []net.IP
+	var ttl time.Duration
+	var firstErr error
+	for i := 0; i < 2; i++ {
+		a := <-answers
+		if a.err != nil {
+			if firstErr == nil {
+				firstErr = a.err
+			}
+			continue
+		}
+		ips = append(ips, a.ips...)
+		if len(a.ips) > 0 && (ttl == 0 || a.ttl < ttl) {
+			ttl = a.ttl
+		}
+	}
+
+	if len(ips) == 0 {
+		if firstErr != nil {
+			return nil, 0, firstErr
+		}
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+	}
+	return sortIPv4First(ips), ttl, nil
+}
+
+// query 发送一次DNS查询
+// 直连时使用UDP，响应被截断时改用TCP；经由代理时使用TCP；DoH服务器使用HTTPS
+func (r *Resolver) query(ctx context.Context, server, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, IsNotFound: true}
+	}
+
+	var idBytes [2]byte
+	rand.Read(idBytes[:])
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	request, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.config.Network.Timeout)
+	defer cancel()
+
+	var response []byte
+	switch {
+	case isDoH(server):
+		response, err = r.exchangeHTTPS(queryCtx, server, request)
+	case r.dialer.Proxied():
+		response, err = r.exchange(queryCtx, "tcp", server, request)
+	default:
+		response, err = r.exchange(queryCtx, "udp", server, request)
+		if err == nil {
+			var header dnsmessage.Header
+			var parser dnsmessage.Parser
+			if header, err = parser.Start(response); err == nil && header.Truncated {
+				response, err = r.exchange(queryCtx, "tcp", server, request)
+			}
+		}
+	}
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTimeout: isTimeout(err), IsTemporary: true}
+	}
+
+	return parseAnswer(response, id, host, server)
+}
+
+// exchange 发送请求并读取响应，TCP使用2字节长度前缀
+func (r *Resolver) exchange(ctx context.Context, network, server string, request []byte) ([]byte, error) {
+	conn, err := r.dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, maxUDPSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	framed := make([]byte, 2+len(request))
+	binary.BigEndian.PutUint16(framed, uint16(len(request)))
+	copy(framed[2:], request)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// exchangeHTTPS 通过DoH（RFC 8484）发送请求
+func (r *Resolver) exchangeHTTPS(ctx context.Context, server string, request []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := r.doh.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH服务器返回 %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 65535))
+}
+
+// isDoH 是否为DoH服务器地址
+func isDoH(server string) bool {
+	return strings.HasPrefix(server, "https://")
+}
+
+// parseAnswer 解析DNS响应，返回地址和最小TTL
+func parseAnswer(response []byte, id uint16, host, server string) ([]net.IP, time.Duration, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTemporary: true}
+	}
+	if header.ID != id {
+		return nil, 0, &net.DNSError{Err: "DNS响应ID不匹配", Name: host, Server: server, IsTemporary: true}
+	}
+
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+	default:
+		return nil, 0, &net.DNSError{Err: fmt.Sprintf("DNS服务器返回 %s", header.RCode), Name: host, Server: server, IsTemporary: true}
+	}
+
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTemporary: true}
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	for {
+		answerHeader, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTemporary: true}
+		}
+
+		// CNAME链上的记录TTL同样限制缓存时间
+		if ttl == 0 || answerHeader.TTL < ttl {
+			ttl = answerHeader.TTL
+		}
+
+		switch answerHeader.Type {
+		case dnsmessage.TypeA:
+			record, err := parser.AResource()
+			if err != nil {
+				return nil, 0, err
+			}
+			ips = append(ips, net.IP(record.A[:]))
+		case dnsmessage.TypeAAAA:
+			record, err := parser.AAAAResource()
+			if err != nil {
+				return nil, 0, err
+			}
+			ips = append(ips, net.IP(record.AAAA[:]))
+		default:
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+// sortIPv4First IPv4地址排在IPv6之前，同类地址保持原有顺序
+func sortIPv4First(ips []net.IP) []net.IP {
+	sorted := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			sorted = append(sorted, ip)
+		}
+	}
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			sorted = append(sorted, ip)
+		}
+	}
+	return sorted
+}
+
+// isTimeout 是否为超时错误
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
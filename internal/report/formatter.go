@@ -29,9 +29,52 @@ func (f *Formatter) FormatSingleResult(result *types.DetectionResult) string {
 
 	// 显示域名检测结果表格（无论适合与否）
 	output.WriteString("检测结果:\n\n")
+	if result.Cached {
+		output.WriteString(fmt.Sprintf("（缓存结果，检测于 %s，使用 --refresh-cache 重新检测）\n\n", result.StartTime.Format("2006-01-02 15:04:05")))
+	}
+	if result.Egress != "" && result.Egress != types.EgressDirect {
+		output.WriteString(fmt.Sprintf("（经由 %s 检测）\n\n", result.Egress))
+	}
 	output.WriteString(tableFormatter.FormatSuitableTable([]*types.DetectionResult{result}))
 	output.WriteString("\n")
 
+	// 显示提示信息（不影响适合性，例如伪装较弱）
+	if result.Summary != nil && len(result.Summary.Warnings) > 0 {
+		output.WriteString("提示:\n")
+		for _, warning := range result.Summary.Warnings {
+			output.WriteString(fmt.Sprintf("   - %s\n", warning))
+		}
+		output.WriteString("\n")
+	}
+
+	// 证书不一致时列出观察到的所有证书
+	if result.Certificate != nil && result.Certificate.Varies {
+		output.WriteString("观察到的证书:\n")
+		for i, cert := range result.Certificate.ObservedCertificates {
+			output.WriteString(fmt.Sprintf("   %d. SHA256=%s... 签发者=%s 密钥=%s 地址=%s 次数=%d\n",
+				i+1, cert.SHA256[:16], cert.Issuer, cert.KeyType, strings.Join(cert.IPs, ","), cert.Count))
+		}
+		output.WriteString("\n")
+	}
+
+	// 多个硬性条件未通过时全部列出（完整诊断模式下最常见）
+	if len(result.FailedRequirements) > 1 {
+		output.WriteString("未通过的条件:\n")
+		for _, requirement := range result.FailedRequirements {
+			output.WriteString(fmt.Sprintf("   - %s\n", requirement.Message))
+		}
+		output.WriteString("\n")
+	}
+
+	// 评分明细
+	output.WriteString(f.formatScoreBreakdown(result))
+
+	// 外部插件的判定
+	output.WriteString(f.formatPluginResults(result))
+
+	// 各检测阶段的执行记录，便于排查被拒绝的原因
+	output.WriteString(f.formatStageReports(result.Stages))
+
 	// 如果不适合，显示不适合的原因
 	if !result.Suitable || result.Error != nil {
 		var unsuitableResults []*types.DetectionResult
@@ -42,6 +85,85 @@ func (f *Formatter) FormatSingleResult(result *types.DetectionResult) string {
 	return output.String()
 }
 
+// formatScoreBreakdown 格式化评分明细
+func (f *Formatter) formatScoreBreakdown(result *types.DetectionResult) string {
+	if len(result.ScoreBreakdown) == 0 {
+		return ""
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("评分: %.1f / 100\n", result.Score))
+	for _, factor := range result.ScoreBreakdown {
+		description := factor.Description
+		if description == "" {
+			description = factor.Name
+		}
+		output.WriteString(fmt.Sprintf("   %5.1f / %-5.1f %s（%s）\n",
+			factor.Contribution, factor.MaxScore, description, factor.Value))
+	}
+	output.WriteString("\n")
+
+	return output.String()
+}
+
+// formatPluginResults 按配置顺序格式化外部插件结果
+func (f *Formatter) formatPluginResults(result *types.DetectionResult) string {
+	if len(result.Plugins) == 0 {
+		return ""
+	}
+
+	var output strings.Builder
+	output.WriteString("插件:\n")
+	for _, plugin := range f.config.Plugins {
+		pluginResult := result.Plugins[plugin.Name]
+		if pluginResult == nil {
+			continue
+		}
+		verdict := "通过"
+		if pluginResult.Verdict == types.PluginVerdictFail {
+			verdict = "不适合"
+		}
+		line := fmt.Sprintf("   %-18s %s", plugin.Name, verdict)
+		if pluginResult.Reason != "" {
+			line += "  " + pluginResult.Reason
+		}
+		output.WriteString(line + "\n")
+	}
+	output.WriteString("\n")
+
+	return output.String()
+}
+
+// formatStageReports 格式化检测阶段执行记录
+func (f *Formatter) formatStageReports(stages []types.StageReport) string {
+	if len(stages) == 0 {
+		return ""
+	}
+
+	var output strings.Builder
+	output.WriteString("检测阶段:\n")
+	for _, stage := range stages {
+		line := fmt.Sprintf("   %-18s %s", stage.Name, types.StageOutcomeName(stage.Outcome))
+		if !stage.Start.IsZero() {
+			line += fmt.Sprintf("  %s", f.formatDuration(stage.Duration))
+		}
+		if stage.Error != "" {
+			line += fmt.Sprintf("  %s", stage.Error)
+		}
+		output.WriteString(line + "\n")
+		for _, evidence := range stage.Evidence {
+			output.WriteString(fmt.Sprintf("      · %s\n", evidence))
+		}
+		for _, retry := range stage.Retries {
+			output.WriteString(fmt.Sprintf("      ↻ %s 第%d次失败，%s后重试: %s\n",
+				retry.Operation, retry.Attempt, f.formatDuration(retry.Backoff), retry.Error))
+		}
+	}
+	output.WriteString("\n")
+
+	return output.String()
+}
+
 // FormatBatchResult 格式化批量检测结果
 func (f *Formatter) FormatBatchResult(results []*types.DetectionResult, totalDuration time.Duration) string {
 	var output strings.Builder
@@ -51,7 +173,7 @@ func (f *Formatter) FormatBatchResult(results []*types.DetectionResult, totalDur
 	successCount := 0
 
 	for _, result := range results {
-		if result.Error == nil {
+		if !types.IsCheckFailure(result.ReasonCode) {
 			successCount++
 		}
 		if result.Suitable {
@@ -0,0 +1,62 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"RealityChecker/internal/types"
+)
+
+// 输出格式常量
+const (
+	FormatTable = "table" // 表格输出
+	FormatJSON  = "json"  // JSON输出
+)
+
+// IsJSONOutput 是否使用JSON输出
+func IsJSONOutput(config *types.Config) bool {
+	return config != nil && config.Output.Format == FormatJSON
+}
+
+// resultOutput 检测结果的输出位置，始终为进程的标准输出
+var resultOutput io.Writer = os.Stdout
+
+// ResultOutput 检测结果（JSON）的输出位置
+func ResultOutput() io.Writer {
+	return resultOutput
+}
+
+// ProgressOutput 横幅、进度和提示信息的输出位置
+// JSON输出时写到标准错误，标准输出只保留检测结果
+func ProgressOutput(jsonOutput bool) io.Writer {
+	if jsonOutput {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// FormatSingleResultJSON 将单个检测结果格式化为JSON
+func (f *Formatter) FormatSingleResultJSON(result *types.DetectionResult) (string, error) {
+	return formatJSON(result)
+}
+
+// FormatBatchReportJSON 将批量报告格式化为JSON
+func (f *Formatter) FormatBatchReportJSON(report *types.BatchReport) (string, error) {
+	return formatJSON(report)
+}
+
+// FormatSourceComparisonJSON 将多源地址对比报告格式化为JSON
+func (f *Formatter) FormatSourceComparisonJSON(comparison *types.SourceComparison) (string, error) {
+	return formatJSON(comparison)
+}
+
+// formatJSON 格式化为缩进的JSON
+func formatJSON(v interface{}) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("生成JSON失败: %v", err)
+	}
+	return string(data) + "\n", nil
+}
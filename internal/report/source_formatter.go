@@ -0,0 +1,145 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"RealityChecker/internal/types"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// FormatSourceComparison 格式化多源地址对比报告
+func (tf *TableFormatter) FormatSourceComparison(comparison *types.SourceComparison) string {
+	var buf strings.Builder
+
+	buf.WriteString("\n多源地址对比\n\n")
+	if comparison.Interrupted {
+		buf.WriteString(fmt.Sprintf("检测被中断，只完成了 %d/%d 个源地址\n\n", len(comparison.Reports), len(comparison.Sources)))
+	}
+
+	// 各源地址概况
+	summary := newComparisonTable(&buf)
+	summary.AppendHeader(table.Row{"源地址", "检测成功", "适合", "耗时"})
+	for i, batchReport := range comparison.Reports {
+		stats := batchReport.Statistics
+		summary.AppendRow(table.Row{
+			comparison.Sources[i],
+			fmt.Sprintf("%d/%d", stats.SuccessfulChecks, stats.TotalDomains),
+			fmt.Sprintf("%d/%d", stats.SuitableDomains, stats.TotalDomains),
+			batchReport.TotalDuration.Round(100 * time.Millisecond).String(),
+		})
+	}
+	summary.Render()
+	buf.WriteString("\n")
+
+	if len(comparison.Reports) < 2 {
+		return buf.String()
+	}
+
+	// 所有源地址都适合的目标
+	if common := commonSuitable(comparison.Reports); len(common) > 0 {
+		buf.WriteString(fmt.Sprintf("所有源地址均适合 (%d个): %s\n\n", len(common), strings.Join(common, ", ")))
+	}
+
+	if len(comparison.Differences) == 0 {
+		buf.WriteString("各源地址的检测结论一致\n")
+		return buf.String()
+	}
+
+	// 结论不一致的目标
+	buf.WriteString(fmt.Sprintf("结论不一致的目标 (%d个):\n\n", len(comparison.Differences)))
+	differences := newComparisonTable(&buf)
+	header := table.Row{"目标"}
+	for _, source := range comparison.Sources[:len(comparison.Reports)] {
+		header = append(header, source)
+	}
+	differences.AppendHeader(header)
+
+	lookups := make([]map[types.Target]*types.DetectionResult, len(comparison.Reports))
+	for i, batchReport := range comparison.Reports {
+		lookups[i] = resultsByTarget(batchReport.Results)
+	}
+	for _, target := range comparison.Differences {
+		name := target.Domain
+		if target.IP != "" {
+			name += " (" + target.IP + ")"
+		}
+		row := table.Row{name}
+		for _, lookup := range lookups {
+			row = append(row, tf.sourceVerdict(lookup[target]))
+		}
+		differences.AppendRow(row)
+	}
+	differences.Render()
+
+	return buf.String()
+}
+
+// sourceVerdict 单个源地址的检测结论：适合时显示星级和分数，否则显示原因
+func (tf *TableFormatter) sourceVerdict(result *types.DetectionResult) string {
+	switch {
+	case result == nil:
+		return "-"
+	case result.Suitable:
+		return tf.calculateRecommendationStars(result)
+	case result.ReasonCode != "":
+		return text.FgRed.Sprint(types.ReasonName(result.ReasonCode))
+	default:
+		return text.FgRed.Sprint("不适合")
+	}
+}
+
+// newComparisonTable 创建与其他报告风格一致的表格
+func newComparisonTable(buf *strings.Builder) table.Writer {
+	t := table.NewWriter()
+	t.SetOutputMirror(buf)
+	t.SetStyle(table.StyleDefault)
+	t.Style().Options.SeparateColumns = true
+	t.Style().Options.DrawBorder = true
+	t.Style().Options.SeparateHeader = true
+	t.Style().Color.Header = []text.Color{text.FgHiWhite, text.Bold}
+	t.Style().Color.Row = []text.Color{text.FgWhite}
+	t.Style().Color.Border = []text.Color{text.FgWhite}
+	return t
+}
+
+// resultsByTarget 按检测目标索引结果
+func resultsByTarget(results []*types.DetectionResult) map[types.Target]*types.DetectionResult {
+	lookup := make(map[types.Target]*types.DetectionResult, len(results))
+	for _, result := range results {
+		if result != nil {
+			lookup[types.Target{Domain: result.Domain, IP: result.TargetIP}] = result
+		}
+	}
+	return lookup
+}
+
+// commonSuitable 在每个源地址都适合的目标，按第一个源地址的结果顺序
+func commonSuitable(reports []*types.BatchReport) []string {
+	lookups := make([]map[types.Target]*types.DetectionResult, len(reports))
+	for i, batchReport := range reports {
+		lookups[i] = resultsByTarget(batchReport.Results)
+	}
+
+	var common []string
+	for _, result := range reports[0].Results {
+		if result == nil || !result.Suitable {
+			continue
+		}
+		target := types.Target{Domain: result.Domain, IP: result.TargetIP}
+		suitable := true
+		for _, lookup := range lookups[1:] {
+			if other := lookup[target]; other == nil || !other.Suitable {
+				suitable = false
+				break
+			}
+		}
+		if suitable {
+			common = append(common, result.Domain)
+		}
+	}
+	return common
+}
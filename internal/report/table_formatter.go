@@ -2,6 +2,7 @@ package report
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"RealityChecker/internal/types"
@@ -160,6 +161,10 @@ func (tf *TableFormatter) FormatSuitableTable(results []*types.DetectionResult)
 				default:
 					pageStatusText = text.FgRed.Sprint(fmt.Sprintf("%d", statusCode))
 				}
+				// 页面内容不自然时追加页面类型
+				if result.PageStatus != nil && types.IsPageTypeUnsuitable(result.PageStatus.PageType) {
+					pageStatusText += text.FgRed.Sprint(" " + types.PageTypeName(result.PageStatus.PageType))
+				}
 			} else {
 				pageStatusText = text.FgRed.Sprint("不可访问")
 			}
@@ -194,19 +199,46 @@ func (tf *TableFormatter) FormatUnsuitableSummary(results []*types.DetectionResu
 	var buf strings.Builder
 	buf.WriteString(fmt.Sprintf("不适合的域名 (%d个):\n", len(results)))
 
-	// 统计各种不适合的原因
-	reasonCounts := make(map[string]int)
+	// 按原因代码分组，同一代码的不同详情（例如不同的状态码）归为一组
+	type reasonGroup struct {
+		code     string
+		count    int
+		messages []string
+	}
+	var groups []*reasonGroup
+	groupByCode := make(map[string]*reasonGroup)
 
 	for _, result := range results {
-		if result.Error != nil {
-			reason := result.Error.Error()
-			reasonCounts[reason]++
+		if result.ReasonCode == "" {
+			continue
+		}
+		group := groupByCode[result.ReasonCode]
+		if group == nil {
+			group = &reasonGroup{code: result.ReasonCode}
+			groupByCode[result.ReasonCode] = group
+			groups = append(groups, group)
+		}
+		group.count++
+		if result.Error != nil && !containsString(group.messages, result.Error.Error()) {
+			group.messages = append(group.messages, result.Error.Error())
 		}
 	}
 
-	// 显示统计信息，按原因分组
-	for reason, count := range reasonCounts {
-		buf.WriteString(fmt.Sprintf("   - %d个%s\n", count, reason))
+	// 数量多的原因在前，数量相同时按代码排序
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		return groups[i].code < groups[j].code
+	})
+
+	// 只有一种详情时直接显示详情，否则显示原因名称
+	for _, group := range groups {
+		reason := types.ReasonName(group.code)
+		if len(group.messages) == 1 {
+			reason = group.messages[0]
+		}
+		buf.WriteString(fmt.Sprintf("   - %d个%s\n", group.count, reason))
 	}
 
 	// 添加空行，与后续输出拉开距离
@@ -215,48 +247,35 @@ func (tf *TableFormatter) FormatUnsuitableSummary(results []*types.DetectionResu
 	return buf.String()
 }
 
+// containsString 切片中是否包含字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // calculateRecommendationStars 计算推荐星级
+// 星级由评分换算，后面附上分数，批量报告按分数排序
 func (tf *TableFormatter) calculateRecommendationStars(result *types.DetectionResult) string {
 	// 如果早期退出，显示"无效"
 	if result.EarlyExit {
 		return text.FgRed.Sprint("无效")
 	}
 
-	stars := 0
-
-	// 1. TLS硬性条件检查 (TLS1.3 + X25519 + H2 + SNI匹配)
-	if result.TLS != nil && result.TLS.SupportsTLS13 &&
-		result.TLS.SupportsX25519 && result.TLS.SupportsHTTP2 &&
-		result.SNI != nil && result.SNI.SNIMatch {
-		stars++
-	}
-
-	// 2. 握手时间延迟小 (<= 10ms)
-	if result.TLS != nil && result.TLS.HandshakeTime > 0 {
-		handshakeMs := int(result.TLS.HandshakeTime.Milliseconds())
-		if handshakeMs <= 10 {
-			stars++
-		}
-	}
-
-	// 3. 没有CDN (不使用CDN更安全)
-	if result.CDN == nil || !result.CDN.IsCDN {
-		stars++
-	}
-
-
-	// 4. TLD加分 (.com 和 .net)
-	if strings.HasSuffix(result.Domain, ".com") || strings.HasSuffix(result.Domain, ".net") {
-		stars++
-	}
-
 	// 生成星级显示 - 只显示实际获得的星级
 	var starsText string
-	for i := 0; i < stars; i++ {
+	for i := 0; i < result.Stars; i++ {
 		starsText += text.FgYellow.Sprint("*")
 	}
 
-	return starsText
+	scoreText := fmt.Sprintf("%.0f", result.Score)
+	if starsText == "" {
+		return scoreText
+	}
+	return starsText + " " + scoreText
 }
 
 // isEarlyExit 判断是否早期退出（未完成所有检测）
@@ -0,0 +1,186 @@
+package rules
+
+import (
+	"RealityChecker/internal/types"
+)
+
+// environment 规则评估时可访问的数据
+type environment struct {
+	result *types.DetectionResult
+	config *types.Config
+}
+
+// fieldGetter 读取字段值，字段所在的检测结果不存在时返回 false
+type fieldGetter func(env *environment) (interface{}, bool)
+
+// fieldGetters 规则可引用的字段，名称与JSON输出保持一致
+var fieldGetters = map[string]fieldGetter{
+	"domain": func(env *environment) (interface{}, bool) {
+		return env.result.Domain, true
+	},
+	"status_code_category": func(env *environment) (interface{}, bool) {
+		return env.result.StatusCodeCategory, env.result.StatusCodeCategory != ""
+	},
+
+	"network.accessible":     networkField(func(r *types.NetworkResult) interface{} { return r.Accessible }),
+	"network.status_code":    networkField(func(r *types.NetworkResult) interface{} { return r.StatusCode }),
+	"network.final_domain":   networkField(func(r *types.NetworkResult) interface{} { return r.FinalDomain }),
+	"network.redirect_count": networkField(func(r *types.NetworkResult) interface{} { return r.RedirectCount }),
+	"network.redirect_scope": networkField(func(r *types.NetworkResult) interface{} { return r.RedirectScope }),
+	"network.naturalness.weak_camouflage": func(env *environment) (interface{}, bool) {
+		if env.result.Network == nil || env.result.Network.Naturalness == nil {
+			return nil, false
+		}
+		return env.result.Network.Naturalness.WeakCamouflage, true
+	},
+
+	"blocked.is_blocked": func(env *environment) (interface{}, bool) {
+		if env.result.Blocked == nil {
+			return nil, false
+		}
+		return env.result.Blocked.IsBlocked, true
+	},
+
+	"location.is_domestic": locationField(func(r *types.LocationResult) interface{} { return r.IsDomestic }),
+	"location.country":     locationField(func(r *types.LocationResult) interface{} { return r.Country }),
+	"location.asn":         locationField(func(r *types.LocationResult) interface{} { return r.ASN }),
+
+	"tls.supports_tls13":  tlsField(func(r *types.TLSResult) interface{} { return r.SupportsTLS13 }),
+	"tls.supports_x25519": tlsField(func(r *types.TLSResult) interface{} { return r.SupportsX25519 }),
+	"tls.supports_http2":  tlsField(func(r *types.TLSResult) interface{} { return r.SupportsHTTP2 }),
+	"tls.handshake_time":  tlsField(func(r *types.TLSResult) interface{} { return r.HandshakeTime }),
+	"tls.handshake_ms":    tlsField(func(r *types.TLSResult) interface{} { return r.HandshakeTime.Milliseconds() }),
+
+	"certificate.valid":             certificateField(func(r *types.CertificateResult) interface{} { return r.Valid }),
+	"certificate.days_until_expiry": certificateField(func(r *types.CertificateResult) interface{} { return r.DaysUntilExpiry }),
+	"certificate.varies":            certificateField(func(r *types.CertificateResult) interface{} { return r.Varies }),
+
+	"sni.supports_sni": sniField(func(r *types.SNIResult) interface{} { return r.SupportsSNI }),
+	"sni.sni_match":    sniField(func(r *types.SNIResult) interface{} { return r.SNIMatch }),
+
+	"cdn.is_cdn":         cdnField(func(r *types.CDNResult) interface{} { return r.IsCDN }),
+	"cdn.cdn_provider":   cdnField(func(r *types.CDNResult) interface{} { return r.CDNProvider }),
+	"cdn.is_hot_website": cdnField(func(r *types.CDNResult) interface{} { return r.IsHotWebsite }),
+
+	"page_status.page_type": func(env *environment) (interface{}, bool) {
+		if env.result.PageStatus == nil {
+			return nil, false
+		}
+		return env.result.PageStatus.PageType, true
+	},
+	"page_status.page_type_name": func(env *environment) (interface{}, bool) {
+		if env.result.PageStatus == nil {
+			return nil, false
+		}
+		return types.PageTypeName(env.result.PageStatus.PageType), true
+	},
+
+	"proxy_front.is_likely_proxy": proxyFrontField(func(r *types.ProxyFrontResult) interface{} { return r.IsLikelyProxy }),
+	"proxy_front.target_ip":       proxyFrontField(func(r *types.ProxyFrontResult) interface{} { return r.TargetIP }),
+
+	// 按配置顺序取第一个判定为不适合的插件，没有时为空字符串
+	"plugins.failed": func(env *environment) (interface{}, bool) {
+		if len(env.result.Plugins) == 0 {
+			return nil, false
+		}
+		name, _ := failedPlugin(env)
+		return name, true
+	},
+	"plugins.failed_reason": func(env *environment) (interface{}, bool) {
+		if len(env.result.Plugins) == 0 {
+			return nil, false
+		}
+		_, reason := failedPlugin(env)
+		return reason, true
+	},
+
+	"config.detection.disqualify_cross_site_redirect": func(env *environment) (interface{}, bool) {
+		return env.config != nil && env.config.Detection.DisqualifyCrossSiteRedirect, true
+	},
+	"config.detection.allow_proxy_front": func(env *environment) (interface{}, bool) {
+		return env.config != nil && env.config.Detection.AllowProxyFront, true
+	},
+}
+
+// failedPlugin 按配置顺序查找判定为不适合的插件
+func failedPlugin(env *environment) (string, string) {
+	if env.config == nil {
+		return "", ""
+	}
+	for _, plugin := range env.config.Plugins {
+		if result := env.result.Plugins[plugin.Name]; result != nil && result.Verdict == types.PluginVerdictFail {
+			return plugin.Name, result.Reason
+		}
+	}
+	return "", ""
+}
+
+// networkField 读取网络检测结果中的字段
+func networkField(get func(*types.NetworkResult) interface{}) fieldGetter {
+	return func(env *environment) (interface{}, bool) {
+		if env.result.Network == nil {
+			return nil, false
+		}
+		return get(env.result.Network), true
+	}
+}
+
+// locationField 读取地理位置检测结果中的字段
+func locationField(get func(*types.LocationResult) interface{}) fieldGetter {
+	return func(env *environment) (interface{}, bool) {
+		if env.result.Location == nil {
+			return nil, false
+		}
+		return get(env.result.Location), true
+	}
+}
+
+// tlsField 读取TLS检测结果中的字段
+func tlsField(get func(*types.TLSResult) interface{}) fieldGetter {
+	return func(env *environment) (interface{}, bool) {
+		if env.result.TLS == nil {
+			return nil, false
+		}
+		return get(env.result.TLS), true
+	}
+}
+
+// certificateField 读取证书检测结果中的字段
+func certificateField(get func(*types.CertificateResult) interface{}) fieldGetter {
+	return func(env *environment) (interface{}, bool) {
+		if env.result.Certificate == nil {
+			return nil, false
+		}
+		return get(env.result.Certificate), true
+	}
+}
+
+// sniField 读取SNI检测结果中的字段
+func sniField(get func(*types.SNIResult) interface{}) fieldGetter {
+	return func(env *environment) (interface{}, bool) {
+		if env.result.SNI == nil {
+			return nil, false
+		}
+		return get(env.result.SNI), true
+	}
+}
+
+// cdnField 读取CDN检测结果中的字段
+func cdnField(get func(*types.CDNResult) interface{}) fieldGetter {
+	return func(env *environment) (interface{}, bool) {
+		if env.result.CDN == nil {
+			return nil, false
+		}
+		return get(env.result.CDN), true
+	}
+}
+
+// proxyFrontField 读取代理前置检测结果中的字段
+func proxyFrontField(get func(*types.ProxyFrontResult) interface{}) fieldGetter {
+	return func(env *environment) (interface{}, bool) {
+		if env.result.ProxyFront == nil {
+			return nil, false
+		}
+		return get(env.result.ProxyFront), true
+	}
+}
@@ -0,0 +1,505 @@
+package rules
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"RealityChecker/internal/types"
+)
+
+// defaultRulesYAML 内置规则，与引入规则引擎之前的判定逻辑一致
+//
+//go:embed default_rules.yaml
+var defaultRulesYAML []byte
+
+// 条件运算符
+const (
+	OpEq     = "eq"
+	OpNe     = "ne"
+	OpLt     = "lt"
+	OpLe     = "le"
+	OpGt     = "gt"
+	OpGe     = "ge"
+	OpIn     = "in"
+	OpNotIn  = "not_in"
+	OpSuffix = "suffix"
+)
+
+// 字段缺失时的处理方式
+const (
+	MissingSkip = "skip" // 规则不生效
+	MissingPass = "pass" // 视为满足条件
+	MissingFail = "fail" // 视为不满足条件
+)
+
+// placeholderPattern 原因模板中的 {字段} 占位符
+var placeholderPattern = regexp.MustCompile(`\{([a-z0-9_.]+)\}`)
+
+// Engine 适合性与评分规则引擎
+type Engine struct {
+	rules       []*rule
+	totalPoints float64 // 所有评分规则的权重之和
+}
+
+// rule 编译后的规则
+type rule struct {
+	name       string
+	code       string
+	when       []*condition
+	conditions []*condition
+	required   bool
+	points     float64
+	scale      *scale
+	message    string
+}
+
+// scale 编译后的线性给分区间
+type scale struct {
+	field string
+	get   fieldGetter
+	best  float64
+	worst float64
+}
+
+// condition 编译后的条件
+type condition struct {
+	field   string
+	get     fieldGetter
+	op      string
+	values  []interface{}
+	missing string
+}
+
+// Evaluation 规则评估结果
+type Evaluation struct {
+	Suitable  bool                // 所有硬性规则都通过
+	Reason    types.Reason        // 第一个不通过的硬性规则的原因
+	Failed    []types.Reason      // 所有不通过的硬性规则的原因
+	Score     float64             // 0-100分
+	Stars     int                 // 得分的评分规则数量
+	Breakdown []types.ScoreFactor // 各评分项的得分
+}
+
+// DefaultRules 内置规则
+func DefaultRules() ([]types.RuleConfig, error) {
+	var configs []types.RuleConfig
+	if err := yaml.Unmarshal(defaultRulesYAML, &configs); err != nil {
+		return nil, fmt.Errorf("内置规则解析失败: %v", err)
+	}
+	return configs, nil
+}
+
+// New 编译规则，未配置规则时使用内置规则
+func New(configs []types.RuleConfig) (*Engine, error) {
+	if len(configs) == 0 {
+		defaults, err := DefaultRules()
+		if err != nil {
+			return nil, err
+		}
+		configs = defaults
+	}
+
+	engine := &Engine{}
+	for i, config := range configs {
+		name := config.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		compiled, err := compileRule(name, config)
+		if err != nil {
+			return nil, fmt.Errorf("规则 %s 无效: %v", name, err)
+		}
+		engine.rules = append(engine.rules, compiled)
+		engine.totalPoints += compiled.points
+	}
+	return engine, nil
+}
+
+// compileRule 校验并编译单条规则
+func compileRule(name string, config types.RuleConfig) (*rule, error) {
+	if len(config.Conditions) == 0 && config.Scale == nil {
+		return nil, fmt.Errorf("缺少条件")
+	}
+	if config.Points < 0 {
+		return nil, fmt.Errorf("评分权重不能为负数")
+	}
+	if !config.Required && config.Points == 0 {
+		return nil, fmt.Errorf("既不是硬性条件也没有评分权重")
+	}
+	if config.Scale != nil && config.Points == 0 {
+		return nil, fmt.Errorf("线性给分需要评分权重")
+	}
+
+	compiled := &rule{
+		name:     name,
+		code:     config.Code,
+		required: config.Required,
+		points:   config.Points,
+		message:  config.Message,
+	}
+	if compiled.code == "" {
+		compiled.code = name
+	}
+	if compiled.required && compiled.message == "" {
+		compiled.message = "不满足规则 " + name
+	}
+
+	for _, placeholder := range placeholderPattern.FindAllStringSubmatch(compiled.message, -1) {
+		if _, exists := fieldGetters[placeholder[1]]; !exists {
+			return nil, fmt.Errorf("原因中引用了未知字段 %s", placeholder[1])
+		}
+	}
+
+	for _, c := range config.When {
+		cond, err := compileCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		compiled.when = append(compiled.when, cond)
+	}
+	for _, c := range config.Conditions {
+		cond, err := compileCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		compiled.conditions = append(compiled.conditions, cond)
+	}
+
+	if config.Scale != nil {
+		s, err := compileScale(*config.Scale)
+		if err != nil {
+			return nil, err
+		}
+		compiled.scale = s
+	}
+	return compiled, nil
+}
+
+// compileScale 校验并编译线性给分区间
+func compileScale(config types.ScaleConfig) (*scale, error) {
+	get, exists := fieldGetters[config.Field]
+	if !exists {
+		return nil, fmt.Errorf("未知字段 %s", config.Field)
+	}
+	best, ok := toNumber(normalizeValue(config.Best))
+	if !ok {
+		return nil, fmt.Errorf("给分区间需要数值或时长，得到 %v", config.Best)
+	}
+	worst, ok := toNumber(normalizeValue(config.Worst))
+	if !ok {
+		return nil, fmt.Errorf("给分区间需要数值或时长，得到 %v", config.Worst)
+	}
+	if best == worst {
+		return nil, fmt.Errorf("给分区间的 best 和 worst 不能相同")
+	}
+	return &scale{field: config.Field, get: get, best: best, worst: worst}, nil
+}
+
+// compileCondition 校验并编译单个条件
+func compileCondition(config types.ConditionConfig) (*condition, error) {
+	get, exists := fieldGetters[config.Field]
+	if !exists {
+		return nil, fmt.Errorf("未知字段 %s", config.Field)
+	}
+
+	cond := &condition{
+		field:   config.Field,
+		get:     get,
+		op:      config.Op,
+		missing: config.Missing,
+	}
+	if cond.missing == "" {
+		cond.missing = MissingSkip
+	}
+	switch cond.missing {
+	case MissingSkip, MissingPass, MissingFail:
+	default:
+		return nil, fmt.Errorf("字段 %s 的缺失处理方式 %s 无效", config.Field, config.Missing)
+	}
+
+	// 列表值只用于 in/not_in/suffix；规范化后的值写入副本，不修改配置中的列表
+	values := []interface{}{config.Value}
+	if list, ok := config.Value.([]interface{}); ok {
+		values = list
+	}
+	cond.values = make([]interface{}, len(values))
+	for i, value := range values {
+		cond.values[i] = normalizeValue(value)
+	}
+
+	switch cond.op {
+	case OpEq, OpNe:
+		if len(cond.values) != 1 {
+			return nil, fmt.Errorf("运算符 %s 只能比较单个值", cond.op)
+		}
+	case OpLt, OpLe, OpGt, OpGe:
+		if len(cond.values) != 1 {
+			return nil, fmt.Errorf("运算符 %s 只能比较单个值", cond.op)
+		}
+		if _, ok := toNumber(cond.values[0]); !ok {
+			return nil, fmt.Errorf("运算符 %s 需要数值或时长，得到 %v", cond.op, config.Value)
+		}
+	case OpIn, OpNotIn:
+	case OpSuffix:
+		for _, value := range cond.values {
+			if _, ok := value.(string); !ok {
+				return nil, fmt.Errorf("运算符 %s 需要字符串，得到 %v", cond.op, value)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("未知运算符 %s", config.Op)
+	}
+
+	return cond, nil
+}
+
+// Evaluate 评估检测结果
+// 硬性规则按顺序检查，第一个不通过的规则决定原因，其余不通过的规则也会记录；评分与适合性无关，总是计算
+func (e *Engine) Evaluate(result *types.DetectionResult, config *types.Config) *Evaluation {
+	env := &environment{result: result, config: config}
+	evaluation := &Evaluation{Suitable: true}
+
+	earned := 0.0
+	for _, r := range e.rules {
+		passed, applicable := r.evaluate(env)
+
+		if r.points > 0 {
+			factor := r.score(env, passed && applicable, e.totalPoints)
+			earned += factor.Contribution
+			evaluation.Breakdown = append(evaluation.Breakdown, factor)
+			if factor.Contribution > 0 {
+				evaluation.Stars++
+			}
+		}
+
+		if r.required && applicable && !passed {
+			reason := types.Reason{Code: r.code, Message: r.render(env)}
+			evaluation.Failed = append(evaluation.Failed, reason)
+			if evaluation.Suitable {
+				evaluation.Suitable = false
+				evaluation.Reason = reason
+			}
+		}
+	}
+
+	evaluation.Score = roundScore(earned)
+	return evaluation
+}
+
+// score 计算评分项的得分，权重按总权重折算为100分制
+func (r *rule) score(env *environment, passed bool, totalPoints float64) types.ScoreFactor {
+	factor := types.ScoreFactor{
+		Name:        r.name,
+		Description: r.render(env),
+		Value:       r.describeValue(env),
+		MaxScore:    roundScore(r.points * 100 / totalPoints),
+	}
+	if !passed {
+		return factor
+	}
+
+	ratio := 1.0
+	if r.scale != nil {
+		value, ok := r.scale.get(env)
+		if !ok {
+			return factor
+		}
+		number, ok := toNumber(value)
+		if !ok {
+			return factor
+		}
+		ratio = r.scale.ratio(number)
+	}
+
+	factor.Contribution = roundScore(r.points * 100 / totalPoints * ratio)
+	return factor
+}
+
+// describeValue 评分依据的字段值，多个字段时逐个列出
+func (r *rule) describeValue(env *environment) string {
+	fields := make([]string, 0, len(r.conditions)+1)
+	if r.scale != nil {
+		fields = append(fields, r.scale.field)
+	}
+	for _, cond := range r.conditions {
+		if r.scale != nil && cond.field == r.scale.field {
+			continue
+		}
+		fields = append(fields, cond.field)
+	}
+
+	values := make([]string, 0, len(fields))
+	for _, field := range fields {
+		text := "-"
+		if value, ok := fieldGetters[field](env); ok {
+			text = formatValue(value)
+		}
+		if len(fields) == 1 {
+			return text
+		}
+		values = append(values, field+"="+text)
+	}
+	return strings.Join(values, ", ")
+}
+
+// ratio 按线性区间计算得分比例
+func (s *scale) ratio(value float64) float64 {
+	ratio := (s.worst - value) / (s.worst - s.best)
+	return math.Max(0, math.Min(1, ratio))
+}
+
+// roundScore 分数保留一位小数
+func roundScore(score float64) float64 {
+	return math.Round(score*10) / 10
+}
+
+// evaluate 评估规则，返回是否通过以及规则是否生效
+func (r *rule) evaluate(env *environment) (bool, bool) {
+	for _, cond := range r.when {
+		matched, applicable := cond.evaluate(env)
+		if !applicable || !matched {
+			return false, false
+		}
+	}
+
+	passed := true
+	for _, cond := range r.conditions {
+		matched, applicable := cond.evaluate(env)
+		if !applicable {
+			return false, false
+		}
+		if !matched {
+			passed = false
+		}
+	}
+	return passed, true
+}
+
+// render 生成不适合的原因，占位符替换为字段值
+func (r *rule) render(env *environment) string {
+	return placeholderPattern.ReplaceAllStringFunc(r.message, func(placeholder string) string {
+		value, ok := fieldGetters[placeholder[1:len(placeholder)-1]](env)
+		if !ok {
+			return ""
+		}
+		return formatValue(value)
+	})
+}
+
+// evaluate 评估条件，返回是否满足以及条件是否生效
+func (c *condition) evaluate(env *environment) (bool, bool) {
+	value, ok := c.get(env)
+	if !ok {
+		switch c.missing {
+		case MissingPass:
+			return true, true
+		case MissingFail:
+			return false, true
+		}
+		return false, false
+	}
+
+	switch c.op {
+	case OpEq:
+		return equal(value, c.values[0]), true
+	case OpNe:
+		return !equal(value, c.values[0]), true
+	case OpLt, OpLe, OpGt, OpGe:
+		return compare(c.op, value, c.values[0]), true
+	case OpIn, OpNotIn:
+		found := false
+		for _, candidate := range c.values {
+			if equal(value, candidate) {
+				found = true
+				break
+			}
+		}
+		return found == (c.op == OpIn), true
+	case OpSuffix:
+		text := strings.ToLower(fmt.Sprint(value))
+		for _, candidate := range c.values {
+			if strings.HasSuffix(text, strings.ToLower(candidate.(string))) {
+				return true, true
+			}
+		}
+		return false, true
+	}
+	return false, true
+}
+
+// formatValue 格式化字段值，时长保留到0.1毫秒
+func formatValue(value interface{}) string {
+	if duration, ok := value.(time.Duration); ok {
+		return duration.Round(100 * time.Microsecond).String()
+	}
+	return fmt.Sprint(value)
+}
+
+// normalizeValue 将配置中的时长字符串（如 10ms）转换为时长
+func normalizeValue(value interface{}) interface{} {
+	if text, ok := value.(string); ok {
+		if duration, err := time.ParseDuration(text); err == nil {
+			return duration
+		}
+	}
+	return value
+}
+
+// toNumber 将数值和时长统一为浮点数
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case time.Duration:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// equal 比较两个值，数值按大小比较，其他按文本比较
+func equal(a, b interface{}) bool {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	if b == nil {
+		return a == nil || fmt.Sprint(a) == ""
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// compare 按运算符比较数值
+func compare(op string, a, b interface{}) bool {
+	x, ok := toNumber(a)
+	if !ok {
+		return false
+	}
+	y, ok := toNumber(b)
+	if !ok {
+		return false
+	}
+
+	switch op {
+	case OpLt:
+		return x < y
+	case OpLe:
+		return x <= y
+	case OpGt:
+		return x > y
+	case OpGe:
+		return x >= y
+	}
+	return false
+}
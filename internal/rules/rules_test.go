@@ -0,0 +1,354 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"RealityChecker/internal/types"
+)
+
+// newTestEngine 编译测试规则
+func newTestEngine(t *testing.T, configs ...types.RuleConfig) *Engine {
+	t.Helper()
+	engine, err := New(configs)
+	if err != nil {
+		t.Fatalf("编译规则失败: %v", err)
+	}
+	return engine
+}
+
+// cond 构造条件
+func cond(field, op string, value interface{}) types.ConditionConfig {
+	return types.ConditionConfig{Field: field, Op: op, Value: value}
+}
+
+// suitableResult 满足所有内置规则的检测结果
+func suitableResult() *types.DetectionResult {
+	return &types.DetectionResult{
+		Domain:      "example.com",
+		Blocked:     &types.BlockedResult{IsBlocked: false},
+		Location:    &types.LocationResult{IsDomestic: false},
+		Network:     &types.NetworkResult{Accessible: true, StatusCode: 200},
+		TLS:         &types.TLSResult{SupportsTLS13: true, SupportsX25519: true, SupportsHTTP2: true, HandshakeTime: 5 * time.Millisecond},
+		Certificate: &types.CertificateResult{Valid: true, DaysUntilExpiry: 60},
+		SNI:         &types.SNIResult{SupportsSNI: true, SNIMatch: true},
+		CDN:         &types.CDNResult{IsCDN: false},
+	}
+}
+
+func TestRequiredRules(t *testing.T) {
+	engine := newTestEngine(t,
+		types.RuleConfig{Name: "tls13", Code: "no_tls13", Required: true, Message: "不支持TLS 1.3",
+			Conditions: []types.ConditionConfig{cond("tls.supports_tls13", OpEq, true)}},
+		types.RuleConfig{Name: "http2", Required: true,
+			Conditions: []types.ConditionConfig{cond("tls.supports_http2", OpEq, true)}},
+		types.RuleConfig{Name: "status", Code: "bad_status", Required: true, Message: "状态码 {network.status_code}",
+			Conditions: []types.ConditionConfig{cond("network.status_code", OpIn, []interface{}{200, 301})}},
+	)
+
+	tests := []struct {
+		name       string
+		mutate     func(*types.DetectionResult)
+		wantCode   string // 空表示适合
+		wantReason string
+		wantFailed []string
+	}{
+		{"全部通过", func(*types.DetectionResult) {}, "", "", nil},
+		{"第一条不通过的规则决定原因", func(r *types.DetectionResult) {
+			r.TLS.SupportsTLS13 = false
+			r.TLS.SupportsHTTP2 = false
+		}, "no_tls13", "不支持TLS 1.3", []string{"no_tls13", "http2"}},
+		{"原因代码默认为规则名，原因有默认文本", func(r *types.DetectionResult) {
+			r.TLS.SupportsHTTP2 = false
+		}, "http2", "不满足规则 http2", []string{"http2"}},
+		{"原因中的占位符替换为字段值", func(r *types.DetectionResult) {
+			r.Network.StatusCode = 404
+		}, "bad_status", "状态码 404", []string{"bad_status"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := suitableResult()
+			tt.mutate(result)
+			evaluation := engine.Evaluate(result, &types.Config{})
+
+			if evaluation.Suitable != (tt.wantCode == "") {
+				t.Fatalf("Suitable = %v，原因 %+v", evaluation.Suitable, evaluation.Reason)
+			}
+			if evaluation.Reason.Code != tt.wantCode || evaluation.Reason.Message != tt.wantReason {
+				t.Errorf("原因 = %s %q，应为 %s %q", evaluation.Reason.Code, evaluation.Reason.Message, tt.wantCode, tt.wantReason)
+			}
+			var failed []string
+			for _, reason := range evaluation.Failed {
+				failed = append(failed, reason.Code)
+			}
+			if strings.Join(failed, ",") != strings.Join(tt.wantFailed, ",") {
+				t.Errorf("不通过的规则 = %v，应为 %v", failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestWhenCondition(t *testing.T) {
+	engine := newTestEngine(t, types.RuleConfig{
+		Name:       "no_cross_site_redirect",
+		Required:   true,
+		When:       []types.ConditionConfig{cond("config.detection.disqualify_cross_site_redirect", OpEq, true)},
+		Conditions: []types.ConditionConfig{cond("network.redirect_scope", OpNe, "cross_site")},
+	})
+
+	result := suitableResult()
+	result.Network.RedirectScope = "cross_site"
+
+	if !engine.Evaluate(result, &types.Config{}).Suitable {
+		t.Error("前提不满足时规则不生效")
+	}
+	config := &types.Config{}
+	config.Detection.DisqualifyCrossSiteRedirect = true
+	if engine.Evaluate(result, config).Suitable {
+		t.Error("前提满足时规则生效")
+	}
+}
+
+func TestMissingPolicies(t *testing.T) {
+	tests := []struct {
+		missing      string
+		wantSuitable bool    // 作为硬性规则
+		wantScore    float64 // 作为评分规则
+	}{
+		{"", true, 0},
+		{MissingSkip, true, 0},
+		{MissingPass, true, 100},
+		{MissingFail, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run("missing="+tt.missing, func(t *testing.T) {
+			condition := cond("cdn.is_cdn", OpEq, false)
+			condition.Missing = tt.missing
+
+			result := suitableResult()
+			result.CDN = nil
+
+			required := newTestEngine(t, types.RuleConfig{Name: "no_cdn", Required: true, Conditions: []types.ConditionConfig{condition}})
+			if got := required.Evaluate(result, &types.Config{}).Suitable; got != tt.wantSuitable {
+				t.Errorf("硬性规则 Suitable = %v，应为 %v", got, tt.wantSuitable)
+			}
+
+			scored := newTestEngine(t, types.RuleConfig{Name: "no_cdn", Points: 10, Conditions: []types.ConditionConfig{condition}})
+			evaluation := scored.Evaluate(result, &types.Config{})
+			if evaluation.Score != tt.wantScore {
+				t.Errorf("评分规则得分 = %v，应为 %v", evaluation.Score, tt.wantScore)
+			}
+			if evaluation.Breakdown[0].Value != "-" {
+				t.Errorf("缺失字段的取值应显示为 -，得到 %q", evaluation.Breakdown[0].Value)
+			}
+		})
+	}
+}
+
+func TestPointsAndStars(t *testing.T) {
+	engine := newTestEngine(t,
+		types.RuleConfig{Name: "tls13", Points: 30, Conditions: []types.ConditionConfig{cond("tls.supports_tls13", OpEq, true)}},
+		types.RuleConfig{Name: "tld", Points: 10, Conditions: []types.ConditionConfig{cond("domain", OpSuffix, []interface{}{".com", ".net"})}},
+		types.RuleConfig{Name: "latency", Points: 40, Conditions: []types.ConditionConfig{cond("tls.handshake_time", OpGt, "0s")},
+			Scale: &types.ScaleConfig{Field: "tls.handshake_time", Best: "10ms", Worst: "110ms"}},
+		types.RuleConfig{Name: "valid", Required: true, Conditions: []types.ConditionConfig{cond("certificate.valid", OpEq, true)}},
+	)
+
+	tests := []struct {
+		name      string
+		mutate    func(*types.DetectionResult)
+		wantScore float64
+		wantStars int
+		wantParts []float64 // 各评分项得分
+	}{
+		{"全部满分", func(*types.DetectionResult) {}, 100, 3, []float64{37.5, 12.5, 50}},
+		{"线性给分", func(r *types.DetectionResult) { r.TLS.HandshakeTime = 60 * time.Millisecond }, 75, 3, []float64{37.5, 12.5, 25}},
+		{"超出区间得0分不计星", func(r *types.DetectionResult) { r.TLS.HandshakeTime = time.Second }, 50, 2, []float64{37.5, 12.5, 0}},
+		{"后缀不区分大小写", func(r *types.DetectionResult) { r.Domain = "EXAMPLE.NET" }, 100, 3, []float64{37.5, 12.5, 50}},
+		{"条件不满足", func(r *types.DetectionResult) {
+			r.Domain = "example.org"
+			r.TLS.SupportsTLS13 = false
+		}, 50, 1, []float64{0, 0, 50}},
+		{"评分与适合性无关", func(r *types.DetectionResult) { r.Certificate.Valid = false }, 100, 3, []float64{37.5, 12.5, 50}},
+		{"TLS结果缺失", func(r *types.DetectionResult) { r.TLS = nil }, 12.5, 1, []float64{0, 12.5, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := suitableResult()
+			tt.mutate(result)
+			evaluation := engine.Evaluate(result, &types.Config{})
+
+			if evaluation.Score != tt.wantScore || evaluation.Stars != tt.wantStars {
+				t.Errorf("得分 %v、星级 %d，应为 %v、%d", evaluation.Score, evaluation.Stars, tt.wantScore, tt.wantStars)
+			}
+			if len(evaluation.Breakdown) != len(tt.wantParts) {
+				t.Fatalf("评分项 %d 个，应为 %d 个", len(evaluation.Breakdown), len(tt.wantParts))
+			}
+			for i, factor := range evaluation.Breakdown {
+				if factor.Contribution != tt.wantParts[i] {
+					t.Errorf("%s 得分 %v，应为 %v", factor.Name, factor.Contribution, tt.wantParts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	valid := []types.ConditionConfig{cond("tls.supports_tls13", OpEq, true)}
+
+	tests := []struct {
+		name string
+		rule types.RuleConfig
+	}{
+		{"缺少条件", types.RuleConfig{Name: "r", Required: true}},
+		{"负权重", types.RuleConfig{Name: "r", Points: -1, Conditions: valid}},
+		{"既不是硬性条件也没有权重", types.RuleConfig{Name: "r", Conditions: valid}},
+		{"未知字段", types.RuleConfig{Name: "r", Required: true, Conditions: []types.ConditionConfig{cond("tls.unknown", OpEq, true)}}},
+		{"未知运算符", types.RuleConfig{Name: "r", Required: true, Conditions: []types.ConditionConfig{cond("tls.supports_tls13", "is", true)}}},
+		{"比较运算需要数值", types.RuleConfig{Name: "r", Required: true, Conditions: []types.ConditionConfig{cond("tls.handshake_ms", OpLt, "fast")}}},
+		{"eq 只能比较单个值", types.RuleConfig{Name: "r", Required: true, Conditions: []types.ConditionConfig{cond("domain", OpEq, []interface{}{"a", "b"})}}},
+		{"suffix 需要字符串", types.RuleConfig{Name: "r", Required: true, Conditions: []types.ConditionConfig{cond("domain", OpSuffix, []interface{}{1})}}},
+		{"无效的缺失处理", types.RuleConfig{Name: "r", Required: true, Conditions: []types.ConditionConfig{{Field: "domain", Op: OpEq, Value: "a", Missing: "ignore"}}}},
+		{"原因引用未知字段", types.RuleConfig{Name: "r", Required: true, Conditions: valid, Message: "{tls.unknown}"}},
+		{"线性给分没有权重", types.RuleConfig{Name: "r", Required: true, Conditions: valid, Scale: &types.ScaleConfig{Field: "tls.handshake_time", Best: "1ms", Worst: "2ms"}}},
+		{"给分区间相同", types.RuleConfig{Name: "r", Points: 1, Scale: &types.ScaleConfig{Field: "tls.handshake_time", Best: "1ms", Worst: "1ms"}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := New([]types.RuleConfig{tt.rule}); err == nil {
+			t.Errorf("%s: 应返回错误", tt.name)
+		}
+	}
+}
+
+// baselineVerdict 引入规则引擎之前的判定逻辑，返回是否适合、原因和推荐星级
+func baselineVerdict(result *types.DetectionResult) (bool, string, int) {
+	verdict := func() (bool, string) {
+		if result.Blocked != nil && result.Blocked.IsBlocked {
+			return false, "域名被墙"
+		}
+		if result.Location != nil && result.Location.IsDomestic {
+			return false, "国内网站"
+		}
+		if result.Network != nil && !result.Network.Accessible {
+			return false, "网络不可达"
+		}
+		if result.Network != nil && types.ClassifyStatusCode(result.Network.StatusCode, true) == types.StatusCodeCategoryExcluded {
+			return false, fmt.Sprintf("状态码不自然: %d", result.Network.StatusCode)
+		}
+		if result.TLS != nil {
+			if !result.TLS.SupportsTLS13 {
+				return false, "不支持TLS 1.3"
+			}
+			if !result.TLS.SupportsX25519 {
+				return false, "不支持X25519密钥交换"
+			}
+			if !result.TLS.SupportsHTTP2 {
+				return false, "不支持HTTP/2"
+			}
+		}
+		if result.Certificate != nil {
+			if !result.Certificate.Valid {
+				return false, "证书无效"
+			}
+			if result.Certificate.DaysUntilExpiry <= 0 {
+				return false, fmt.Sprintf("证书已过期（%d天）", result.Certificate.DaysUntilExpiry)
+			}
+		}
+		if result.SNI != nil && (!result.SNI.SupportsSNI || !result.SNI.SNIMatch) {
+			return false, "SNI不匹配"
+		}
+		return true, ""
+	}
+	suitable, reason := verdict()
+
+	stars := 0
+	if result.TLS != nil && result.TLS.SupportsTLS13 && result.TLS.SupportsX25519 && result.TLS.SupportsHTTP2 &&
+		result.SNI != nil && result.SNI.SNIMatch {
+		stars++
+	}
+	if result.TLS != nil && result.TLS.HandshakeTime > 0 && result.TLS.HandshakeTime.Milliseconds() <= 10 {
+		stars++
+	}
+	if result.CDN == nil || !result.CDN.IsCDN {
+		stars++
+	}
+	if strings.HasSuffix(result.Domain, ".com") || strings.HasSuffix(result.Domain, ".net") {
+		stars++
+	}
+	return suitable, reason, stars
+}
+
+func TestDefaultRulesMatchBaseline(t *testing.T) {
+	engine := newTestEngine(t)
+
+	tests := []struct {
+		name     string
+		mutate   func(*types.DetectionResult)
+		wantCode string // 内置规则的原因代码，空表示适合
+	}{
+		{"适合", func(*types.DetectionResult) {}, ""},
+		{"被墙", func(r *types.DetectionResult) { r.Blocked.IsBlocked = true }, types.ReasonBlocked},
+		{"国内网站", func(r *types.DetectionResult) { r.Location.IsDomestic = true }, types.ReasonDomestic},
+		{"网络不可达", func(r *types.DetectionResult) {
+			r.Network.Accessible = false
+			r.Network.StatusCode = 0
+		}, types.ReasonUnreachable},
+		{"状态码不自然", func(r *types.DetectionResult) { r.Network.StatusCode = 500 }, types.ReasonBadStatus},
+		{"重定向状态码同样不自然", func(r *types.DetectionResult) { r.Network.StatusCode = 301 }, types.ReasonBadStatus},
+		{"不支持TLS1.3", func(r *types.DetectionResult) { r.TLS.SupportsTLS13 = false }, types.ReasonNoTLS13},
+		{"不支持X25519", func(r *types.DetectionResult) { r.TLS.SupportsX25519 = false }, types.ReasonNoX25519},
+		{"不支持H2", func(r *types.DetectionResult) { r.TLS.SupportsHTTP2 = false }, types.ReasonNoH2},
+		{"证书无效", func(r *types.DetectionResult) { r.Certificate.Valid = false }, types.ReasonCertInvalid},
+		{"证书过期", func(r *types.DetectionResult) { r.Certificate.DaysUntilExpiry = -3 }, types.ReasonCertExpired},
+		{"证书当天过期", func(r *types.DetectionResult) { r.Certificate.DaysUntilExpiry = 0 }, types.ReasonCertExpired},
+		{"不支持SNI", func(r *types.DetectionResult) { r.SNI.SupportsSNI = false }, types.ReasonSNIMismatch},
+		{"SNI不匹配", func(r *types.DetectionResult) { r.SNI.SNIMatch = false }, types.ReasonSNIMismatch},
+		{"多个条件不通过时取第一个", func(r *types.DetectionResult) {
+			r.Location.IsDomestic = true
+			r.TLS.SupportsHTTP2 = false
+		}, types.ReasonDomestic},
+		{"使用CDN", func(r *types.DetectionResult) { r.CDN.IsCDN = true }, ""},
+		{"没有CDN结果", func(r *types.DetectionResult) { r.CDN = nil }, ""},
+		{"握手较慢", func(r *types.DetectionResult) { r.TLS.HandshakeTime = 80 * time.Millisecond }, ""},
+		{"握手恰好10ms", func(r *types.DetectionResult) { r.TLS.HandshakeTime = 10*time.Millisecond + 900*time.Microsecond }, ""},
+		{"其他顶级域名", func(r *types.DetectionResult) { r.Domain = "example.org" }, ""},
+		{"早期退出只有部分结果", func(r *types.DetectionResult) {
+			r.Blocked.IsBlocked = true
+			r.TLS, r.Certificate, r.SNI, r.CDN = nil, nil, nil, nil
+		}, types.ReasonBlocked},
+		{"TLS检测未完成", func(r *types.DetectionResult) {
+			r.TLS, r.SNI = nil, nil
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := suitableResult()
+			tt.mutate(result)
+			wantSuitable, wantReason, wantStars := baselineVerdict(result)
+
+			// 与流水线相同，评估前按最终状态码分类
+			result.StatusCodeCategory = types.ClassifyStatusCode(result.Network.StatusCode, result.Network.Accessible)
+			evaluation := engine.Evaluate(result, &types.Config{})
+
+			if evaluation.Suitable != wantSuitable || evaluation.Reason.Message != wantReason {
+				t.Errorf("判定 = %v %q，原有逻辑为 %v %q", evaluation.Suitable, evaluation.Reason.Message, wantSuitable, wantReason)
+			}
+			if evaluation.Reason.Code != tt.wantCode {
+				t.Errorf("原因代码 = %q，应为 %q", evaluation.Reason.Code, tt.wantCode)
+			}
+			if evaluation.Stars != wantStars {
+				t.Errorf("星级 = %d，原有逻辑为 %d", evaluation.Stars, wantStars)
+			}
+			if evaluation.Score != float64(wantStars)*25 {
+				t.Errorf("得分 = %v，应为每颗星25分，共 %v", evaluation.Score, float64(wantStars)*25)
+			}
+		})
+	}
+}
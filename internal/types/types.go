@@ -2,12 +2,34 @@ package types
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"sync"
 	"time"
 )
 
+// Target 检测目标
+// IP 为可选的扫描得到的地址（例如RealiTLScanner CSV中的IP列），为空时只按域名检测
+type Target struct {
+	Domain string `json:"domain"`
+	IP     string `json:"ip,omitempty"`
+}
+
+// DomainTargets 将域名列表转换为检测目标
+func DomainTargets(domains []string) []Target {
+	targets := make([]Target, len(domains))
+	for i, domain := range domains {
+		targets[i] = Target{Domain: domain}
+	}
+	return targets
+}
+
 // DetectionResult 检测结果
 type DetectionResult struct {
 	Domain              string        `json:"domain"`
+	TargetIP            string        `json:"target_ip,omitempty"`      // 扫描得到的目标IP
+	Egress              string        `json:"egress,omitempty"`         // 探测使用的出口，direct 或代理地址
+	SourceAddress       string        `json:"source_address,omitempty"` // 探测绑定的本机地址或网卡
 	Index               int           `json:"index"`
 	StartTime           time.Time     `json:"start_time"`
 	Duration            time.Duration `json:"duration"`
@@ -15,6 +37,9 @@ type DetectionResult struct {
 	Error               error         `json:"error,omitempty"`
 	HardRequirementsMet bool          `json:"hard_requirements_met"`
 	EarlyExit           bool          `json:"early_exit"`                     // 是否早期退出
+	Cached              bool          `json:"cached,omitempty"`               // 是否来自结果缓存
+	ReasonCode          string        `json:"reason_code,omitempty"`          // 不适合或检测失败的原因代码
+	FailedRequirements  []Reason      `json:"failed_requirements,omitempty"`  // 所有未通过的硬性条件
 	StatusCodeCategory  string        `json:"status_code_category,omitempty"` // 状态码分类
 
 	// 检测结果
@@ -26,7 +51,225 @@ type DetectionResult struct {
 	PageStatus  *PageStatusResult  `json:"page_status,omitempty"`
 	Blocked     *BlockedResult     `json:"blocked,omitempty"`
 	Location    *LocationResult    `json:"location,omitempty"`
+	ProxyFront  *ProxyFrontResult  `json:"proxy_front,omitempty"`
 	Summary     *DetectionSummary  `json:"summary,omitempty"`
+
+	AlternativeCandidate string `json:"alternative_candidate,omitempty"` // 跨站重定向的最终站点，可作为备选目标
+
+	ProbedTarget string `json:"probed_target,omitempty"` // 实际进行TLS探测的最终域名，相同时多个输入共用一次探测
+	SharedProbe  bool   `json:"shared_probe,omitempty"`  // 是否复用了其他输入的探测结果
+
+	Score          float64       `json:"score"`                     // 评分规则得出的0-100分
+	ScoreBreakdown []ScoreFactor `json:"score_breakdown,omitempty"` // 各评分项的取值和得分
+	Stars          int           `json:"stars"`                     // 由评分换算的推荐星级，仅用于显示
+
+	Stages  []StageReport            `json:"stages,omitempty"`  // 各检测阶段的执行记录
+	Plugins map[string]*PluginResult `json:"plugins,omitempty"` // 外部插件的检测结果，按插件名称索引
+}
+
+// Reason 不适合的原因
+type Reason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"` // 本地化的详细原因
+}
+
+// 原因代码常量
+const (
+	ReasonBlocked           = "blocked"             // 域名被墙
+	ReasonDomestic          = "domestic"            // 国内网站
+	ReasonUnreachable       = "unreachable"         // 网络不可达
+	ReasonBadStatus         = "bad_status"          // 状态码不自然
+	ReasonCrossSiteRedirect = "cross_site_redirect" // 跨站重定向
+	ReasonProxyFront        = "proxy_front"         // 疑似代理前置
+	ReasonUnnaturalPage     = "unnatural_page"      // 页面内容不自然
+	ReasonNoTLS13           = "no_tls13"            // 不支持TLS 1.3
+	ReasonNoX25519          = "no_x25519"           // 不支持X25519
+	ReasonNoH2              = "no_h2"               // 不支持HTTP/2
+	ReasonCertInvalid       = "cert_invalid"        // 证书无效
+	ReasonCertExpired       = "cert_expired"        // 证书已过期
+	ReasonSNIMismatch       = "sni_mismatch"        // SNI不匹配
+	ReasonPluginFail        = "plugin_fail"         // 插件判定不适合
+	ReasonTimeout           = "timeout"             // 检测超时
+	ReasonInterrupted       = "interrupted"         // 检测被中断
+	ReasonError             = "error"               // 检测出错
+)
+
+// reasonNames 原因代码的中文名称
+var reasonNames = map[string]string{
+	ReasonBlocked:           "域名被墙",
+	ReasonDomestic:          "国内网站",
+	ReasonUnreachable:       "网络不可达",
+	ReasonBadStatus:         "状态码不自然",
+	ReasonCrossSiteRedirect: "跨站重定向",
+	ReasonProxyFront:        "疑似代理前置",
+	ReasonUnnaturalPage:     "页面内容不自然",
+	ReasonNoTLS13:           "不支持TLS 1.3",
+	ReasonNoX25519:          "不支持X25519密钥交换",
+	ReasonNoH2:              "不支持HTTP/2",
+	ReasonCertInvalid:       "证书无效",
+	ReasonCertExpired:       "证书已过期",
+	ReasonSNIMismatch:       "SNI不匹配",
+	ReasonPluginFail:        "插件判定不适合",
+	ReasonTimeout:           "检测超时",
+	ReasonInterrupted:       "检测中断",
+	ReasonError:             "检测出错",
+}
+
+// ReasonName 原因代码的中文名称，自定义规则的代码原样返回
+func ReasonName(code string) string {
+	if name, exists := reasonNames[code]; exists {
+		return name
+	}
+	return code
+}
+
+// IsCheckFailure 原因代码是否表示检测本身没有完成（而不是检测出不适合）
+func IsCheckFailure(code string) bool {
+	switch code {
+	case ReasonTimeout, ReasonInterrupted, ReasonError:
+		return true
+	}
+	return false
+}
+
+// SetReason 设置不适合的原因
+func (r *DetectionResult) SetReason(code, message string) {
+	r.ReasonCode = code
+	r.Error = errors.New(message)
+}
+
+// ScoreFactor 评分项
+type ScoreFactor struct {
+	Name         string  `json:"name"`
+	Description  string  `json:"description,omitempty"`
+	Value        string  `json:"value"`        // 评分依据的原始字段值
+	Contribution float64 `json:"contribution"` // 对总分的贡献
+	MaxScore     float64 `json:"max_score"`    // 该项的满分
+}
+
+// PluginResult 外部插件检测结果
+type PluginResult struct {
+	Name     string                 `json:"name"`
+	Verdict  string                 `json:"verdict"`
+	Reason   string                 `json:"reason,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`   // 插件自定义的结果字段
+	Warnings []string               `json:"warnings,omitempty"` // 插件返回的提示信息
+}
+
+// 插件判定结果常量
+const (
+	PluginVerdictPass = "pass" // 通过，不影响适合性
+	PluginVerdictFail = "fail" // 不适合
+)
+
+// MarshalJSON 将错误序列化为字符串
+func (r DetectionResult) MarshalJSON() ([]byte, error) {
+	type detectionResult DetectionResult
+	return json.Marshal(struct {
+		detectionResult
+		Error string `json:"error,omitempty"`
+	}{
+		detectionResult: detectionResult(r),
+		Error:           errorString(r.Error),
+	})
+}
+
+// UnmarshalJSON 将字符串形式的错误还原
+func (r *DetectionResult) UnmarshalJSON(data []byte) error {
+	type detectionResult DetectionResult
+	aux := struct {
+		*detectionResult
+		Error string `json:"error,omitempty"`
+	}{
+		detectionResult: (*detectionResult)(r),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Error = errorValue(aux.Error)
+	return nil
+}
+
+// StageReport 检测阶段执行记录
+type StageReport struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Outcome  string        `json:"outcome"`
+	Error    string        `json:"error,omitempty"`
+	Evidence []string      `json:"evidence,omitempty"`
+	Retries  []RetryRecord `json:"retries,omitempty"` // 因临时性错误重试的网络操作
+}
+
+// RetryRecord 网络操作重试记录
+type RetryRecord struct {
+	Operation string        `json:"operation"`
+	Attempt   int           `json:"attempt"` // 失败的第几次尝试
+	Error     string        `json:"error"`
+	Backoff   time.Duration `json:"backoff"` // 下次尝试前的等待时间
+}
+
+// StageTrace 检测阶段执行过程中的记录，可被阶段内的多个协程并发写入
+type StageTrace struct {
+	mu      sync.Mutex
+	retries []RetryRecord
+}
+
+// RecordRetry 记录一次重试
+func (t *StageTrace) RecordRetry(record RetryRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retries = append(t.retries, record)
+}
+
+// Retries 获取重试记录
+func (t *StageTrace) Retries() []RetryRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RetryRecord(nil), t.retries...)
+}
+
+// 检测阶段执行结果常量
+const (
+	StageOutcomePassed   = "passed"    // 执行成功
+	StageOutcomeFailed   = "failed"    // 返回错误
+	StageOutcomeSkipped  = "skipped"   // 未执行（早期退出或依赖未完成）
+	StageOutcomeTimedOut = "timed_out" // 超时
+	StageOutcomePanicked = "panicked"  // 发生panic
+)
+
+// StageOutcomeName 检测阶段执行结果的显示名称
+func StageOutcomeName(outcome string) string {
+	switch outcome {
+	case StageOutcomePassed:
+		return "通过"
+	case StageOutcomeFailed:
+		return "失败"
+	case StageOutcomeSkipped:
+		return "跳过"
+	case StageOutcomeTimedOut:
+		return "超时"
+	case StageOutcomePanicked:
+		return "崩溃"
+	default:
+		return outcome
+	}
+}
+
+// errorString 错误信息，nil返回空字符串
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// errorValue 将字符串还原为错误，空字符串为nil
+func errorValue(message string) error {
+	if message == "" {
+		return nil
+	}
+	return errors.New(message)
 }
 
 // StatusCodeCategory 状态码分类常量
@@ -75,18 +318,55 @@ func IsStatusCodeExcluded(statusCode int) bool {
 
 // NetworkResult 网络检测结果
 type NetworkResult struct {
-	Accessible         bool              `json:"accessible"`
-	ResponseTime       time.Duration     `json:"response_time"`
-	StatusCode         int               `json:"status_code"`
-	FinalDomain        string            `json:"final_domain"`
-	RedirectChain      []string          `json:"redirect_chain"`
-	IsRedirected       bool              `json:"is_redirected"`
-	RedirectCount      int               `json:"redirect_count"`
-	URL                string            `json:"url"`
-	HandshakeTime      time.Duration     `json:"handshake_time"`
-	Headers            map[string]string `json:"headers,omitempty"`             // HTTP响应头
-	CertificateIssuer  string            `json:"certificate_issuer,omitempty"`  // 证书颁发者
-	CertificateSubject string            `json:"certificate_subject,omitempty"` // 证书主题
+	Accessible         bool               `json:"accessible"`
+	ResponseTime       time.Duration      `json:"response_time"`
+	StatusCode         int                `json:"status_code"`
+	FinalDomain        string             `json:"final_domain"`
+	RedirectChain      []string           `json:"redirect_chain"`
+	IsRedirected       bool               `json:"is_redirected"`
+	RedirectCount      int                `json:"redirect_count"` // 跳转到其他主机的次数，每一跳见Hops
+	URL                string             `json:"url"`
+	HandshakeTime      time.Duration      `json:"handshake_time"`
+	Headers            map[string]string  `json:"headers,omitempty"`             // HTTP响应头
+	CertificateIssuer  string             `json:"certificate_issuer,omitempty"`  // 证书颁发者
+	CertificateSubject string             `json:"certificate_subject,omitempty"` // 证书主题
+	BodySnippet        []byte             `json:"-"`                             // 最终页面响应体（有限长度），供内容分类使用
+	Naturalness        *NaturalnessResult `json:"naturalness,omitempty"`         // 站点自然度检测结果
+	Hops               []RedirectHop      `json:"hops,omitempty"`                // 每一跳的请求记录
+	FinalURL           string             `json:"final_url,omitempty"`           // 最终页面URL
+	RedirectScope      string             `json:"redirect_scope,omitempty"`      // 最终目标与原域名的关系
+}
+
+// RedirectHop 重定向链中的一跳
+type RedirectHop struct {
+	URL        string            `json:"url"`
+	StatusCode int               `json:"status_code"`
+	Location   string            `json:"location,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"` // 选取的关键响应头
+	Duration   time.Duration     `json:"duration"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// RedirectScope 重定向范围常量
+const (
+	RedirectScopeNone      = "none"       // 没有重定向
+	RedirectScopeSameHost  = "same_host"  // 同一主机（路径或协议跳转）
+	RedirectScopeSameSite  = "same_site"  // 同一可注册域名（如 a.example.com -> www.example.com）
+	RedirectScopeCrossSite = "cross_site" // 跨站跳转
+)
+
+// NaturalnessResult 站点自然度检测结果
+// 真实网站访问不存在的路径应返回404，访问80端口应跳转到HTTPS
+type NaturalnessResult struct {
+	RandomPathURL        string   `json:"random_path_url"`
+	RandomPathStatus     int      `json:"random_path_status"` // 0表示请求失败
+	RandomPathLocation   string   `json:"random_path_location,omitempty"`
+	RandomPathLikeRoot   bool     `json:"random_path_like_root"` // 随机路径与首页的响应相同或跳转到首页
+	HTTPStatus           int      `json:"http_status"`           // 0表示80端口不可达
+	HTTPLocation         string   `json:"http_location,omitempty"`
+	HTTPRedirectsToHTTPS bool     `json:"http_redirects_to_https"`
+	WeakCamouflage       bool     `json:"weak_camouflage"`
+	Issues               []string `json:"issues,omitempty"`
 }
 
 // TLSResult TLS检测结果
@@ -109,6 +389,19 @@ type CertificateResult struct {
 	NotBefore       time.Time `json:"not_before"`
 	NotAfter        time.Time `json:"not_after"`
 	Error           string    `json:"error,omitempty"`
+
+	Varies               bool                  `json:"varies"`                          // 多次握手返回了不同的叶子证书
+	ObservedCertificates []ObservedCertificate `json:"observed_certificates,omitempty"` // 多次握手观察到的不同证书
+}
+
+// ObservedCertificate 多次握手中观察到的一张叶子证书
+type ObservedCertificate struct {
+	SHA256  string   `json:"sha256"`
+	Issuer  string   `json:"issuer"`
+	KeyType string   `json:"key_type"`
+	SANs    []string `json:"sans"`
+	IPs     []string `json:"ips"`   // 返回该证书的地址
+	Count   int      `json:"count"` // 出现次数
 }
 
 // SNIResult SNI检测结果
@@ -128,19 +421,91 @@ type CDNResult struct {
 	Error        error  `json:"error,omitempty"`
 }
 
+// MarshalJSON 将错误序列化为字符串
+func (r CDNResult) MarshalJSON() ([]byte, error) {
+	type cdnResult CDNResult
+	return json.Marshal(struct {
+		cdnResult
+		Error string `json:"error,omitempty"`
+	}{
+		cdnResult: cdnResult(r),
+		Error:     errorString(r.Error),
+	})
+}
+
+// UnmarshalJSON 将字符串形式的错误还原
+func (r *CDNResult) UnmarshalJSON(data []byte) error {
+	type cdnResult CDNResult
+	aux := struct {
+		*cdnResult
+		Error string `json:"error,omitempty"`
+	}{
+		cdnResult: (*cdnResult)(r),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Error = errorValue(aux.Error)
+	return nil
+}
+
 // BlockedResult 被墙检测结果
 type BlockedResult struct {
-	IsBlocked      bool     `json:"is_blocked"`
-	BlockedReasons []string `json:"blocked_reasons"`
-	MatchType      string   `json:"match_type"`
+	IsBlocked      bool         `json:"is_blocked"`
+	BlockedReasons []string     `json:"blocked_reasons"`
+	MatchType      string       `json:"match_type"`
+	MatchedRule    *MatchedRule `json:"matched_rule,omitempty"` // 命中的GFW列表规则
+}
+
+// MatchedRule 命中的数据集规则及其来源
+type MatchedRule struct {
+	Type    string `json:"type"`    // exact、suffix、keyword、regex
+	Pattern string `json:"pattern"` // 规则的域名、关键字或正则表达式
+	File    string `json:"file"`
+	Line    int    `json:"line"`
 }
 
 // PageStatusResult 页面状态检测结果
 type PageStatusResult struct {
-	StatusCode   int    `json:"status_code"`
-	IsAccessible bool   `json:"is_accessible"`
-	ResponseTime int64  `json:"response_time_ms"`
-	Error        string `json:"error,omitempty"`
+	StatusCode       int    `json:"status_code"`
+	IsAccessible     bool   `json:"is_accessible"`
+	ResponseTime     int64  `json:"response_time_ms"`
+	PageType         string `json:"page_type"`                   // 页面类型
+	MatchedSignature string `json:"matched_signature,omitempty"` // 命中的页面特征
+	Error            string `json:"error,omitempty"`
+}
+
+// PageType 页面类型常量
+const (
+	PageTypeNormal        = "normal"        // 正常页面
+	PageTypeUnknown       = "unknown"       // 无法获取页面内容
+	PageTypeWAFChallenge  = "waf_challenge" // WAF/人机验证挑战页
+	PageTypeParked        = "parked"        // 停放/待售域名
+	PageTypeDefaultServer = "default_page"  // Web服务器默认页面
+)
+
+// IsPageTypeUnsuitable 判断页面类型是否不适合作为伪装目标
+func IsPageTypeUnsuitable(pageType string) bool {
+	switch pageType {
+	case PageTypeWAFChallenge, PageTypeParked, PageTypeDefaultServer:
+		return true
+	}
+	return false
+}
+
+// PageTypeName 页面类型的中文名称
+func PageTypeName(pageType string) string {
+	switch pageType {
+	case PageTypeNormal:
+		return "正常页面"
+	case PageTypeWAFChallenge:
+		return "WAF挑战页"
+	case PageTypeParked:
+		return "停放域名"
+	case PageTypeDefaultServer:
+		return "默认页面"
+	}
+	return "未知"
 }
 
 // LocationResult 地理位置检测结果
@@ -154,6 +519,34 @@ type LocationResult struct {
 	Region     string `json:"region"`
 }
 
+// AddWarning 添加提示信息（不影响适合性判断）
+func (r *DetectionResult) AddWarning(warning string) {
+	if r.Summary == nil {
+		r.Summary = &DetectionSummary{}
+	}
+	r.Summary.Warnings = append(r.Summary.Warnings, warning)
+}
+
+// ProxyFrontResult 代理前置检测结果
+// 扫描到的IP若是他人的Reality/Trojan服务器，会转发或冒用大站的证书，不适合作为目标
+type ProxyFrontResult struct {
+	TargetIP          string   `json:"target_ip"`
+	DNSAddresses      []string `json:"dns_addresses"`
+	InDNS             bool     `json:"in_dns"`             // 目标IP是否在域名的DNS解析结果中
+	TargetCertSHA256  string   `json:"target_cert_sha256"` // 目标IP返回的叶子证书指纹
+	DNSCertSHA256     string   `json:"dns_cert_sha256"`    // DNS地址返回的叶子证书指纹
+	CertMatch         bool     `json:"cert_match"`         // 两者证书是否一致
+	TargetFingerprint string   `json:"target_fingerprint"` // 目标IP的TLS参数（版本/套件/ALPN）
+	DNSFingerprint    string   `json:"dns_fingerprint"`    // DNS地址的TLS参数
+	FingerprintMatch  bool     `json:"fingerprint_match"`  // TLS参数是否一致
+	TargetASN         string   `json:"target_asn,omitempty"`
+	DomainASNs        []string `json:"domain_asns,omitempty"`
+	ASNMismatch       bool     `json:"asn_mismatch"`    // 目标IP与域名真实地址不属于同一ASN
+	PrefixMismatch    bool     `json:"prefix_mismatch"` // 目标IP与域名真实地址不在同一网段（IPv4 /16、IPv6 /32）
+	IsLikelyProxy     bool     `json:"is_likely_proxy"`
+	Evidence          []string `json:"evidence,omitempty"`
+}
+
 // DetectionSummary 检测摘要
 type DetectionSummary struct {
 	TotalChecks     int      `json:"total_checks"`
@@ -176,6 +569,23 @@ type BatchReport struct {
 	TLSStats         *TLSStats          `json:"tls_stats"`
 	CertificateStats *CertificateStats  `json:"certificate_stats"`
 	Summary          *BatchSummary      `json:"summary"`
+	Interrupted      bool               `json:"interrupted,omitempty"`   // 检测被中断，报告只包含已完成的部分
+	SharedProbes     []ProbeShare       `json:"shared_probes,omitempty"` // 共用同一次TLS探测的输入
+}
+
+// ProbeShare 探测同一最终域名的多个输入
+type ProbeShare struct {
+	ProbedTarget string   `json:"probed_target"`
+	Inputs       []string `json:"inputs"` // 输入的域名，带扫描IP时附在括号中
+}
+
+// SourceComparison 多源地址对比报告
+// 同一批目标依次从每个源地址检测一遍，Reports 与 Sources 一一对应
+type SourceComparison struct {
+	Sources     []string       `json:"sources"`
+	Reports     []*BatchReport `json:"reports"`
+	Differences []Target       `json:"differences"` // 各源地址结论（适合与否、星级）不一致的目标
+	Interrupted bool           `json:"interrupted,omitempty"`
 }
 
 // Statistics 统计信息
@@ -186,6 +596,9 @@ type Statistics struct {
 	SuitableDomains  int `json:"suitable_domains"`
 	BlockedDomains   int `json:"blocked_domains"`
 	ErrorDomains     int `json:"error_domains"`
+	CachedResults    int `json:"cached_results"` // 来自结果缓存的数量
+
+	ReasonCounts map[string]int `json:"reason_counts,omitempty"` // 按原因代码统计的不适合数量
 }
 
 // PerformanceStats 性能统计
@@ -239,12 +652,69 @@ type BatchSummary struct {
 }
 
 // DetectionStage 检测阶段接口
+// 流水线根据 Requires/Produces 声明构建依赖图，互不依赖的阶段并发执行，
+// Priority 只用于同时就绪的阶段之间的启动顺序
 type DetectionStage interface {
-	Execute(ctx *PipelineContext) error
-	CanEarlyExit() bool
+	Execute(ctx *PipelineContext) (*PartialResult, error) // 返回本阶段的部分结果，由流水线统一合并
+	CanEarlyExit() bool                                   // 失败时是否可以终止整个流水线
 	Priority() int
 	Name() string
-}
+	Requires() []string // 依赖的结果字段
+	Produces() []string // 产出的结果字段
+}
+
+// PartialResult 检测阶段产出的部分结果
+// 检测阶段只读取 PipelineContext.Result 快照，不直接修改共享结果，由流水线按拓扑顺序合并
+type PartialResult struct {
+	Blocked              *BlockedResult
+	Network              *NetworkResult
+	StatusCodeCategory   string
+	Naturalness          *NaturalnessResult
+	IPAddress            string
+	Location             *LocationResult
+	TLS                  *TLSResult
+	SNI                  *SNIResult
+	Certificate          *CertificateResult
+	CDN                  *CDNResult // HTTP或证书CDN检测结果，先产出者优先
+	HotWebsite           *bool      // 热门网站检测结果，单独合并到CDN结果
+	PageStatus           *PageStatusResult
+	ProxyFront           *ProxyFrontResult
+	Plugin               *PluginResult
+	AlternativeCandidate string
+	ProbedTarget         string // 本阶段探测的最终域名
+	SharedProbe          bool   // 本阶段是否复用了其他输入的探测结果
+	Warnings             []string
+	Evidence             []string // 本阶段的判断依据，记录到检测阶段执行记录中
+}
+
+// AddWarning 添加一条提示信息
+func (p *PartialResult) AddWarning(warning string) {
+	p.Warnings = append(p.Warnings, warning)
+}
+
+// AddEvidence 添加一条判断依据
+func (p *PartialResult) AddEvidence(evidence string) {
+	p.Evidence = append(p.Evidence, evidence)
+}
+
+// 结果字段常量，用于声明检测阶段之间的依赖关系
+const (
+	FieldBlocked        = "blocked"         // 被墙检测结果
+	FieldNetwork        = "network"         // 重定向结果、最终域名、响应头
+	FieldHTTPCDN        = "cdn.http"        // 基于HTTP响应头的CDN检测
+	FieldStatusCategory = "status_category" // 状态码分类
+	FieldNaturalness    = "naturalness"     // 随机路径和80端口检测
+	FieldPageStatus     = "page_status"     // 页面内容分类
+	FieldResolvedIP     = "resolved_ip"     // 原始域名的解析地址
+	FieldLocation       = "location"        // 地理位置
+	FieldLocationCheck  = "location_check"  // 重定向后最终域名的地理位置检查
+	FieldTLS            = "tls"             // TLS、SNI、证书
+	FieldCertCDN        = "cdn.cert"        // 基于证书的CDN检测
+	FieldHotWebsite     = "cdn.hot"         // 热门网站标记
+	FieldProxyFront     = "proxy_front"     // 代理前置检测
+
+	FieldPluginPrefix = "plugin." // 外部插件产出的字段前缀，完整字段为 plugin.<插件名>
+)
 
 // PipelineContext 流水线上下文
 type PipelineContext struct {
@@ -254,9 +724,13 @@ type PipelineContext struct {
 	Connections interface{} // 使用interface{}来支持不同的连接管理器类型
 	Cache       interface{} // 使用interface{}来支持不同的缓存管理器类型
 	Config      *Config
+	TargetIP    string // 扫描得到的目标IP（可选）
 	EarlyExit   bool
 	Error       error
 	Context     context.Context // 添加Context字段
+	Probes      interface{}     // 多个目标共用的探测合并组，相同最终域名的探测只执行一次
+	Datasets    interface{}     // 本次检测使用的数据集快照，检测期间重新加载不影响进行中的检测
+	Trace       *StageTrace     // 当前阶段的执行记录，由流水线为每个阶段单独创建
 }
 
 // ConnectionManager 连接管理器
@@ -293,7 +767,7 @@ type DNSResolver struct {
 
 // DNSEntry DNS条目
 type DNSEntry struct {
-	IP        string
+	IPs       []string
 	Timestamp time.Time
 	TTL       time.Duration
 }
@@ -312,8 +786,8 @@ type ResultCache struct {
 
 // CachedResult 缓存结果
 type CachedResult struct {
-	Result    *DetectionResult
-	Timestamp time.Time
+	Result    *DetectionResult `json:"result"`
+	Timestamp time.Time        `json:"timestamp"`
 }
 
 // CDNCache CDN缓存
@@ -339,13 +813,95 @@ type Config struct {
 	Output      OutputConfig      `yaml:"output"`
 	Cache       CacheConfig       `yaml:"cache"`
 	Batch       BatchConfig       `yaml:"batch"`
+	Detection   DetectionConfig   `yaml:"detection"`
+	Plugins     []PluginConfig    `yaml:"plugins"`
+	Rules       []RuleConfig      `yaml:"rules"` // 适合性与评分规则，未配置时使用内置规则
+}
+
+// RuleConfig 适合性与评分规则
+// 条件全部满足时规则通过；硬性规则不通过时判定为不适合，评分规则通过时按权重计分
+type RuleConfig struct {
+	Name       string            `yaml:"name"`
+	Code       string            `yaml:"code"`       // 不通过时的原因代码，默认为规则名称
+	When       []ConditionConfig `yaml:"when"`       // 规则生效的前提，为空时总是生效
+	Conditions []ConditionConfig `yaml:"conditions"` // 规则通过需要满足的条件
+	Required   bool              `yaml:"required"`   // 硬性条件
+	Points     float64           `yaml:"points"`     // 评分规则的权重，总分按所有评分规则的权重之和折算为100分
+	Scale      *ScaleConfig      `yaml:"scale"`      // 按字段取值线性给分，未配置时条件通过即得满分
+	Message    string            `yaml:"message"`    // 硬性条件不通过的原因或评分项的说明，支持 {字段} 占位符
+}
+
+// ScaleConfig 评分项的线性给分区间
+type ScaleConfig struct {
+	Field string      `yaml:"field"`
+	Best  interface{} `yaml:"best"`  // 不差于该值时得满分
+	Worst interface{} `yaml:"worst"` // 不优于该值时得0分
+}
+
+// ConditionConfig 规则条件
+type ConditionConfig struct {
+	Field   string      `yaml:"field"`   // 结果字段，如 tls.supports_tls13
+	Op      string      `yaml:"op"`      // eq、ne、lt、le、gt、ge、in、not_in、suffix
+	Value   interface{} `yaml:"value"`   // 比较值，in/not_in/suffix 可以是列表
+	Missing string      `yaml:"missing"` // 字段缺失时的处理：skip（默认，规则不生效）、pass、fail
+}
+
+// PluginConfig 外部检测插件配置
+type PluginConfig struct {
+	Name     string        `yaml:"name"`
+	Command  string        `yaml:"command"`  // 可执行文件路径
+	Args     []string      `yaml:"args"`     // 命令行参数
+	Timeout  time.Duration `yaml:"timeout"`  // 单次执行超时
+	Requires []string      `yaml:"requires"` // 依赖的结果字段，默认为 network
 }
 
 // NetworkConfig 网络配置
 type NetworkConfig struct {
-	Timeout    time.Duration `yaml:"timeout"`
-	Retries    int           `yaml:"retries"`
-	DNSServers []string      `yaml:"dns_servers"`
+	Timeout       time.Duration `yaml:"timeout"`         // 单次网络操作（拨号、握手、DNS查询、HTTP请求）超时
+	Retries       int           `yaml:"retries"`         // 临时性错误的重试次数
+	RetryBackoff  time.Duration `yaml:"retry_backoff"`   // 首次重试前的等待时间，之后每次翻倍
+	DNSServers    []string      `yaml:"dns_servers"`     // DNS服务器，支持 host[:port] 和 DoH地址 https://...
+	UseDNSServers bool          `yaml:"use_dns_servers"` // 直连时也使用 DNSServers 解析；默认使用系统解析器，经由代理时总是使用 DNSServers
+	Proxy         ProxyConfig   `yaml:"proxy"`           // 上游代理，所有探测、DNS查询和数据下载经由代理
+
+	// 出站源地址，多IP主机上使探测从Reality入站监听的地址发出
+	SourceAddress   string   `yaml:"source_address"`   // 绑定的本机地址，优先于 Interface
+	Interface       string   `yaml:"interface"`        // 绑定的网卡，使用其上的地址
+	SourceAddresses []string `yaml:"source_addresses"` // 批量检测时依次从这些地址各检测一遍并对比结果
+
+	RateLimit RateLimitConfig `yaml:"rate_limit"` // 按目标IP和网段限制连接
+}
+
+// RateLimitConfig 按目标IP和网段的连接限制，数值为负时不限制
+type RateLimitConfig struct {
+	PerIPConcurrent     int     `yaml:"per_ip_concurrent"`     // 同一IP的最大并发连接数
+	PerIPRate           float64 `yaml:"per_ip_rate"`           // 同一IP每秒新建的连接数
+	PrefixLength        int     `yaml:"prefix_length"`         // IPv4网段前缀长度
+	PrefixLengthV6      int     `yaml:"prefix_length_v6"`      // IPv6网段前缀长度
+	PerPrefixConcurrent int     `yaml:"per_prefix_concurrent"` // 同一网段的最大并发连接数
+	PerPrefixRate       float64 `yaml:"per_prefix_rate"`       // 同一网段每秒新建的连接数
+}
+
+// 上游代理类型
+const (
+	ProxyTypeSOCKS5 = "socks5"
+	ProxyTypeHTTP   = "http" // HTTP CONNECT
+)
+
+// EgressDirect 未配置代理时的出口名称
+const EgressDirect = "direct"
+
+// ProxyConfig 上游代理配置
+type ProxyConfig struct {
+	Type     string `yaml:"type"`    // socks5 或 http，为空时直连
+	Address  string `yaml:"address"` // 代理地址 host:port
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Enabled 是否配置了代理
+func (p ProxyConfig) Enabled() bool {
+	return p.Type != ""
 }
 
 // ConcurrencyConfig 并发配置
@@ -369,6 +925,9 @@ type CacheConfig struct {
 	ResultEnabled bool          `yaml:"result_enabled"`
 	TTL           time.Duration `yaml:"ttl"`
 	MaxSize       int           `yaml:"max_size"`
+	ResultPath    string        `yaml:"result_path"` // 检测结果缓存文件
+
+	Refresh bool `yaml:"-"` // 忽略已缓存的结果重新检测（仍会写入缓存），仅由 --refresh-cache 设置
 }
 
 // BatchConfig 批量配置
@@ -377,13 +936,29 @@ type BatchConfig struct {
 	ProgressBar  bool          `yaml:"progress_bar"`
 	ReportFormat string        `yaml:"report_format"`
 	Timeout      time.Duration `yaml:"timeout"`
+	JournalDir   string        `yaml:"journal_dir"` // 进度日志目录，每次批量检测写入一个新文件
+	Resume       string        `yaml:"-"`           // 继续检测的进度日志路径，由 --resume 设置
+}
+
+// DetectionConfig 检测行为配置
+type DetectionConfig struct {
+	MaxRedirects                int  `yaml:"max_redirects"`
+	DisqualifyCrossSiteRedirect bool `yaml:"disqualify_cross_site_redirect"` // 跨站重定向是否判定为不适合
+	AllowProxyFront             bool `yaml:"allow_proxy_front"`              // 是否保留疑似代理前置的目标
+	CertHandshakes              int  `yaml:"cert_handshakes"`                // 证书一致性检查的握手次数，1表示不检查
+	FullDiagnosis               bool `yaml:"full_diagnosis"`                 // 完整诊断：不早期退出，执行所有检测阶段
+
+	StageTimeouts map[string]time.Duration `yaml:"stage_timeouts"` // 按阶段名称覆盖的阶段超时，未配置的阶段只受单域名超时约束
 }
 
 // ConnectionStats 连接统计
 type ConnectionStats struct {
-	ActiveConnections int `json:"active_connections"`
-	TotalConnections  int `json:"total_connections"`
-	FailedConnections int `json:"failed_connections"`
+	ActiveConnections int   `json:"active_connections"`
+	TotalConnections  int   `json:"total_connections"`
+	FailedConnections int   `json:"failed_connections"`
+	DNSHits           int64 `json:"dns_hits"`
+	DNSMisses         int64 `json:"dns_misses"`
+	RateLimited       int64 `json:"rate_limited"` // 因IP或网段限制而等待的连接数
 }
 
 // CacheStats 缓存统计
@@ -392,4 +967,6 @@ type CacheStats struct {
 	ResultCacheSize int     `json:"result_cache_size"`
 	CDNCacheSize    int     `json:"cdn_cache_size"`
 	HitRate         float64 `json:"hit_rate"`
+	ResultHits      int64   `json:"result_hits"`
+	ResultMisses    int64   `json:"result_misses"`
 }
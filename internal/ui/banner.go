@@ -2,12 +2,19 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 )
 
 // PrintBanner 打印程序横幅
 func PrintBanner() {
-	fmt.Println()
+	FprintBanner(os.Stdout)
+}
+
+// FprintBanner 将程序横幅写到指定位置
+func FprintBanner(w io.Writer) {
+	fmt.Fprintln(w)
 
 	// 获取版本信息
 	versionInfo := getVersionInfo()
@@ -36,8 +43,8 @@ func PrintBanner() {
 
 	if versionRightSpace < 0 { versionRightSpace = 0 }
 
-	fmt.Printf("%s╔%s╗%s\n", white, strings.Repeat("═", width-2), reset)
-	fmt.Printf("%s║%s%s%s║%s\n", white, strings.Repeat(" ", versionPadding), versionText, strings.Repeat(" ", versionRightSpace), reset)
-	fmt.Printf("%s║%s║%s\n", white, strings.Repeat(" ", width-2), reset)
-	fmt.Println("")
+	fmt.Fprintf(w, "%s╔%s╗%s\n", white, strings.Repeat("═", width-2), reset)
+	fmt.Fprintf(w, "%s║%s%s%s║%s\n", white, strings.Repeat(" ", versionPadding), versionText, strings.Repeat(" ", versionRightSpace), reset)
+	fmt.Fprintf(w, "%s║%s║%s\n", white, strings.Repeat(" ", width-2), reset)
+	fmt.Fprintln(w, "")
 }
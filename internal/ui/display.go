@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"time"
 
 	"RealityChecker/internal/version"
@@ -24,6 +25,14 @@ func PrintUsage() {
 	fmt.Println("  reality-checker batch <domain1> <domain2> <domain3> ...  批量检测域名")
 	fmt.Println("  reality-checker csv <csv_file>          从CSV文件批量检测域名")
 	fmt.Println("")
+	fmt.Println("选项:")
+	fmt.Println("  --json                                  以JSON格式输出结果")
+	fmt.Println("  --full                                  完整诊断：不早期退出，列出所有未通过的条件")
+	fmt.Println("  --no-cache                              不使用检测结果缓存")
+	fmt.Println("  --refresh-cache                         忽略已缓存的结果，重新检测并更新缓存")
+	fmt.Println("  --source=<ip>[,<ip>...]                 从指定本机地址发出探测，多个地址时批量检测逐个对比")
+	fmt.Println("  --resume <journal>                      从进度日志继续被中断的批量检测，跳过已完成的目标")
+	fmt.Println("")
 	fmt.Println("示例:")
 	fmt.Println("  reality-checker check apple.com")
 	fmt.Println("  reality-checker batch apple.com tesla.com microsoft.com")
@@ -32,26 +41,32 @@ func PrintUsage() {
 
 // PrintTimestampedMessage 打印带时间戳的消息
 func PrintTimestampedMessage(format string, args ...interface{}) {
+	FprintTimestampedMessage(os.Stdout, format, args...)
+}
+
+// FprintTimestampedMessage 将带时间戳的消息写到指定位置
+func FprintTimestampedMessage(w io.Writer, format string, args ...interface{}) {
 	timestamp := time.Now().Format("15:04:05")
 	message := fmt.Sprintf(format, args...)
-	fmt.Printf("[%s] %s\n", timestamp, message)
+	fmt.Fprintf(w, "[%s] %s\n", timestamp, message)
 }
 
 // PrintError 打印错误信息（带空行间距）
+// 错误信息写到标准错误，不与检测结果混在一起
 func PrintError(message string) {
-	fmt.Println()
-	fmt.Println(message)
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, message)
+	fmt.Fprintln(os.Stderr)
 }
 
 // PrintErrorWithDetails 打印错误信息和详细信息
 func PrintErrorWithDetails(message string, details ...string) {
-	fmt.Println()
-	fmt.Println(message)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, message)
 	for _, detail := range details {
-		fmt.Println(detail)
+		fmt.Fprintln(os.Stderr, detail)
 	}
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 }
 
 // getLatestVersion 获取GitHub最新版本号
@@ -5,25 +5,36 @@ import (
 	"os"
 
 	"RealityChecker/internal/cmd"
+	"RealityChecker/internal/config"
 	"RealityChecker/internal/data"
 	"RealityChecker/internal/ui"
 )
 
 func main() {
-	// 首先显示横幅
-	ui.PrintBanner()
-	
+	// 加载配置，数据文件下载同样经由配置的上游代理
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	// JSON输出时标准输出只保留检测结果，横幅、进度和提示信息写到标准错误
+	progress := cmd.ProgressOutput(cfg)
+
+	// 显示横幅
+	ui.FprintBanner(progress)
+
 	// 检查并下载必要的数据文件
-	downloader := data.NewDownloader()
+	downloader := data.NewDownloader(&cfg.Network, progress)
 	if err := downloader.EnsureDataFiles(); err != nil {
-		fmt.Printf("数据文件检查失败: %v\n", err)
+		fmt.Fprintf(os.Stderr, "数据文件检查失败: %v\n", err)
 		os.Exit(1)
 	}
 
 	// 创建根命令
-	rootCmd, err := cmd.NewRootCmd()
+	rootCmd, err := cmd.NewRootCmd(progress)
 	if err != nil {
-		fmt.Printf("初始化失败: %v\n", err)
+		fmt.Fprintf(os.Stderr, "初始化失败: %v\n", err)
 		os.Exit(1)
 	}
 